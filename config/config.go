@@ -0,0 +1,430 @@
+// Package config loads the application settings. Values are resolved in
+// increasing order of precedence from built-in defaults, a YAML file, the
+// environment (including a .env file) and command-line flags, and the result
+// is validated before the application starts.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is read when no -config flag or NOMADO_CONFIG variable is given.
+// It is optional; a missing default file is not an error.
+const DefaultFile = "nomado.yaml"
+
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Log       LogConfig       `yaml:"log"`
+	Trash     TrashConfig     `yaml:"trash"`
+	Geocoding GeocodingConfig `yaml:"geocoding"`
+	Storage   StorageConfig   `yaml:"storage"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// RequestTimeout bounds the database work done for a single request
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+// TrashConfig controls how long deleted houses can be restored.
+type TrashConfig struct {
+	// Retention is how long a deleted house stays in the trash before it is
+	// removed for good
+	Retention time.Duration `yaml:"retention"`
+	// PurgeInterval is how often houses past the retention are removed
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+// GeocodingConfig selects how house addresses are turned into coordinates.
+type GeocodingConfig struct {
+	// Provider is "gazetteer" to geocode from the imported places table or
+	// "none" to disable geocoding
+	Provider string `yaml:"provider"`
+}
+
+// StorageConfig selects where uploaded images are kept.
+type StorageConfig struct {
+	// Backend is "local" to keep files in Dir and serve them under /images/,
+	// or "s3" to keep them in an S3-compatible bucket
+	Backend string `yaml:"backend"`
+	Dir     string `yaml:"dir"`
+	// MaxUploadMB limits the size of an uploaded image
+	MaxUploadMB int      `yaml:"max_upload_mb"`
+	S3          S3Config `yaml:"s3"`
+}
+
+type S3Config struct {
+	// Endpoint is the base URL of the service, e.g. http://localhost:9000
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// PathStyle addresses the bucket as a path rather than a subdomain, as
+	// MinIO requires
+	PathStyle bool `yaml:"path_style"`
+	// PublicURL is where clients fetch files from, such as a CDN; it defaults
+	// to the bucket URL
+	PublicURL string `yaml:"public_url"`
+}
+
+type LogConfig struct {
+	Level   string   `yaml:"level"`
+	Format  string   `yaml:"format"`
+	Outputs []string `yaml:"outputs"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     120 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+			RequestTimeout:  10 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:    "localhost",
+			Port:    5432,
+			User:    "postgres",
+			Name:    "nomado",
+			SSLMode: "disable",
+		},
+		Log: LogConfig{
+			Level:   "info",
+			Format:  "text",
+			Outputs: []string{"stdout", "nomado.log"},
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Geocoding: GeocodingConfig{
+			Provider: "gazetteer",
+		},
+		Storage: StorageConfig{
+			Backend:     "local",
+			Dir:         "uploads",
+			MaxUploadMB: 10,
+			S3: S3Config{
+				Region: "us-east-1",
+			},
+		},
+	}
+}
+
+// DSN returns the lib/pq connection string for the database.
+func (d DatabaseConfig) DSN() string {
+	quote := func(value string) string {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(d.Host), d.Port, quote(d.User), quote(d.Password), quote(d.Name), quote(d.SSLMode))
+}
+
+// Load resolves the configuration from args (without the program name), the
+// environment and the config file. Parsing stops at the first non-flag
+// argument; the remaining arguments, such as a subcommand, are returned.
+func Load(name string, args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", "", "path to a YAML config file (default $NOMADO_CONFIG or "+DefaultFile+")")
+	addr := flags.String("addr", "", "HTTP listen address, e.g. :8080")
+	dbHost := flags.String("db-host", "", "database host")
+	dbPort := flags.Int("db-port", 0, "database port")
+	dbUser := flags.String("db-user", "", "database user")
+	dbName := flags.String("db-name", "", "database name")
+	dbSSLMode := flags.String("db-sslmode", "", "database sslmode")
+	logLevel := flags.String("log-level", "", "minimum log level: debug, info, warn or error")
+	logFormat := flags.String("log-format", "", "log format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	// A .env file is optional and never overrides the real environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to load .env file: %w", err)
+	}
+
+	cfg := Default()
+
+	path, required := *configFile, true
+	if path == "" {
+		path = os.Getenv("NOMADO_CONFIG")
+	}
+	if path == "" {
+		path, required = DefaultFile, false
+	}
+	if err := cfg.loadFile(path, required); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	// Only flags given on the command line override the other sources
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "db-host":
+			cfg.Database.Host = *dbHost
+		case "db-port":
+			cfg.Database.Port = *dbPort
+		case "db-user":
+			cfg.Database.User = *dbUser
+		case "db-name":
+			cfg.Database.Name = *dbName
+		case "db-sslmode":
+			cfg.Database.SSLMode = *dbSSLMode
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "log-format":
+			cfg.Log.Format = *logFormat
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return &cfg, flags.Args(), nil
+}
+
+func (c *Config) loadFile(path string, required bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	texts := []struct {
+		key    string
+		target *string
+	}{
+		{"HTTP_ADDR", &c.Server.Addr},
+		{"DB_HOST", &c.Database.Host},
+		{"DB_USER", &c.Database.User},
+		{"DB_PASSWORD", &c.Database.Password},
+		{"DB_NAME", &c.Database.Name},
+		{"DB_SSLMODE", &c.Database.SSLMode},
+		{"LOG_LEVEL", &c.Log.Level},
+		{"LOG_FORMAT", &c.Log.Format},
+		{"GEOCODING_PROVIDER", &c.Geocoding.Provider},
+		{"STORAGE_BACKEND", &c.Storage.Backend},
+		{"STORAGE_DIR", &c.Storage.Dir},
+		{"S3_ENDPOINT", &c.Storage.S3.Endpoint},
+		{"S3_REGION", &c.Storage.S3.Region},
+		{"S3_BUCKET", &c.Storage.S3.Bucket},
+		{"S3_ACCESS_KEY_ID", &c.Storage.S3.AccessKeyID},
+		{"S3_SECRET_ACCESS_KEY", &c.Storage.S3.SecretAccessKey},
+		{"S3_PUBLIC_URL", &c.Storage.S3.PublicURL},
+	}
+	for _, t := range texts {
+		if value := os.Getenv(t.key); value != "" {
+			*t.target = value
+		}
+	}
+
+	durations := []struct {
+		key    string
+		target *time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", &c.Server.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", &c.Server.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", &c.Server.IdleTimeout},
+		{"HTTP_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout},
+		{"HTTP_REQUEST_TIMEOUT", &c.Server.RequestTimeout},
+		{"TRASH_RETENTION", &c.Trash.Retention},
+		{"TRASH_PURGE_INTERVAL", &c.Trash.PurgeInterval},
+	}
+	for _, d := range durations {
+		if value := os.Getenv(d.key); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a duration such as 30s", d.key)
+			}
+			*d.target = parsed
+		}
+	}
+
+	if value := os.Getenv("DB_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("DB_PORT must be an integer")
+		}
+		c.Database.Port = port
+	}
+
+	if value := os.Getenv("STORAGE_MAX_UPLOAD_MB"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("STORAGE_MAX_UPLOAD_MB must be an integer")
+		}
+		c.Storage.MaxUploadMB = size
+	}
+
+	if value := os.Getenv("S3_PATH_STYLE"); value != "" {
+		pathStyle, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("S3_PATH_STYLE must be true or false")
+		}
+		c.Storage.S3.PathStyle = pathStyle
+	}
+
+	if value := os.Getenv("LOG_OUTPUTS"); value != "" {
+		c.Log.Outputs = splitList(value)
+	}
+
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		problems = append(problems, fmt.Sprintf("server.addr %q is not a host:port address", c.Server.Addr))
+	}
+	timeouts := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"server.request_timeout", c.Server.RequestTimeout},
+		{"trash.retention", c.Trash.Retention},
+		{"trash.purge_interval", c.Trash.PurgeInterval},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			problems = append(problems, t.key+" must be positive")
+		}
+	}
+	// Otherwise the connection is cut before the timeout response is written
+	if c.Server.RequestTimeout >= c.Server.WriteTimeout {
+		problems = append(problems, "server.request_timeout must be shorter than server.write_timeout")
+	}
+
+	if c.Database.Host == "" {
+		problems = append(problems, "database.host is required")
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("database.port %d is out of range", c.Database.Port))
+	}
+	if c.Database.User == "" {
+		problems = append(problems, "database.user is required")
+	}
+	if c.Database.Name == "" {
+		problems = append(problems, "database.name is required")
+	}
+	switch c.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		problems = append(problems, fmt.Sprintf("database.sslmode %q is not a valid sslmode", c.Database.SSLMode))
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "text", "json":
+	default:
+		problems = append(problems, fmt.Sprintf("log.format %q must be text or json", c.Log.Format))
+	}
+	if len(c.Log.Outputs) == 0 {
+		problems = append(problems, "log.outputs must list at least one output")
+	}
+
+	switch c.Geocoding.Provider {
+	case "gazetteer", "none":
+	default:
+		problems = append(problems, fmt.Sprintf("geocoding.provider %q must be gazetteer or none", c.Geocoding.Provider))
+	}
+
+	switch c.Storage.Backend {
+	case "local":
+		if c.Storage.Dir == "" {
+			problems = append(problems, "storage.dir is required by the local backend")
+		}
+	case "s3":
+		s3 := c.Storage.S3
+		for _, required := range []struct{ key, value string }{
+			{"storage.s3.endpoint", s3.Endpoint},
+			{"storage.s3.region", s3.Region},
+			{"storage.s3.bucket", s3.Bucket},
+			{"storage.s3.access_key_id", s3.AccessKeyID},
+			{"storage.s3.secret_access_key", s3.SecretAccessKey},
+		} {
+			if required.value == "" {
+				problems = append(problems, required.key+" is required by the s3 backend")
+			}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("storage.backend %q must be local or s3", c.Storage.Backend))
+	}
+	if c.Storage.MaxUploadMB < 1 || c.Storage.MaxUploadMB > 100 {
+		problems = append(problems, fmt.Sprintf("storage.max_upload_mb %d must be between 1 and 100", c.Storage.MaxUploadMB))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -4,47 +4,29 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"thugcorp.io/nomado/config"
 )
 
 type Database struct {
 	DB *sql.DB
 }
 
-func NewDatabase() (*Database, error) {
-	// Load environment variables
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("Warning: Error loading .env file")
-	}
-
-	// Get database connection parameters from environment variables
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "")
-	dbname := getEnv("DB_NAME", "nomado")
-	sslmode := getEnv("DB_SSLMODE", "disable")
-
-	// Create connection string
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode)
-
+func NewDatabase(cfg config.DatabaseConfig) (*Database, error) {
 	// Open database connection
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", cfg.DSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Printf("Successfully connected to database: %s:%s/%s", host, port, dbname)
+	log.Printf("Successfully connected to database: %s:%d/%s", cfg.Host, cfg.Port, cfg.Name)
 
 	return &Database{DB: db}, nil
 }
@@ -53,52 +35,6 @@ func (d *Database) Close() error {
 	return d.DB.Close()
 }
 
-func (d *Database) CreateTables() error {
-	schema := `
-	-- Create house_types table
-	CREATE TABLE IF NOT EXISTS house_types (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(100) NOT NULL UNIQUE
-	);
-
-	-- Create agents table
-	CREATE TABLE IF NOT EXISTS agents (
-		id SERIAL PRIMARY KEY,
-		first_name VARCHAR(100) NOT NULL,
-		last_name VARCHAR(100) NOT NULL,
-		image_url TEXT
-	);
-
-	-- Create houses table
-	CREATE TABLE IF NOT EXISTS houses (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		description TEXT,
-		house_type_id INTEGER REFERENCES house_types(id) ON DELETE SET NULL,
-		price DECIMAL(12,2) NOT NULL,
-		tags TEXT, -- comma-separated tags
-		image_url TEXT,
-		created_at TIMESTAMP DEFAULT NOW(),
-		updated_at TIMESTAMP DEFAULT NOW(),
-		agent_id INTEGER REFERENCES agents(id) ON DELETE SET NULL
-	);
-
-	-- Create indexes for better performance
-	CREATE INDEX IF NOT EXISTS idx_houses_price ON houses(price);
-	CREATE INDEX IF NOT EXISTS idx_houses_house_type_id ON houses(house_type_id);
-	CREATE INDEX IF NOT EXISTS idx_houses_agent_id ON houses(agent_id);
-	CREATE INDEX IF NOT EXISTS idx_houses_created_at ON houses(created_at);
-	`
-
-	_, err := d.DB.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
-	}
-
-	log.Println("Database tables created successfully")
-	return nil
-}
-
 func (d *Database) SeedData() error {
 	// Insert sample house types
 	houseTypesQuery := `
@@ -134,14 +70,16 @@ func (d *Database) SeedData() error {
 
 	// Insert sample houses
 	housesQuery := `
-	INSERT INTO houses (name, description, house_type_id, price, tags, image_url, agent_id) VALUES 
-		('Luxury Villa Downtown', 'Beautiful 4-bedroom villa in the heart of the city with stunning views and modern amenities.', 1, 850000.00, 'luxury,downtown,4-bedroom,modern', '/images/logo.png', 1),
-		('Modern Apartment Complex', 'Contemporary 2-bedroom apartment with all modern conveniences and great location.', 2, 320000.00, 'modern,2-bedroom,apartment,convenient', '/images/logo.png', 2),
-		('Family House Suburbia', 'Spacious 3-bedroom house perfect for families, with a large garden and quiet neighborhood.', 3, 450000.00, 'family,3-bedroom,garden,quiet', '/images/logo.png', 3),
-		('Executive Townhouse', 'Elegant 3-bedroom townhouse with premium finishes and close to business district.', 4, 620000.00, 'executive,3-bedroom,premium,business', '/images/logo.png', 4),
-		('City Center Condo', 'Stylish 1-bedroom condo in the city center with great amenities and city views.', 5, 280000.00, 'stylish,1-bedroom,city-center,views', '/images/logo.png', 5),
-		('Waterfront Villa', 'Stunning waterfront villa with private beach access and panoramic ocean views.', 1, 1200000.00, 'waterfront,luxury,beach,ocean-views', '/images/logo.png', 1),
-		('Garden Apartment', 'Charming 2-bedroom apartment with private garden and peaceful surroundings.', 2, 380000.00, 'charming,2-bedroom,garden,peaceful', '/images/logo.png', 2)
+	INSERT INTO houses (name, description, house_type_id, price, tags, image_url, agent_id,
+		bedrooms, bathrooms, floor_area, lot_size, year_built, parking_spaces, floors,
+		street, city, region, postal_code, country, latitude, longitude) VALUES 
+		('Luxury Villa Downtown', 'Beautiful 4-bedroom villa in the heart of the city with stunning views and modern amenities.', 1, 850000.00, '{luxury,downtown,4-bedroom,modern}', '/images/logo.png', 1, 4, 3.5, 320, 600, 2012, 2, 2, '200 Congress Ave', 'Austin', 'TX', '78701', 'US', 30.2651, -97.7436),
+		('Modern Apartment Complex', 'Contemporary 2-bedroom apartment with all modern conveniences and great location.', 2, 320000.00, '{modern,2-bedroom,apartment,convenient}', '/images/logo.png', 2, 2, 1, 85, NULL, 2018, 1, 1, '1100 S Lamar Blvd', 'Austin', 'TX', '78704', 'US', 30.2546, -97.7637),
+		('Family House Suburbia', 'Spacious 3-bedroom house perfect for families, with a large garden and quiet neighborhood.', 3, 450000.00, '{family,3-bedroom,garden,quiet}', '/images/logo.png', 3, 3, 2, 160, 750, 1995, 2, 2, '4501 Spicewood Springs Rd', 'Austin', 'TX', '78759', 'US', 30.3797, -97.7593),
+		('Executive Townhouse', 'Elegant 3-bedroom townhouse with premium finishes and close to business district.', 4, 620000.00, '{executive,3-bedroom,premium,business}', '/images/logo.png', 4, 3, 2.5, 180, 120, 2008, 1, 3, '500 W 2nd St', 'Austin', 'TX', '78701', 'US', 30.2652, -97.7487),
+		('City Center Condo', 'Stylish 1-bedroom condo in the city center with great amenities and city views.', 5, 280000.00, '{stylish,1-bedroom,city-center,views}', '/images/logo.png', 5, 1, 1, 55, NULL, 2015, 0, 1, '300 Bowie St', 'Austin', 'TX', '78703', 'US', 30.2677, -97.7523),
+		('Waterfront Villa', 'Stunning waterfront villa with private beach access and panoramic ocean views.', 1, 1200000.00, '{waterfront,luxury,beach,ocean-views}', '/images/logo.png', 1, 5, 4, 410, 1500, 2004, 3, 2, '2500 Lake Austin Blvd', 'Austin', 'TX', '78703', 'US', 30.2853, -97.7855),
+		('Garden Apartment', 'Charming 2-bedroom apartment with private garden and peaceful surroundings.', 2, 380000.00, '{charming,2-bedroom,garden,peaceful}', '/images/logo.png', 2, 2, 1, 90, 60, 1987, 1, 1, '1600 E 6th St', 'Austin', 'TX', '78702', 'US', 30.2631, -97.7284)
 	ON CONFLICT DO NOTHING;
 	`
 
@@ -150,13 +88,20 @@ func (d *Database) SeedData() error {
 		return fmt.Errorf("failed to seed houses: %w", err)
 	}
 
-	log.Println("Database seeded successfully")
-	return nil
-}
+	// Give every house without a gallery its image as the cover
+	imagesQuery := `
+	INSERT INTO house_images (house_id, url, caption, alt_text, position, is_cover)
+	SELECT h.id, h.image_url, '', h.name, 0, TRUE
+	FROM houses h
+	WHERE h.image_url IS NOT NULL AND h.image_url <> ''
+		AND NOT EXISTS (SELECT 1 FROM house_images i WHERE i.house_id = h.id);
+	`
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	_, err = d.DB.Exec(imagesQuery)
+	if err != nil {
+		return fmt.Errorf("failed to seed house images: %w", err)
 	}
-	return defaultValue
+
+	log.Println("Database seeded successfully")
+	return nil
 }
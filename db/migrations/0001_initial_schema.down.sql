@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS houses;
+DROP TABLE IF EXISTS agents;
+DROP TABLE IF EXISTS house_types;
@@ -0,0 +1,36 @@
+-- Uses IF NOT EXISTS so that databases created by the former
+-- Database.CreateTables can adopt the migration history.
+
+-- Create house_types table
+CREATE TABLE IF NOT EXISTS house_types (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL UNIQUE
+);
+
+-- Create agents table
+CREATE TABLE IF NOT EXISTS agents (
+	id SERIAL PRIMARY KEY,
+	first_name VARCHAR(100) NOT NULL,
+	last_name VARCHAR(100) NOT NULL,
+	image_url TEXT
+);
+
+-- Create houses table
+CREATE TABLE IF NOT EXISTS houses (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	description TEXT,
+	house_type_id INTEGER REFERENCES house_types(id) ON DELETE SET NULL,
+	price DECIMAL(12,2) NOT NULL,
+	tags TEXT, -- comma-separated tags
+	image_url TEXT,
+	created_at TIMESTAMP DEFAULT NOW(),
+	updated_at TIMESTAMP DEFAULT NOW(),
+	agent_id INTEGER REFERENCES agents(id) ON DELETE SET NULL
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_houses_price ON houses(price);
+CREATE INDEX IF NOT EXISTS idx_houses_house_type_id ON houses(house_type_id);
+CREATE INDEX IF NOT EXISTS idx_houses_agent_id ON houses(agent_id);
+CREATE INDEX IF NOT EXISTS idx_houses_created_at ON houses(created_at);
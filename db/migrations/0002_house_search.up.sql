@@ -0,0 +1,8 @@
+-- Full-text search over house names, tags and descriptions
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+	setweight(to_tsvector('english', REPLACE(COALESCE(tags, ''), ',', ' ')), 'B') ||
+	setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_houses_search_vector ON houses USING GIN(search_vector);
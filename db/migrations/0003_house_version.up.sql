@@ -0,0 +1,2 @@
+-- Row version for optimistic concurrency, incremented by every update
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_houses_search_vector;
+ALTER TABLE houses DROP COLUMN IF EXISTS search_vector;
+DROP INDEX IF EXISTS idx_houses_tags;
+
+ALTER TABLE houses ALTER COLUMN tags DROP DEFAULT;
+ALTER TABLE houses ALTER COLUMN tags DROP NOT NULL;
+ALTER TABLE houses ALTER COLUMN tags TYPE TEXT USING NULLIF(array_to_string(tags, ','), '');
+
+ALTER TABLE houses ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+	setweight(to_tsvector('english', REPLACE(COALESCE(tags, ''), ',', ' ')), 'B') ||
+	setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_houses_search_vector ON houses USING GIN(search_vector);
+
+DROP FUNCTION IF EXISTS house_tags_text(TEXT[]);
@@ -0,0 +1,37 @@
+-- Store tags as a TEXT[] of normalised slugs instead of comma-separated TEXT,
+-- so that tags cannot contain the separator and can be indexed.
+
+-- array_to_string is only STABLE, but generated columns need IMMUTABLE functions
+CREATE OR REPLACE FUNCTION house_tags_text(tags TEXT[]) RETURNS TEXT
+	LANGUAGE sql IMMUTABLE PARALLEL SAFE
+	AS $$ SELECT array_to_string(tags, ' ') $$;
+
+-- The search vector depends on the tags column
+DROP INDEX IF EXISTS idx_houses_search_vector;
+ALTER TABLE houses DROP COLUMN IF EXISTS search_vector;
+
+-- Slug every tag like repository.NormalizeTag and drop duplicates, keeping
+-- the first occurrence
+ALTER TABLE houses ADD COLUMN tag_slugs TEXT[] NOT NULL DEFAULT '{}';
+UPDATE houses h SET tag_slugs = ARRAY(
+	SELECT slug FROM (
+		SELECT trim(BOTH '-' FROM regexp_replace(lower(t.tag), '[^[:alnum:]]+', '-', 'g')) AS slug,
+			MIN(t.position) AS position
+		FROM unnest(string_to_array(h.tags, ',')) WITH ORDINALITY AS t(tag, position)
+		GROUP BY 1
+	) AS slugs
+	WHERE slug <> ''
+	ORDER BY position
+);
+ALTER TABLE houses DROP COLUMN tags;
+ALTER TABLE houses RENAME COLUMN tag_slugs TO tags;
+
+CREATE INDEX IF NOT EXISTS idx_houses_tags ON houses USING GIN(tags);
+
+ALTER TABLE houses ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+	setweight(to_tsvector('english', house_tags_text(tags)), 'B') ||
+	setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_houses_search_vector ON houses USING GIN(search_vector);
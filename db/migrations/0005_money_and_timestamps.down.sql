@@ -0,0 +1,7 @@
+ALTER TABLE houses DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE houses
+	ALTER COLUMN created_at DROP NOT NULL,
+	ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE current_setting('TimeZone'),
+	ALTER COLUMN updated_at DROP NOT NULL,
+	ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE current_setting('TimeZone');
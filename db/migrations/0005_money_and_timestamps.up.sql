@@ -0,0 +1,15 @@
+-- Store timestamps as absolute instants and give every price a currency.
+
+-- Existing values were written by NOW() in the session time zone, which is
+-- also how ALTER COLUMN interprets them while converting
+UPDATE houses SET created_at = NOW() WHERE created_at IS NULL;
+UPDATE houses SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE houses
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE current_setting('TimeZone'),
+	ALTER COLUMN created_at SET NOT NULL,
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE current_setting('TimeZone'),
+	ALTER COLUMN updated_at SET NOT NULL;
+
+-- ISO 4217 code of price, which stays DECIMAL(12,2)
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD'
+	CONSTRAINT houses_currency_check CHECK (currency ~ '^[A-Z]{3}$');
@@ -0,0 +1,5 @@
+-- Houses still in the trash are deleted for good
+DELETE FROM houses WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_houses_deleted_at;
+ALTER TABLE houses DROP COLUMN IF EXISTS deleted_at;
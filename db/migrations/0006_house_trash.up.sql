@@ -0,0 +1,5 @@
+-- Deleted houses are kept in the trash until purged
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Serves the trash listing and the purge; live houses are not indexed
+CREATE INDEX IF NOT EXISTS idx_houses_deleted_at ON houses(deleted_at) WHERE deleted_at IS NOT NULL;
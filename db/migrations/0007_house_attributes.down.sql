@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_houses_floor_area_sqm;
+DROP INDEX IF EXISTS idx_houses_bedrooms;
+
+ALTER TABLE houses
+	DROP COLUMN IF EXISTS lot_size_sqm,
+	DROP COLUMN IF EXISTS floor_area_sqm,
+	DROP COLUMN IF EXISTS floors,
+	DROP COLUMN IF EXISTS parking_spaces,
+	DROP COLUMN IF EXISTS year_built,
+	DROP COLUMN IF EXISTS area_unit,
+	DROP COLUMN IF EXISTS lot_size,
+	DROP COLUMN IF EXISTS floor_area,
+	DROP COLUMN IF EXISTS bathrooms,
+	DROP COLUMN IF EXISTS bedrooms;
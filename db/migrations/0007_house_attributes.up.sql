@@ -0,0 +1,37 @@
+-- Structured property attributes, NULL when unknown. Areas are stored in the
+-- unit they were given in; the generated *_sqm columns serve range filters.
+ALTER TABLE houses
+	ADD COLUMN IF NOT EXISTS bedrooms SMALLINT CONSTRAINT houses_bedrooms_check CHECK (bedrooms >= 0),
+	ADD COLUMN IF NOT EXISTS bathrooms NUMERIC(3,1) CONSTRAINT houses_bathrooms_check CHECK (bathrooms >= 0),
+	ADD COLUMN IF NOT EXISTS floor_area NUMERIC(10,2) CONSTRAINT houses_floor_area_check CHECK (floor_area > 0),
+	ADD COLUMN IF NOT EXISTS lot_size NUMERIC(12,2) CONSTRAINT houses_lot_size_check CHECK (lot_size > 0),
+	ADD COLUMN IF NOT EXISTS area_unit VARCHAR(4) NOT NULL DEFAULT 'sqm'
+		CONSTRAINT houses_area_unit_check CHECK (area_unit IN ('sqm', 'sqft')),
+	ADD COLUMN IF NOT EXISTS year_built SMALLINT,
+	ADD COLUMN IF NOT EXISTS parking_spaces SMALLINT CONSTRAINT houses_parking_spaces_check CHECK (parking_spaces >= 0),
+	ADD COLUMN IF NOT EXISTS floors SMALLINT CONSTRAINT houses_floors_check CHECK (floors > 0);
+
+-- Square foot to square metre factor, as repository.SquareMetres
+ALTER TABLE houses
+	ADD COLUMN floor_area_sqm NUMERIC GENERATED ALWAYS AS (
+		CASE area_unit WHEN 'sqft' THEN floor_area * 0.09290304 ELSE floor_area END
+	) STORED,
+	ADD COLUMN lot_size_sqm NUMERIC GENERATED ALWAYS AS (
+		CASE area_unit WHEN 'sqft' THEN lot_size * 0.09290304 ELSE lot_size END
+	) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_houses_bedrooms ON houses(bedrooms);
+CREATE INDEX IF NOT EXISTS idx_houses_floor_area_sqm ON houses(floor_area_sqm);
+
+-- Backfill bedrooms from tags such as 4-bedroom, 2-bedrooms or 3-bed; the
+-- tags are kept
+UPDATE houses h SET bedrooms = (
+	SELECT substring(tag FROM '^([0-9]{1,2})-bed')::SMALLINT
+	FROM unnest(h.tags) WITH ORDINALITY AS t(tag, position)
+	WHERE tag ~ '^[0-9]{1,2}-bed(room)?s?$'
+	ORDER BY position
+	LIMIT 1
+)
+WHERE bedrooms IS NULL AND EXISTS (
+	SELECT 1 FROM unnest(h.tags) AS tag WHERE tag ~ '^[0-9]{1,2}-bed(room)?s?$'
+);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_houses_location;
+
+ALTER TABLE houses
+	DROP CONSTRAINT IF EXISTS houses_coordinates_check,
+	DROP COLUMN IF EXISTS longitude,
+	DROP COLUMN IF EXISTS latitude,
+	DROP COLUMN IF EXISTS country,
+	DROP COLUMN IF EXISTS postal_code,
+	DROP COLUMN IF EXISTS region,
+	DROP COLUMN IF EXISTS city,
+	DROP COLUMN IF EXISTS street;
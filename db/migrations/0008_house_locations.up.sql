@@ -0,0 +1,18 @@
+-- Postal address, empty when unknown, and WGS 84 coordinates
+ALTER TABLE houses
+	ADD COLUMN IF NOT EXISTS street VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS city VARCHAR(100) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS region VARCHAR(100) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS postal_code VARCHAR(20) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS country VARCHAR(2) NOT NULL DEFAULT ''
+		CONSTRAINT houses_country_check CHECK (country = '' OR country ~ '^[A-Z]{2}$'),
+	ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION
+		CONSTRAINT houses_latitude_check CHECK (latitude BETWEEN -90 AND 90),
+	ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION
+		CONSTRAINT houses_longitude_check CHECK (longitude BETWEEN -180 AND 180),
+	ADD CONSTRAINT houses_coordinates_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+-- Serves bounding-box searches, and radius searches through the bounding box
+-- of their circle. Queries must use the same point(longitude, latitude)
+-- expression.
+CREATE INDEX IF NOT EXISTS idx_houses_location ON houses USING GIST (point(longitude, latitude));
@@ -0,0 +1,18 @@
+-- Places of the offline geocoder, imported with `nomado gazetteer import`
+CREATE TABLE IF NOT EXISTS places (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(200) NOT NULL CONSTRAINT places_name_check CHECK (name <> ''),
+	region VARCHAR(100) NOT NULL DEFAULT '',
+	country VARCHAR(2) NOT NULL DEFAULT ''
+		CONSTRAINT places_country_check CHECK (country = '' OR country ~ '^[A-Z]{2}$'),
+	postal_code VARCHAR(20) NOT NULL DEFAULT '',
+	latitude DOUBLE PRECISION NOT NULL CONSTRAINT places_latitude_check CHECK (latitude BETWEEN -90 AND 90),
+	longitude DOUBLE PRECISION NOT NULL CONSTRAINT places_longitude_check CHECK (longitude BETWEEN -180 AND 180),
+	population BIGINT NOT NULL DEFAULT 0 CONSTRAINT places_population_check CHECK (population >= 0)
+);
+
+-- text_pattern_ops serves both equality and the prefix LIKE of Geocode
+CREATE INDEX IF NOT EXISTS idx_places_name ON places (lower(name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_places_postal_code ON places (lower(postal_code));
+-- Nearest-neighbour search of ReverseGeocode
+CREATE INDEX IF NOT EXISTS idx_places_location ON places USING GIST (point(longitude, latitude));
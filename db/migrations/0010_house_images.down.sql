@@ -0,0 +1 @@
+DROP TABLE IF EXISTS house_images;
@@ -0,0 +1,22 @@
+-- Photo galleries of houses. Positions count from 0 without gaps; the unique
+-- constraint is deferred so that reordering can swap positions.
+CREATE TABLE IF NOT EXISTS house_images (
+	id SERIAL PRIMARY KEY,
+	house_id INTEGER NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+	url TEXT NOT NULL CONSTRAINT house_images_url_check CHECK (url <> ''),
+	caption VARCHAR(500) NOT NULL DEFAULT '',
+	alt_text VARCHAR(255) NOT NULL DEFAULT '',
+	position INTEGER NOT NULL CONSTRAINT house_images_position_check CHECK (position BETWEEN 0 AND 49),
+	is_cover BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CONSTRAINT house_images_house_id_position_key UNIQUE (house_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+-- At most one cover per house
+CREATE UNIQUE INDEX IF NOT EXISTS idx_house_images_cover ON house_images (house_id) WHERE is_cover;
+
+-- The single image of existing houses becomes the cover of their gallery
+INSERT INTO house_images (house_id, url, alt_text, position, is_cover)
+SELECT id, image_url, name, 0, TRUE
+FROM houses
+WHERE image_url IS NOT NULL AND image_url <> '';
@@ -0,0 +1,3 @@
+ALTER TABLE agents DROP COLUMN IF EXISTS image_renditions;
+
+ALTER TABLE house_images DROP COLUMN IF EXISTS renditions;
@@ -0,0 +1,5 @@
+-- URLs of the resized copies of uploaded images, as {"thumbnail", "medium",
+-- "large"}. NULL for images given by URL.
+ALTER TABLE house_images ADD COLUMN IF NOT EXISTS renditions JSONB;
+
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS image_renditions JSONB;
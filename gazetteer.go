@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"thugcorp.io/nomado/config"
+	"thugcorp.io/nomado/db"
+	"thugcorp.io/nomado/gazetteer"
+	"thugcorp.io/nomado/repository"
+)
+
+const gazetteerUsage = `Usage: nomado [flags] gazetteer <command> [arguments]
+
+Commands:
+  import [-format F] [-replace] <file>   Load places for offline geocoding
+                                         (formats: csv, geonames, geonames-postal)
+`
+
+// runGazetteerCommand implements the `gazetteer` subcommand.
+func runGazetteerCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 || args[0] != "import" {
+		fmt.Fprint(os.Stderr, gazetteerUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("gazetteer import", flag.ExitOnError)
+	format := flags.String("format", gazetteer.FormatCSV, "file format: "+strings.Join(gazetteer.Formats, ", "))
+	replace := flags.Bool("replace", false, "remove the places imported before")
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		log.Fatal("Usage: nomado gazetteer import [-format F] [-replace] <file>")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open gazetteer: %v", err)
+	}
+	defer file.Close()
+
+	database, err := db.NewDatabase(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	if _, err := database.MigrateUp(ctx); err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	places := repository.NewGazetteerRepository(database.DB)
+	imported, err := places.ImportPlaces(ctx, gazetteer.Read(file, *format), *replace)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	fmt.Printf("Imported %d place(s)\n", imported)
+}
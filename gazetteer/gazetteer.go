@@ -0,0 +1,202 @@
+// Package gazetteer reads the place files imported into the offline
+// geocoder: GeoNames dumps and CSV extracts such as those derived from
+// OpenStreetMap.
+package gazetteer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+
+	"thugcorp.io/nomado/models"
+)
+
+// Formats of the place files
+const (
+	// FormatCSV is a comma-separated file whose header row names the columns
+	// name, latitude (or lat) and longitude (or lon, lng), and optionally
+	// region, country, postal_code and population. Other columns are ignored.
+	FormatCSV = "csv"
+	// FormatGeoNames is a GeoNames place dump such as cities500.txt or
+	// allCountries.txt. Regions are the admin1 codes, e.g. TX.
+	FormatGeoNames = "geonames"
+	// FormatGeoNamesPostal is a GeoNames postal code dump such as US.txt from
+	// the postal code download.
+	FormatGeoNamesPostal = "geonames-postal"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{FormatCSV, FormatGeoNames, FormatGeoNamesPostal}
+
+// Read returns the places of a file in the given format. A malformed line
+// ends the sequence with an error naming the line.
+func Read(r io.Reader, format string) iter.Seq2[models.Place, error] {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatGeoNames:
+		// geonameid, name, asciiname, alternatenames, latitude, longitude,
+		// feature class, feature code, country code, cc2, admin1 code, admin2
+		// code, admin3 code, admin4 code, population, ...
+		return readTabs(r, 15, func(f []string) rawPlace {
+			return rawPlace{name: f[1], lat: f[4], lng: f[5], country: f[8], region: f[10], population: f[14]}
+		})
+	case FormatGeoNamesPostal:
+		// country code, postal code, place name, admin name1, admin code1,
+		// admin name2, admin code2, admin name3, admin code3, latitude,
+		// longitude, accuracy
+		return readTabs(r, 11, func(f []string) rawPlace {
+			region := f[4]
+			if region == "" {
+				region = f[3]
+			}
+			return rawPlace{name: f[2], lat: f[9], lng: f[10], country: f[0], region: region, postalCode: f[1]}
+		})
+	}
+	return func(yield func(models.Place, error) bool) {
+		yield(models.Place{}, fmt.Errorf("unknown gazetteer format %q, expected one of %s", format, strings.Join(Formats, ", ")))
+	}
+}
+
+// rawPlace holds the text fields of a place before they are parsed.
+type rawPlace struct {
+	name, lat, lng, region, country, postalCode, population string
+}
+
+func (raw rawPlace) place() (models.Place, error) {
+	place := models.Place{
+		Name:       strings.TrimSpace(raw.name),
+		Region:     strings.TrimSpace(raw.region),
+		Country:    strings.ToUpper(strings.TrimSpace(raw.country)),
+		PostalCode: strings.TrimSpace(raw.postalCode),
+	}
+	if place.Name == "" {
+		return place, errors.New("name is empty")
+	}
+
+	var err error
+	if place.Latitude, err = strconv.ParseFloat(strings.TrimSpace(raw.lat), 64); err != nil {
+		return place, fmt.Errorf("invalid latitude %q", raw.lat)
+	}
+	if place.Longitude, err = strconv.ParseFloat(strings.TrimSpace(raw.lng), 64); err != nil {
+		return place, fmt.Errorf("invalid longitude %q", raw.lng)
+	}
+	if population := strings.TrimSpace(raw.population); population != "" {
+		if place.Population, err = strconv.ParseInt(population, 10, 64); err != nil {
+			return place, fmt.Errorf("invalid population %q", raw.population)
+		}
+	}
+	return place, nil
+}
+
+// readTabs reads a GeoNames file: unquoted, tab-separated fields with at least
+// columns fields per line.
+func readTabs(r io.Reader, columns int, parse func(fields []string) rawPlace) iter.Seq2[models.Place, error] {
+	return func(yield func(models.Place, error) bool) {
+		scanner := bufio.NewScanner(r)
+		// Lines of allCountries.txt carry long lists of alternate names
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			fields := strings.Split(scanner.Text(), "\t")
+			if len(fields) < columns {
+				yield(models.Place{}, fmt.Errorf("line %d: expected %d tab-separated fields, got %d", line, columns, len(fields)))
+				return
+			}
+			place, err := parse(fields).place()
+			if err != nil {
+				yield(models.Place{}, fmt.Errorf("line %d: %w", line, err))
+				return
+			}
+			if !yield(place, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(models.Place{}, fmt.Errorf("failed to read gazetteer: %w", err))
+		}
+	}
+}
+
+// csvColumns maps the CSV columns to the fields of a place.
+var csvColumns = map[string]func(raw *rawPlace, value string){
+	"name":        func(raw *rawPlace, value string) { raw.name = value },
+	"latitude":    func(raw *rawPlace, value string) { raw.lat = value },
+	"longitude":   func(raw *rawPlace, value string) { raw.lng = value },
+	"region":      func(raw *rawPlace, value string) { raw.region = value },
+	"country":     func(raw *rawPlace, value string) { raw.country = value },
+	"postal_code": func(raw *rawPlace, value string) { raw.postalCode = value },
+	"population":  func(raw *rawPlace, value string) { raw.population = value },
+}
+
+// csvAliases are the other accepted names of CSV columns.
+var csvAliases = map[string]string{"lat": "latitude", "lon": "longitude", "lng": "longitude"}
+
+// csvRequired are the columns a CSV header must name.
+var csvRequired = []string{"name", "latitude", "longitude"}
+
+func readCSV(r io.Reader) iter.Seq2[models.Place, error] {
+	return func(yield func(models.Place, error) bool) {
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+
+		header, err := reader.Read()
+		if err != nil {
+			yield(models.Place{}, fmt.Errorf("failed to read the CSV header: %w", err))
+			return
+		}
+		setters := make([]func(*rawPlace, string), len(header))
+		seen := make(map[string]bool)
+		for i, name := range header {
+			// Spreadsheets often save CSV with a byte order mark
+			name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+			if alias, ok := csvAliases[name]; ok {
+				name = alias
+			}
+			setters[i] = csvColumns[name]
+			seen[name] = true
+		}
+		for _, name := range csvRequired {
+			if !seen[name] {
+				yield(models.Place{}, fmt.Errorf("the CSV header has no %s column", name))
+				return
+			}
+		}
+
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				// Parse errors name their line
+				yield(models.Place{}, fmt.Errorf("failed to read gazetteer: %w", err))
+				return
+			}
+			line, _ := reader.FieldPos(0)
+
+			var raw rawPlace
+			for i, value := range record {
+				if i < len(setters) && setters[i] != nil {
+					setters[i](&raw, value)
+				}
+			}
+			place, err := raw.place()
+			if err != nil {
+				yield(models.Place{}, fmt.Errorf("line %d: %w", line, err))
+				return
+			}
+			if !yield(place, nil) {
+				return
+			}
+		}
+	}
+}
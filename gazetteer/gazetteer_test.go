@@ -0,0 +1,89 @@
+package gazetteer
+
+import (
+	"strings"
+	"testing"
+
+	"thugcorp.io/nomado/models"
+)
+
+// readAll collects the places of a file, stopping at the first error.
+func readAll(input, format string) ([]models.Place, error) {
+	var places []models.Place
+	for place, err := range Read(strings.NewReader(input), format) {
+		if err != nil {
+			return places, err
+		}
+		places = append(places, place)
+	}
+	return places, nil
+}
+
+func TestReadCSV(t *testing.T) {
+	input := "\ufeffName,Lat,lng,country,region,postal_code,population,osm_id\n" +
+		"Austin,30.2672,-97.7431,us,TX,,961855,113314\n" +
+		"\"Round Rock\", 30.5083 ,-97.6789,US,TX,78664,,\n"
+	places, err := readAll(input, FormatCSV)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []models.Place{
+		{Name: "Austin", Region: "TX", Country: "US", Latitude: 30.2672, Longitude: -97.7431, Population: 961855},
+		{Name: "Round Rock", Region: "TX", Country: "US", PostalCode: "78664", Latitude: 30.5083, Longitude: -97.6789},
+	}
+	if len(places) != len(want) {
+		t.Fatalf("Read = %+v, want %+v", places, want)
+	}
+	for i := range want {
+		if places[i] != want[i] {
+			t.Errorf("places[%d] = %+v, want %+v", i, places[i], want[i])
+		}
+	}
+}
+
+func TestReadGeoNames(t *testing.T) {
+	fields := []string{"4671654", "Austin", "Austin", "Austin,Ostin", "30.26715", "-97.74306", "P", "PPLA", "US", "", "TX", "453", "", "", "961855", "149", "165", "America/Chicago", "2024-01-01"}
+	places, err := readAll(strings.Join(fields, "\t")+"\n\n", FormatGeoNames)
+	want := models.Place{Name: "Austin", Region: "TX", Country: "US", Latitude: 30.26715, Longitude: -97.74306, Population: 961855}
+	if err != nil || len(places) != 1 || places[0] != want {
+		t.Errorf("Read = %+v, %v, want %+v", places, err, want)
+	}
+}
+
+func TestReadGeoNamesPostal(t *testing.T) {
+	input := "US\t78701\tAustin\tTexas\tTX\tTravis\t453\t\t\t30.2713\t-97.7426\t4\n" +
+		"FR\t75001\tParis 01\tÎle-de-France\t\tParis\t75\t\t\t48.8626\t2.3363\t5\n"
+	places, err := readAll(input, FormatGeoNamesPostal)
+	if err != nil || len(places) != 2 {
+		t.Fatalf("Read = %+v, %v", places, err)
+	}
+	want := models.Place{Name: "Austin", Region: "TX", Country: "US", PostalCode: "78701", Latitude: 30.2713, Longitude: -97.7426}
+	if places[0] != want {
+		t.Errorf("places[0] = %+v, want %+v", places[0], want)
+	}
+	// Without an admin code the admin name is the region
+	if places[1].Region != "Île-de-France" {
+		t.Errorf("places[1].Region = %q, want the admin name", places[1].Region)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input, format, want string
+	}{
+		{"name,lat\nAustin,30\n", FormatCSV, "no longitude column"},
+		{"", FormatCSV, "CSV header"},
+		{"name,lat,lon\nAustin,30,-97\nRound Rock,north,-97\n", FormatCSV, "line 3: invalid latitude"},
+		{"name,lat,lon\n,30,-97\n", FormatCSV, "line 2: name is empty"},
+		{"name,lat,lon,population\nAustin,30,-97,many\n", FormatCSV, "invalid population"},
+		{"1\tAustin\n", FormatGeoNames, "line 1: expected 15 tab-separated fields, got 2"},
+		{"US\t78701\tAustin\tTexas\tTX\t\t\t\t\t30.2\teast\n", FormatGeoNamesPostal, "line 1: invalid longitude"},
+		{"", "kml", "unknown gazetteer format"},
+	}
+	for _, tt := range tests {
+		_, err := readAll(tt.input, tt.format)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Read(%q, %s) error = %v, want %q", tt.input, tt.format, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/validation"
+)
+
+// Column sizes of the agents table
+const maxAgentNameLength = 100
+
+type AgentHandler struct {
+	responder
+	agentRepo repository.AgentStore
+	houseRepo repository.HouseStore
+	uploader  *ImageUploader
+}
+
+// NewAgentHandler returns the agent handler. Agent images can only be
+// uploaded, rather than linked by URL, if uploader is not nil.
+func NewAgentHandler(agentRepo repository.AgentStore, houseRepo repository.HouseStore, uploader *ImageUploader, logger *logger.Logger) *AgentHandler {
+	return &AgentHandler{
+		agentRepo: agentRepo,
+		houseRepo: houseRepo,
+		uploader:  uploader,
+		responder: responder{logger: logger},
+	}
+}
+
+// validateAgent normalises the agent in place and reports every invalid field.
+func validateAgent(agent *models.Agent) error {
+	agent.FirstName = strings.TrimSpace(agent.FirstName)
+	agent.LastName = strings.TrimSpace(agent.LastName)
+	validation.TrimOptional(&agent.ImageURL)
+	// Only uploads set renditions
+	agent.ImageRenditions = nil
+
+	var v validation.Validator
+	if v.Required("first_name", agent.FirstName) {
+		v.MaxLength("first_name", agent.FirstName, maxAgentNameLength)
+	}
+	if v.Required("last_name", agent.LastName) {
+		v.MaxLength("last_name", agent.LastName, maxAgentNameLength)
+	}
+	v.ImageURL("image_url", agent.ImageURL)
+
+	return v.Err()
+}
+
+func (h *AgentHandler) GetAgents(w http.ResponseWriter, r *http.Request) {
+	agents, err := h.agentRepo.GetAllAgents(r.Context())
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "agents")
+		return
+	}
+
+	h.sendSuccessResponse(w, agents, "Agents retrieved successfully")
+}
+
+func (h *AgentHandler) GetAgentByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid agent ID")
+		return
+	}
+
+	agent, err := h.agentRepo.GetAgentByID(r.Context(), id)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "agent")
+		return
+	}
+
+	h.sendSuccessResponse(w, agent, "Agent retrieved successfully")
+}
+
+func (h *AgentHandler) CreateAgent(w http.ResponseWriter, r *http.Request) {
+	var agent models.Agent
+	if err := validation.DecodeJSON(r.Body, &agent); err != nil {
+		h.sendRequestError(w, r, err, "agent")
+		return
+	}
+
+	if err := validateAgent(&agent); err != nil {
+		h.sendRequestError(w, r, err, "agent")
+		return
+	}
+
+	if err := h.agentRepo.CreateAgent(r.Context(), &agent); err != nil {
+		h.sendRepositoryError(w, r, err, "create", "agent")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    agent,
+		Message: "Agent created successfully",
+	})
+}
+
+func (h *AgentHandler) UpdateAgent(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid agent ID")
+		return
+	}
+
+	var agent models.Agent
+	if err := validation.DecodeJSON(r.Body, &agent); err != nil {
+		h.sendRequestError(w, r, err, "agent")
+		return
+	}
+
+	if err := validateAgent(&agent); err != nil {
+		h.sendRequestError(w, r, err, "agent")
+		return
+	}
+
+	uploaded := h.storedRenditions(r.Context(), id)
+	agent.ID = id
+	if err := h.agentRepo.UpdateAgent(r.Context(), &agent); err != nil {
+		h.sendRepositoryError(w, r, err, "update", "agent")
+		return
+	}
+	// A new image URL replaces the uploaded image
+	if agent.ImageRenditions == nil {
+		h.discard(r.Context(), h.uploader, uploaded)
+	}
+
+	h.sendSuccessResponse(w, agent, "Agent updated successfully")
+}
+
+func (h *AgentHandler) DeleteAgent(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid agent ID")
+		return
+	}
+
+	uploaded := h.storedRenditions(r.Context(), id)
+	if err := h.agentRepo.DeleteAgent(r.Context(), id); err != nil {
+		h.sendRepositoryError(w, r, err, "delete", "agent")
+		return
+	}
+	h.discard(r.Context(), h.uploader, uploaded)
+
+	h.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Agent deleted successfully",
+	})
+}
+
+// UploadAgentImage replaces the image of an agent with an upload, sent as the
+// image field of a multipart/form-data body. Its renditions are stored and
+// the image URL is the large one.
+func (h *AgentHandler) UploadAgentImage(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid agent ID")
+		return
+	}
+
+	up := h.receive(w, r, h.uploader, nil)
+	if up == nil {
+		return
+	}
+
+	// Saves storing files for an agent that does not exist
+	previous, err := h.agentRepo.GetAgentByID(r.Context(), id)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "agent")
+		return
+	}
+	renditions, ok := h.store(w, r, h.uploader, fmt.Sprintf("agents/%d", id), up.image)
+	if !ok {
+		return
+	}
+
+	agent, err := h.agentRepo.SetAgentImage(r.Context(), id, renditions)
+	if err != nil {
+		h.discard(r.Context(), h.uploader, &renditions)
+		h.sendRepositoryError(w, r, err, "update", "agent")
+		return
+	}
+	h.discard(r.Context(), h.uploader, previous.ImageRenditions)
+
+	h.sendSuccessResponse(w, agent, "Agent image uploaded successfully")
+}
+
+// storedRenditions returns the renditions of the uploaded image of an agent,
+// so that they can be discarded once the agent no longer uses them. It
+// returns nil if the image was not uploaded or the agent cannot be found.
+func (h *AgentHandler) storedRenditions(ctx context.Context, id int) *models.Renditions {
+	if h.uploader == nil {
+		return nil
+	}
+	agent, err := h.agentRepo.GetAgentByID(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return agent.ImageRenditions
+}
+
+// GetAgentHouses lists the houses of one agent. It accepts the same
+// filtering, sorting and pagination parameters as GET /api/houses.
+func (h *AgentHandler) GetAgentHouses(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid agent ID")
+		return
+	}
+
+	if _, err := h.agentRepo.GetAgentByID(r.Context(), id); err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "agent")
+		return
+	}
+
+	page, limit, filter, err := parseHouseListQuery(r.URL.Query())
+	if err != nil {
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.AgentID = &id
+
+	houses, total, err := h.houseRepo.ListHouses(r.Context(), filter)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "agent houses")
+		return
+	}
+
+	if houses == nil {
+		houses = []models.HouseWithDetails{}
+	}
+
+	h.sendPaginatedResponse(w, houses, page, limit, total)
+}
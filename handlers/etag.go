@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"thugcorp.io/nomado/models"
+)
+
+// versionETag returns the strong entity tag of a record version.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// detailsETag returns the strong entity tag of a house with its details: the
+// house version, which the gallery changes too, followed by a digest of the
+// agent and house type, which have no version of their own.
+func detailsETag(house *models.HouseWithDetails) string {
+	digest := fnv.New64a()
+	json.NewEncoder(digest).Encode([]interface{}{house.Agent, house.HouseType})
+	return fmt.Sprintf(`"%d-%x"`, house.Version, digest.Sum64())
+}
+
+// parseETags splits an If-Match or If-None-Match header into its entity tags.
+func parseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// notModified reports whether the If-None-Match header of r matches etag,
+// using the weak comparison that RFC 9110 prescribes for this header.
+func notModified(r *http.Request, etag string) bool {
+	for _, tag := range parseETags(r.Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersions returns the record versions named by the If-Match header of
+// r, including those of detailsETag tags: writes only conflict with changes to
+// the record itself. anyVersion is true for "If-Match: *". Weak and foreign
+// tags never match, so a header holding only such tags yields no versions.
+func ifMatchVersions(r *http.Request) (versions []int, anyVersion bool) {
+	for _, tag := range parseETags(r.Header.Get("If-Match")) {
+		if tag == "*" {
+			return nil, true
+		}
+		unquoted, ok := strings.CutPrefix(tag, `"`)
+		if !ok {
+			continue
+		}
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+		unquoted, _, _ = strings.Cut(unquoted, "-")
+		if version, err := strconv.Atoi(unquoted); ok && err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	return versions, false
+}
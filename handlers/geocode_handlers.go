@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+)
+
+// Number of places returned by the geocoding endpoints
+const (
+	defaultGeocodeLimit = 5
+	maxGeocodeLimit     = 20
+)
+
+type GeocodeHandler struct {
+	responder
+	geocoder repository.Geocoder
+}
+
+// NewGeocodeHandler returns the geocoding handlers. geocoder may be nil when
+// geocoding is disabled, in which case they answer 503.
+func NewGeocodeHandler(geocoder repository.Geocoder, logger *logger.Logger) *GeocodeHandler {
+	return &GeocodeHandler{
+		geocoder:  geocoder,
+		responder: responder{logger: logger},
+	}
+}
+
+// Geocode finds the places matching a query such as "Austin, TX" or "78701",
+// best match first.
+func (h *GeocodeHandler) Geocode(w http.ResponseWriter, r *http.Request) {
+	if h.geocoder == nil {
+		h.sendErrorResponse(w, http.StatusServiceUnavailable, "Geocoding is disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Geocoding query (q) is required")
+		return
+	}
+	limit, err := parsePositiveInt(query, "limit", defaultGeocodeLimit)
+	if err != nil {
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	places, err := h.geocoder.Geocode(r.Context(), q, min(limit, maxGeocodeLimit))
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "geocode", "address")
+		return
+	}
+
+	if places == nil {
+		places = []models.Place{}
+	}
+
+	h.sendSuccessResponse(w, places, "Places retrieved successfully")
+}
+
+// ReverseGeocode lists the places nearest to the lat and lng parameters with
+// their distance, nearest first.
+func (h *GeocodeHandler) ReverseGeocode(w http.ResponseWriter, r *http.Request) {
+	if h.geocoder == nil {
+		h.sendErrorResponse(w, http.StatusServiceUnavailable, "Geocoding is disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	lat, latErr := parseFloat(query.Get("lat"))
+	lng, lngErr := parseFloat(query.Get("lng"))
+	if latErr != nil || lngErr != nil || !repository.ValidCoordinates(lat, lng) {
+		h.sendErrorResponse(w, http.StatusBadRequest, "lat and lng must be a latitude and a longitude such as lat=30.27&lng=-97.74")
+		return
+	}
+	limit, err := parsePositiveInt(query, "limit", defaultGeocodeLimit)
+	if err != nil {
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	places, err := h.geocoder.ReverseGeocode(r.Context(), repository.Point{Lat: lat, Lng: lng}, min(limit, maxGeocodeLimit))
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "reverse geocode", "coordinates")
+		return
+	}
+
+	if places == nil {
+		places = []models.Place{}
+	}
+
+	h.sendSuccessResponse(w, places, "Places retrieved successfully")
+}
@@ -1,91 +1,401 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"math"
+	"mime"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"thugcorp.io/nomado/logger"
 	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/money"
 	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/validation"
 )
 
-// Response structures for API responses
-type APIResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-	Message string      `json:"message,omitempty"`
-}
-
-type PaginatedResponse struct {
-	Success    bool        `json:"success"`
-	Data       interface{} `json:"data"`
-	Pagination Pagination  `json:"pagination"`
-	Error      string      `json:"error,omitempty"`
-}
+// Limits of the houses table columns
+const (
+	maxHouseNameLength  = 255
+	maxHousePrice       = 1e10 * money.Unit // DECIMAL(12,2)
+	maxTagLength        = 50
+	maxFloorArea        = 1e8  // NUMERIC(10,2)
+	maxLotSize          = 1e10 // NUMERIC(12,2)
+	maxStreetLength     = 255
+	maxCityLength       = 100
+	maxRegionLength     = 100
+	maxPostalCodeLength = 20
+)
 
-type Pagination struct {
-	Page       int `json:"page"`
-	Limit      int `json:"limit"`
-	Total      int `json:"total"`
-	TotalPages int `json:"total_pages"`
-}
+// Plausible values of the property attributes
+const (
+	maxBedrooms      = 100
+	maxBathrooms     = 99.5 // NUMERIC(3,1) holds less than 100
+	minYearBuilt     = 1000
+	yearBuiltAhead   = 5 // off-plan listings may be completed a few years out
+	maxParkingSpaces = 1000
+	maxFloors        = 200
+)
 
 type HouseHandler struct {
-	houseRepo     *repository.HouseRepository
-	agentRepo     *repository.AgentRepository
-	houseTypeRepo *repository.HouseTypeRepository
-	logger        *logger.Logger
+	responder
+	houseRepo     repository.HouseStore
+	agentRepo     repository.AgentStore
+	houseTypeRepo repository.HouseTypeStore
+	// geocoder fills in missing coordinates from the address, if not nil
+	geocoder repository.Geocoder
 }
 
-type HouseWithDetails struct {
-	models.House
-	Agent     *models.Agent     `json:"agent,omitempty"`
-	HouseType *models.HouseType `json:"house_type,omitempty"`
+// HouseSearchResult is a house returned by GET /api/houses/search.
+type HouseSearchResult struct {
+	models.HouseWithDetails
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
 
-func NewHouseHandler(houseRepo *repository.HouseRepository, agentRepo *repository.AgentRepository, houseTypeRepo *repository.HouseTypeRepository, logger *logger.Logger) *HouseHandler {
+// NewHouseHandler returns the house handlers. geocoder may be nil to store
+// houses without coordinates as given.
+func NewHouseHandler(houseRepo repository.HouseStore, agentRepo repository.AgentStore, houseTypeRepo repository.HouseTypeStore, geocoder repository.Geocoder, logger *logger.Logger) *HouseHandler {
 	return &HouseHandler{
 		houseRepo:     houseRepo,
 		agentRepo:     agentRepo,
 		houseTypeRepo: houseTypeRepo,
-		logger:        logger,
+		geocoder:      geocoder,
+		responder:     responder{logger: logger},
 	}
 }
 
-// Helper methods for consistent API responses
-func (h *HouseHandler) sendSuccessResponse(w http.ResponseWriter, data interface{}, message string) {
-	response := APIResponse{
-		Success: true,
-		Data:    data,
-		Message: message,
+// validateHouse normalises the house in place and reports every invalid
+// field, including an agent or house type that does not exist.
+func (h *HouseHandler) validateHouse(ctx context.Context, house *models.House) error {
+	var v validation.Validator
+	if err := h.checkHouse(ctx, &v, house, nil); err != nil {
+		return err
+	}
+	return v.Err()
+}
+
+// checkHouse normalises the house in place and records its invalid fields in
+// v. Only the JSON fields in only are checked, or every field if only is nil.
+// Agent and house type lookups that fail for a reason other than not found
+// are returned.
+func (h *HouseHandler) checkHouse(ctx context.Context, v *validation.Validator, house *models.House, only map[string]bool) error {
+	check := func(field string) bool {
+		return only == nil || only[field]
+	}
+
+	house.Name = strings.TrimSpace(house.Name)
+	validation.TrimOptional(&house.ImageURL)
+	house.DeletedAt = nil // only DeleteHouse and RestoreHouse move houses
+
+	if check("name") && v.Required("name", house.Name) {
+		v.MaxLength("name", house.Name, maxHouseNameLength)
+	}
+	if check("price") && v.Positive("price", house.Price.Float64()) {
+		v.Below("price", house.Price.Float64(), maxHousePrice.Float64())
+	}
+	if check("currency") {
+		house.Currency = strings.ToUpper(strings.TrimSpace(house.Currency))
+		if house.Currency == "" {
+			house.Currency = money.DefaultCurrency
+		}
+		if !money.ValidCurrency(house.Currency) {
+			v.Add("currency", validation.CodeInvalid, "must be a three-letter ISO 4217 currency code")
+		}
+	}
+	checkAttributes(v, house, check)
+	checkLocation(v, house, check)
+	if check("tags") {
+		for _, tag := range house.Tags {
+			slug := repository.NormalizeTag(tag)
+			if slug == "" {
+				v.Add("tags", validation.CodeInvalid, "tags must contain a letter or digit")
+				break
+			}
+			if !v.MaxLength("tags", slug, maxTagLength) {
+				break
+			}
+		}
+		house.Tags = repository.NormalizeTags(house.Tags)
+	}
+	if check("image_url") {
+		v.ImageURL("image_url", house.ImageURL)
+	}
+
+	if check("agent_id") && v.RequiredID("agent_id", house.AgentID) {
+		if _, err := h.agentRepo.GetAgentByID(ctx, house.AgentID); errors.Is(err, repository.ErrNotFound) {
+			v.Add("agent_id", validation.CodeNotFound, "agent does not exist")
+		} else if err != nil {
+			return err
+		}
+	}
+	if check("house_type_id") && v.RequiredID("house_type_id", house.HouseTypeID) {
+		if _, err := h.houseTypeRepo.GetHouseTypeByID(ctx, house.HouseTypeID); errors.Is(err, repository.ErrNotFound) {
+			v.Add("house_type_id", validation.CodeNotFound, "house type does not exist")
+		} else if err != nil {
+			return err
+		}
 	}
-	h.sendJSONResponse(w, http.StatusOK, response)
+
+	return nil
 }
 
-func (h *HouseHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, errorMsg string) {
-	response := APIResponse{
-		Success: false,
-		Error:   errorMsg,
+// checkAttributes normalises the area unit and records invalid property
+// attributes in v. Unset attributes are unknown and always valid.
+func checkAttributes(v *validation.Validator, house *models.House, check func(string) bool) {
+	if check("bedrooms") {
+		validation.Between(v, "bedrooms", house.Bedrooms, 0, maxBedrooms)
+	}
+	if check("bathrooms") && validation.Between(v, "bathrooms", house.Bathrooms, 0, maxBathrooms) &&
+		house.Bathrooms != nil && math.Mod(*house.Bathrooms*2, 1) != 0 {
+		v.Add("bathrooms", validation.CodeInvalid, "must be a multiple of 0.5")
+	}
+	// Areas are stored with two decimals, so the rounded value must fit
+	roundArea(house.FloorArea)
+	roundArea(house.LotSize)
+	if check("floor_area") && house.FloorArea != nil && v.Positive("floor_area", *house.FloorArea) {
+		v.Below("floor_area", *house.FloorArea, maxFloorArea)
+	}
+	if check("lot_size") && house.LotSize != nil && v.Positive("lot_size", *house.LotSize) {
+		v.Below("lot_size", *house.LotSize, maxLotSize)
+	}
+	if check("area_unit") {
+		house.AreaUnit = strings.ToLower(strings.TrimSpace(house.AreaUnit))
+		if house.AreaUnit == "" {
+			house.AreaUnit = models.AreaUnitSquareMetres
+		}
+		if !repository.ValidAreaUnit(house.AreaUnit) {
+			v.Add("area_unit", validation.CodeInvalid, "must be sqm or sqft")
+		}
+	}
+	if check("year_built") {
+		validation.Between(v, "year_built", house.YearBuilt, minYearBuilt, time.Now().Year()+yearBuiltAhead)
+	}
+	if check("parking_spaces") {
+		validation.Between(v, "parking_spaces", house.ParkingSpaces, 0, maxParkingSpaces)
+	}
+	if check("floors") {
+		validation.Between(v, "floors", house.Floors, 1, maxFloors)
 	}
-	h.sendJSONResponse(w, statusCode, response)
 }
 
-func (h *HouseHandler) sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		h.logger.Error("Failed to encode JSON response", err)
+// roundArea rounds an area to the two decimals of its column.
+func roundArea(area *float64) {
+	if area != nil {
+		*area = math.Round(*area*100) / 100
 	}
 }
 
-func (h *HouseHandler) GetTopHouses(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+// checkLocation normalises the address and records invalid address parts and
+// coordinates in v. A patch may change the address parts one by one, but must
+// change latitude and longitude together.
+func checkLocation(v *validation.Validator, house *models.House, check func(string) bool) {
+	address := &house.Address
+	for _, part := range []struct {
+		field string
+		value *string
+		max   int
+	}{
+		{"address.street", &address.Street, maxStreetLength},
+		{"address.city", &address.City, maxCityLength},
+		{"address.region", &address.Region, maxRegionLength},
+		{"address.postal_code", &address.PostalCode, maxPostalCodeLength},
+	} {
+		if check("address") || check(part.field) {
+			*part.value = strings.TrimSpace(*part.value)
+			v.MaxLength(part.field, *part.value, part.max)
+		}
+	}
+	if check("address") || check("address.country") {
+		address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+		if !repository.ValidCountry(address.Country) {
+			v.Add("address.country", validation.CodeInvalid, "must be a two-letter ISO 3166-1 country code")
+		}
+	}
+
+	if !check("latitude") && !check("longitude") {
+		return
+	}
+	validation.Between(v, "latitude", house.Latitude, -90, 90)
+	validation.Between(v, "longitude", house.Longitude, -180, 180)
+	switch {
+	case !check("latitude") || house.Latitude == nil && house.Longitude != nil:
+		v.Add("latitude", validation.CodeRequired, "must be given together with longitude")
+	case !check("longitude") || house.Longitude == nil && house.Latitude != nil:
+		v.Add("longitude", validation.CodeRequired, "must be given together with latitude")
+	}
+}
+
+// locate sets the coordinates of a house that has an address but none of its
+// own to those of the best matching gazetteer place, if that place is the
+// city or postal code of the address. Geocoding failures are logged and leave
+// the house as it is: coordinates are optional.
+func (h *HouseHandler) locate(ctx context.Context, house *models.House) {
+	if h.geocoder == nil || house.Latitude != nil || house.Longitude != nil {
+		return
+	}
+	query := repository.AddressQuery(house.Address)
+	if query == "" {
+		return
+	}
+
+	places, err := h.geocoder.Geocode(ctx, query, 1)
+	if err != nil {
+		h.logger.WarnContext(ctx, "Failed to geocode house address", "query", query, "error", err)
 		return
 	}
+	if len(places) == 0 || !placeOf(places[0], house.Address) {
+		return
+	}
+	house.Latitude, house.Longitude = &places[0].Latitude, &places[0].Longitude
+}
+
+// placeOf reports whether place is the city or postal code of address, so
+// that a house is never placed at a town that merely shares a prefix.
+func placeOf(place models.Place, address models.Address) bool {
+	if address.Country != "" && place.Country != "" && address.Country != place.Country {
+		return false
+	}
+	return address.City != "" && strings.EqualFold(place.Name, address.City) ||
+		address.PostalCode != "" && strings.EqualFold(place.PostalCode, address.PostalCode)
+}
+
+// Fields of a house that a patch may and may not change
+var (
+	housePatchFields = map[string]bool{
+		"name": true, "description": true, "house_type_id": true, "price": true,
+		"currency": true, "tags": true, "image_url": true, "agent_id": true,
+		"bedrooms": true, "bathrooms": true, "floor_area": true, "lot_size": true,
+		"area_unit": true, "year_built": true, "parking_spaces": true, "floors": true,
+		"address": true, "address.street": true, "address.city": true, "address.region": true,
+		"address.postal_code": true, "address.country": true, "latitude": true, "longitude": true,
+	}
+	houseReadOnlyFields = map[string]bool{"id": true, "created_at": true, "updated_at": true, "deleted_at": true}
+)
+
+// housePatch validates the fields of a merge patch of house id decoded into
+// house and returns the matching repository patch.
+func (h *HouseHandler) housePatch(ctx context.Context, id int, house *models.House, fields map[string]bool) (repository.HousePatch, error) {
+	var v validation.Validator
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch {
+		case houseReadOnlyFields[name]:
+			v.Add(name, validation.CodeReadOnly, "cannot be changed")
+		case !housePatchFields[name]:
+			// Decoding matches field names case-insensitively
+			v.Add(name, validation.CodeUnknownField, "")
+		}
+	}
+	if err := h.checkHouse(ctx, &v, house, fields); err != nil {
+		return repository.HousePatch{}, err
+	}
+	if err := v.Err(); err != nil {
+		return repository.HousePatch{}, err
+	}
+
+	var patch repository.HousePatch
+	if fields["name"] {
+		patch.Name = &house.Name
+	}
+	if fields["description"] {
+		patch.Description = &house.Description
+	}
+	if fields["house_type_id"] {
+		patch.HouseTypeID = &house.HouseTypeID
+	}
+	if fields["price"] {
+		patch.Price = &house.Price
+	}
+	if fields["currency"] {
+		patch.Currency = &house.Currency
+	}
+	if fields["tags"] {
+		patch.Tags = &house.Tags
+	}
+	if fields["image_url"] {
+		patch.ImageURL = &house.ImageURL
+	}
+	if fields["agent_id"] {
+		patch.AgentID = &house.AgentID
+	}
+	if fields["bedrooms"] {
+		patch.Bedrooms = &house.Bedrooms
+	}
+	if fields["bathrooms"] {
+		patch.Bathrooms = &house.Bathrooms
+	}
+	if fields["floor_area"] {
+		patch.FloorArea = &house.FloorArea
+	}
+	if fields["lot_size"] {
+		patch.LotSize = &house.LotSize
+	}
+	if fields["area_unit"] {
+		patch.AreaUnit = &house.AreaUnit
+	}
+	if fields["year_built"] {
+		patch.YearBuilt = &house.YearBuilt
+	}
+	if fields["parking_spaces"] {
+		patch.ParkingSpaces = &house.ParkingSpaces
+	}
+	if fields["floors"] {
+		patch.Floors = &house.Floors
+	}
+	// "address": null clears every part
+	address := &house.Address
+	for _, part := range []struct {
+		field  string
+		value  *string
+		target **string
+	}{
+		{"address.street", &address.Street, &patch.Street},
+		{"address.city", &address.City, &patch.City},
+		{"address.region", &address.Region, &patch.Region},
+		{"address.postal_code", &address.PostalCode, &patch.PostalCode},
+		{"address.country", &address.Country, &patch.Country},
+	} {
+		if fields["address"] || fields[part.field] {
+			*part.target = part.value
+		}
+	}
+	if fields["latitude"] {
+		patch.Latitude = &house.Latitude
+	}
+	if fields["longitude"] {
+		patch.Longitude = &house.Longitude
+	}
+
+	// A new address moves the house unless the patch also says where to
+	if patch.Latitude == nil && (patch.Street != nil || patch.City != nil || patch.Region != nil ||
+		patch.PostalCode != nil || patch.Country != nil) {
+		current, err := h.houseRepo.GetHouseByID(ctx, id)
+		if err != nil {
+			return repository.HousePatch{}, err
+		}
+		moved := *current
+		patch.Apply(&moved)
+		if moved.Address != current.Address {
+			// Coordinates of the old address would be wrong for the new one
+			moved.Latitude, moved.Longitude = nil, nil
+			h.locate(ctx, &moved)
+			patch.Latitude, patch.Longitude = &moved.Latitude, &moved.Longitude
+		}
+	}
+	return patch, nil
+}
 
+func (h *HouseHandler) GetTopHouses(w http.ResponseWriter, r *http.Request) {
 	// Get limit from query parameter, default to 10
 	limitStr := r.URL.Query().Get("limit")
 	limit := 10
@@ -95,133 +405,123 @@ func (h *HouseHandler) GetTopHouses(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	houses, err := h.houseRepo.GetTopHouses(limit)
+	// Prices in different currencies cannot be ranked together
+	currency, err := parseCurrency(r.URL.Query())
 	if err != nil {
-		h.logger.Error("Failed to get top houses", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve top houses")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
 
-	// Enrich houses with agent and house type details
-	var housesWithDetails []HouseWithDetails
-	for _, house := range houses {
-		houseWithDetails := HouseWithDetails{House: house}
-
-		// Get agent details
-		if agent, err := h.agentRepo.GetAgentByID(house.AgentID); err == nil {
-			houseWithDetails.Agent = agent
-		}
-
-		// Get house type details
-		if houseType, err := h.houseTypeRepo.GetHouseTypeByID(house.HouseTypeID); err == nil {
-			houseWithDetails.HouseType = houseType
-		}
-
-		housesWithDetails = append(housesWithDetails, houseWithDetails)
+	houses, err := h.houseRepo.GetTopHousesWithDetails(r.Context(), currency, limit)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "top houses")
+		return
 	}
 
-	h.sendSuccessResponse(w, housesWithDetails, "Top houses retrieved successfully")
+	h.sendSuccessResponse(w, houses, "Top houses retrieved successfully")
 }
 
 func (h *HouseHandler) GetAllHouses(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+	page, limit, filter, err := parseHouseListQuery(r.URL.Query())
+	if err != nil {
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	houses, err := h.houseRepo.GetAllHouses()
+	houses, total, err := h.houseRepo.ListHouses(r.Context(), filter)
 	if err != nil {
-		h.logger.Error("Failed to get all houses", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve houses")
+		h.sendRepositoryError(w, r, err, "retrieve", "houses")
 		return
 	}
 
-	// Enrich houses with agent and house type details
-	var housesWithDetails []HouseWithDetails
-	for _, house := range houses {
-		houseWithDetails := HouseWithDetails{House: house}
-
-		// Get agent details
-		if agent, err := h.agentRepo.GetAgentByID(house.AgentID); err == nil {
-			houseWithDetails.Agent = agent
-		}
-
-		// Get house type details
-		if houseType, err := h.houseTypeRepo.GetHouseTypeByID(house.HouseTypeID); err == nil {
-			houseWithDetails.HouseType = houseType
-		}
-
-		housesWithDetails = append(housesWithDetails, houseWithDetails)
+	if houses == nil {
+		houses = []models.HouseWithDetails{}
 	}
 
-	h.sendSuccessResponse(w, housesWithDetails, "Houses retrieved successfully")
+	h.sendPaginatedResponse(w, houses, page, limit, total)
 }
 
-func (h *HouseHandler) GetHouseByID(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+func (h *HouseHandler) SearchHouses(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := strings.TrimSpace(query.Get("q"))
+	if search == "" {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Search query (q) is required")
 		return
 	}
 
-	// Extract ID from URL path
-	path := r.URL.Path
-	idStr := path[len("/api/houses/"):]
-	id, err := strconv.Atoi(idStr)
+	page, limit, filter, err := parseHouseListQuery(query)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	// Rank by relevance unless the client asked for an explicit order
+	if query.Get("sort") == "" {
+		filter.SortField = ""
+	}
 
-	house, err := h.houseRepo.GetHouseByID(id)
+	matches, total, err := h.houseRepo.SearchHouses(r.Context(), search, filter)
 	if err != nil {
-		h.logger.Error("Failed to get house by ID", err)
-		h.sendErrorResponse(w, http.StatusNotFound, "House not found")
+		h.sendRepositoryError(w, r, err, "search", "houses")
 		return
 	}
 
-	houseWithDetails := HouseWithDetails{House: *house}
-
-	// Get agent details
-	if agent, err := h.agentRepo.GetAgentByID(house.AgentID); err == nil {
-		houseWithDetails.Agent = agent
+	results := []HouseSearchResult{}
+	for _, match := range matches {
+		results = append(results, HouseSearchResult{
+			HouseWithDetails: match.House,
+			Rank:             match.Rank,
+			Snippet:          match.Snippet,
+		})
 	}
 
-	// Get house type details
-	if houseType, err := h.houseTypeRepo.GetHouseTypeByID(house.HouseTypeID); err == nil {
-		houseWithDetails.HouseType = houseType
-	}
-
-	h.sendSuccessResponse(w, houseWithDetails, "House retrieved successfully")
+	h.sendPaginatedResponse(w, results, page, limit, total)
 }
 
-func (h *HouseHandler) CreateHouse(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+func (h *HouseHandler) GetHouseByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
 		return
 	}
 
-	var house models.House
-	if err := json.NewDecoder(r.Body).Decode(&house); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON body")
+	house, err := h.houseRepo.GetHouseWithDetailsByID(r.Context(), id)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "house")
 		return
 	}
 
-	// Basic validation
-	if house.Name == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "House name is required")
+	etag := detailsETag(house)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.sendSuccessResponse(w, house, "House retrieved successfully")
+}
+
+func (h *HouseHandler) CreateHouse(w http.ResponseWriter, r *http.Request) {
+	var house models.House
+	if err := validation.DecodeJSON(r.Body, &house); err != nil {
+		h.sendRequestError(w, r, err, "house")
 		return
 	}
-	if house.Price <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "House price must be greater than 0")
+
+	if err := h.validateHouse(r.Context(), &house); err != nil {
+		h.sendRequestError(w, r, err, "house")
 		return
 	}
+	h.locate(r.Context(), &house)
 
-	if err := h.houseRepo.CreateHouse(&house); err != nil {
-		h.logger.Error("Failed to create house", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to create house")
+	if err := h.houseRepo.CreateHouse(r.Context(), &house); err != nil {
+		h.sendRepositoryError(w, r, err, "create", "house")
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(house.Version))
 	h.sendJSONResponse(w, http.StatusCreated, APIResponse{
 		Success: true,
 		Data:    house,
@@ -229,128 +529,186 @@ func (h *HouseHandler) CreateHouse(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// UpdateHouse replaces a house. Like PatchHouse and DeleteHouse it requires
+// an If-Match header naming the current ETag of the house.
 func (h *HouseHandler) UpdateHouse(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
 		return
 	}
 
-	// Extract ID from URL path
-	path := r.URL.Path
-	idStr := path[len("/api/houses/"):]
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
+	version, ok := h.expectedVersion(w, r, id)
+	if !ok {
 		return
 	}
 
 	var house models.House
-	if err := json.NewDecoder(r.Body).Decode(&house); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON body")
+	if err := validation.DecodeJSON(r.Body, &house); err != nil {
+		h.sendRequestError(w, r, err, "house")
 		return
 	}
 
-	// Basic validation
-	if house.Name == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "House name is required")
-		return
-	}
-	if house.Price <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "House price must be greater than 0")
+	if err := h.validateHouse(r.Context(), &house); err != nil {
+		h.sendRequestError(w, r, err, "house")
 		return
 	}
+	h.locate(r.Context(), &house)
 
 	house.ID = id
-	if err := h.houseRepo.UpdateHouse(&house); err != nil {
-		h.logger.Error("Failed to update house", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to update house")
+	house.Version = version
+	if err := h.houseRepo.UpdateHouse(r.Context(), &house); err != nil {
+		h.sendRepositoryError(w, r, err, "update", "house")
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(house.Version))
 	h.sendSuccessResponse(w, house, "House updated successfully")
 }
 
-func (h *HouseHandler) DeleteHouse(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+// PatchHouse applies a JSON merge patch (RFC 7396) to a house, changing only
+// the fields present in the body. A field set to null is cleared.
+func (h *HouseHandler) PatchHouse(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
+		return
+	}
+
+	version, ok := h.expectedVersion(w, r, id)
+	if !ok {
 		return
 	}
 
-	// Extract ID from URL path
-	path := r.URL.Path
-	idStr := path[len("/api/houses/"):]
-	id, err := strconv.Atoi(idStr)
+	if !isMergePatch(r.Header.Get("Content-Type")) {
+		h.sendErrorResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json")
+		return
+	}
+
+	var house models.House
+	fields, err := validation.DecodeMergePatch(r.Body, &house)
+	if err != nil {
+		h.sendRequestError(w, r, err, "house")
+		return
+	}
+
+	patch, err := h.housePatch(r.Context(), id, &house, fields)
+	if err != nil {
+		h.sendRequestError(w, r, err, "house")
+		return
+	}
+
+	updated, err := h.houseRepo.PatchHouse(r.Context(), id, version, patch)
 	if err != nil {
+		h.sendRepositoryError(w, r, err, "update", "house")
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(updated.Version))
+	h.sendSuccessResponse(w, updated, "House updated successfully")
+}
+
+// expectedVersion returns the version of house id that the If-Match header of
+// r requires a write to match, or 0 for "If-Match: *". If the header is
+// missing or matches no current version, it answers the request and returns
+// false.
+func (h *HouseHandler) expectedVersion(w http.ResponseWriter, r *http.Request, id int) (int, bool) {
+	if r.Header.Get("If-Match") == "" {
+		h.sendErrorResponse(w, http.StatusPreconditionRequired, "If-Match header with the house ETag is required")
+		return 0, false
+	}
+
+	versions, anyVersion := ifMatchVersions(r)
+	switch {
+	case anyVersion:
+		return 0, true
+	case len(versions) == 1:
+		return versions[0], true
+	case len(versions) > 1:
+		// Pick the listed version that is current, if any
+		house, err := h.houseRepo.GetHouseByID(r.Context(), id)
+		if err != nil {
+			h.sendRepositoryError(w, r, err, "retrieve", "house")
+			return 0, false
+		}
+		if slices.Contains(versions, house.Version) {
+			return house.Version, true
+		}
+	}
+
+	h.sendErrorResponse(w, http.StatusPreconditionFailed, "House has been modified since it was retrieved")
+	return 0, false
+}
+
+// isMergePatch reports whether a Content-Type header announces a JSON merge
+// patch. Plain JSON and a missing header are accepted as well.
+func isMergePatch(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/merge-patch+json" || mediaType == "application/json")
+}
+
+func (h *HouseHandler) DeleteHouse(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
 		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
 		return
 	}
 
-	if err := h.houseRepo.DeleteHouse(id); err != nil {
-		h.logger.Error("Failed to delete house", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete house")
+	version, ok := h.expectedVersion(w, r, id)
+	if !ok {
+		return
+	}
+
+	if err := h.houseRepo.DeleteHouse(r.Context(), id, version); err != nil {
+		h.sendRepositoryError(w, r, err, "delete", "house")
 		return
 	}
 
 	h.sendJSONResponse(w, http.StatusOK, APIResponse{
 		Success: true,
-		Message: "House deleted successfully",
+		Message: "House moved to the trash",
 	})
 }
 
-func (h *HouseHandler) GetAgents(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+// GetTrash lists the deleted houses that have not been purged yet, most
+// recently deleted first.
+func (h *HouseHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	agents, err := h.agentRepo.GetAllAgents()
+	houses, total, err := h.houseRepo.ListDeletedHouses(r.Context(), limit, (page-1)*limit)
 	if err != nil {
-		h.logger.Error("Failed to get agents", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve agents")
+		h.sendRepositoryError(w, r, err, "retrieve", "deleted houses")
 		return
 	}
 
-	h.sendSuccessResponse(w, agents, "Agents retrieved successfully")
+	if houses == nil {
+		houses = []models.HouseWithDetails{}
+	}
+
+	h.sendPaginatedResponse(w, houses, page, limit, total)
 }
 
-func (h *HouseHandler) GetHouseTypes(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+// RestoreHouse takes a house out of the trash.
+func (h *HouseHandler) RestoreHouse(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
 		return
 	}
 
-	houseTypes, err := h.houseTypeRepo.GetAllHouseTypes()
+	house, err := h.houseRepo.RestoreHouse(r.Context(), id)
 	if err != nil {
-		h.logger.Error("Failed to get house types", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve house types")
+		h.sendRepositoryError(w, r, err, "restore", "deleted house")
 		return
 	}
 
-	h.sendSuccessResponse(w, houseTypes, "House types retrieved successfully")
-}
-
-func (h *HouseHandler) HandleHousesRoute(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-
-	switch {
-	case path == "/api/houses" && r.Method == http.MethodGet:
-		h.GetAllHouses(w, r)
-	case path == "/api/houses" && r.Method == http.MethodPost:
-		h.CreateHouse(w, r)
-	case path == "/api/houses/top":
-		h.GetTopHouses(w, r)
-	case len(path) > len("/api/houses/") && path[:len("/api/houses/")] == "/api/houses/":
-		switch r.Method {
-		case http.MethodGet:
-			h.GetHouseByID(w, r)
-		case http.MethodPut:
-			h.UpdateHouse(w, r)
-		case http.MethodDelete:
-			h.DeleteHouse(w, r)
-		default:
-			h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
-		}
-	default:
-		h.sendErrorResponse(w, http.StatusNotFound, "Endpoint not found")
-	}
+	w.Header().Set("ETag", versionETag(house.Version))
+	h.sendSuccessResponse(w, house, "House restored successfully")
 }
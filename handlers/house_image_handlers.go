@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/router"
+	"thugcorp.io/nomado/validation"
+)
+
+// Limits of the house_images table columns
+const (
+	maxImageCaptionLength = 500
+	maxImageAltTextLength = 255
+)
+
+var (
+	houseImagePatchFields    = map[string]bool{"url": true, "caption": true, "alt_text": true}
+	houseImageReadOnlyFields = map[string]bool{
+		"id": true, "house_id": true, "position": true, "is_cover": true, "created_at": true, "renditions": true,
+	}
+	// houseImageUploadFields are the form fields sent along an uploaded photo
+	houseImageUploadFields = map[string]bool{"caption": true, "alt_text": true, "is_cover": true}
+)
+
+// HouseImageHandler serves the photo galleries of houses. Changing a gallery
+// increments the version of its house but, unlike the house endpoints, does
+// not require an If-Match header.
+type HouseImageHandler struct {
+	responder
+	imageRepo repository.HouseImageStore
+	uploader  *ImageUploader
+}
+
+// HouseImageRequest is the body of POST /api/houses/{id}/images. The first
+// image of a gallery is always its cover.
+type HouseImageRequest struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption"`
+	AltText string `json:"alt_text"`
+	IsCover bool   `json:"is_cover"`
+}
+
+// HouseImageOrder is the body of PUT /api/houses/{id}/images/order.
+type HouseImageOrder struct {
+	ImageIDs []int `json:"image_ids"`
+}
+
+// NewHouseImageHandler returns the gallery handler. Photos can only be
+// uploaded, rather than linked by URL, if uploader is not nil.
+func NewHouseImageHandler(imageRepo repository.HouseImageStore, uploader *ImageUploader, logger *logger.Logger) *HouseImageHandler {
+	return &HouseImageHandler{
+		imageRepo: imageRepo,
+		uploader:  uploader,
+		responder: responder{logger: logger},
+	}
+}
+
+// checkImage normalises an image in place and records its invalid fields in
+// v. Only the JSON fields in only are checked, or every field if only is nil.
+func checkImage(v *validation.Validator, image *models.HouseImage, only map[string]bool) {
+	check := func(field string) bool {
+		return only == nil || only[field]
+	}
+
+	image.URL = strings.TrimSpace(image.URL)
+	image.Caption = strings.TrimSpace(image.Caption)
+	image.AltText = strings.TrimSpace(image.AltText)
+
+	if check("url") && v.Required("url", image.URL) {
+		v.ImageURL("url", &image.URL)
+	}
+	if check("caption") {
+		v.MaxLength("caption", image.Caption, maxImageCaptionLength)
+	}
+	if check("alt_text") {
+		v.MaxLength("alt_text", image.AltText, maxImageAltTextLength)
+	}
+}
+
+// imagePath returns the {id} and {imageID} path parameters. If either is not
+// a positive integer, it answers the request and returns false.
+func (h *HouseImageHandler) imagePath(w http.ResponseWriter, r *http.Request) (houseID, imageID int, ok bool) {
+	houseID, ok = pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
+		return 0, 0, false
+	}
+	imageID, err := router.PathInt(r, "imageID")
+	if err != nil || imageID < 1 {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid image ID")
+		return 0, 0, false
+	}
+	return houseID, imageID, true
+}
+
+// GetHouseImages lists the gallery of a house in display order.
+func (h *HouseImageHandler) GetHouseImages(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
+		return
+	}
+
+	images, err := h.imageRepo.ListHouseImages(r.Context(), id)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "house")
+		return
+	}
+
+	if images == nil {
+		images = []models.HouseImage{}
+	}
+
+	h.sendSuccessResponse(w, images, "House images retrieved successfully")
+}
+
+func (h *HouseImageHandler) GetHouseImage(w http.ResponseWriter, r *http.Request) {
+	houseID, imageID, ok := h.imagePath(w, r)
+	if !ok {
+		return
+	}
+
+	images, err := h.imageRepo.ListHouseImages(r.Context(), houseID)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "house image")
+		return
+	}
+
+	i := slices.IndexFunc(images, func(image models.HouseImage) bool { return image.ID == imageID })
+	if i < 0 {
+		h.sendErrorResponse(w, http.StatusNotFound, "House image not found")
+		return
+	}
+
+	h.sendSuccessResponse(w, images[i], "House image retrieved successfully")
+}
+
+// AddHouseImage appends an image to the gallery of a house. A JSON body links
+// an image by URL; a multipart/form-data body uploads one, see uploadImage.
+func (h *HouseImageHandler) AddHouseImage(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
+		return
+	}
+
+	if isMultipart(r.Header.Get("Content-Type")) {
+		h.uploadImage(w, r, id)
+		return
+	}
+
+	var req HouseImageRequest
+	if err := validation.DecodeJSON(r.Body, &req); err != nil {
+		h.sendRequestError(w, r, err, "house image")
+		return
+	}
+
+	image := models.HouseImage{HouseID: id, URL: req.URL, Caption: req.Caption, AltText: req.AltText, IsCover: req.IsCover}
+	var v validation.Validator
+	checkImage(&v, &image, nil)
+	if err := v.Err(); err != nil {
+		h.sendRequestError(w, r, err, "house image")
+		return
+	}
+
+	h.addImage(w, r, &image)
+}
+
+// uploadImage adds an uploaded photo, sent as the image field of a form with
+// optional caption, alt_text and is_cover fields. Its renditions are stored
+// and its URL is the large one.
+func (h *HouseImageHandler) uploadImage(w http.ResponseWriter, r *http.Request, houseID int) {
+	up := h.receive(w, r, h.uploader, houseImageUploadFields)
+	if up == nil {
+		return
+	}
+
+	image := models.HouseImage{HouseID: houseID, Caption: up.fields["caption"], AltText: up.fields["alt_text"]}
+	var v validation.Validator
+	image.IsCover = formBool(&v, up.fields, "is_cover")
+	checkImage(&v, &image, houseImageUploadFields)
+	if err := v.Err(); err != nil {
+		h.sendRequestError(w, r, err, "house image")
+		return
+	}
+
+	// Saves storing files for a house that does not exist
+	if _, err := h.imageRepo.ListHouseImages(r.Context(), houseID); err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "house")
+		return
+	}
+	renditions, ok := h.store(w, r, h.uploader, fmt.Sprintf("houses/%d", houseID), up.image)
+	if !ok {
+		return
+	}
+	image.URL, image.Renditions = renditions.Large, &renditions
+
+	if !h.addImage(w, r, &image) {
+		h.discard(r.Context(), h.uploader, &renditions)
+	}
+}
+
+// addImage adds a checked image to its gallery and answers the request. It
+// returns whether the image was added.
+func (h *HouseImageHandler) addImage(w http.ResponseWriter, r *http.Request, image *models.HouseImage) bool {
+	err := h.imageRepo.AddHouseImage(r.Context(), image)
+	if errors.Is(err, repository.ErrValidation) {
+		// The request is valid, so the gallery is full
+		h.sendErrorResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("A house has at most %d images", repository.MaxHouseImages))
+		return false
+	}
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "add", "house image")
+		return false
+	}
+
+	h.sendJSONResponse(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    image,
+		Message: "House image added successfully",
+	})
+	return true
+}
+
+// PatchHouseImage applies a JSON merge patch to the url, caption and alt_text
+// of an image. Positions and the cover have their own endpoints.
+func (h *HouseImageHandler) PatchHouseImage(w http.ResponseWriter, r *http.Request) {
+	houseID, imageID, ok := h.imagePath(w, r)
+	if !ok {
+		return
+	}
+
+	if !isMergePatch(r.Header.Get("Content-Type")) {
+		h.sendErrorResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json")
+		return
+	}
+
+	var image models.HouseImage
+	fields, err := validation.DecodeMergePatch(r.Body, &image)
+	if err != nil {
+		h.sendRequestError(w, r, err, "house image")
+		return
+	}
+
+	var v validation.Validator
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch {
+		case houseImageReadOnlyFields[name]:
+			v.Add(name, validation.CodeReadOnly, "cannot be changed")
+		case !houseImagePatchFields[name]:
+			v.Add(name, validation.CodeUnknownField, "")
+		}
+	}
+	checkImage(&v, &image, fields)
+	if err := v.Err(); err != nil {
+		h.sendRequestError(w, r, err, "house image")
+		return
+	}
+
+	var patch repository.HouseImagePatch
+	if fields["url"] {
+		patch.URL = &image.URL
+	}
+	if fields["caption"] {
+		patch.Caption = &image.Caption
+	}
+	if fields["alt_text"] {
+		patch.AltText = &image.AltText
+	}
+
+	var uploaded *models.Renditions
+	if patch.URL != nil {
+		uploaded = h.storedRenditions(r.Context(), houseID, imageID)
+	}
+
+	updated, err := h.imageRepo.UpdateHouseImage(r.Context(), houseID, imageID, patch)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "update", "house image")
+		return
+	}
+	// A new URL replaces the uploaded photo
+	if updated.Renditions == nil {
+		h.discard(r.Context(), h.uploader, uploaded)
+	}
+
+	h.sendSuccessResponse(w, updated, "House image updated successfully")
+}
+
+// DeleteHouseImage removes an image from its gallery. The images after it move
+// up, and if it was the cover the new first image becomes the cover.
+func (h *HouseImageHandler) DeleteHouseImage(w http.ResponseWriter, r *http.Request) {
+	houseID, imageID, ok := h.imagePath(w, r)
+	if !ok {
+		return
+	}
+
+	uploaded := h.storedRenditions(r.Context(), houseID, imageID)
+	if err := h.imageRepo.DeleteHouseImage(r.Context(), houseID, imageID); err != nil {
+		h.sendRepositoryError(w, r, err, "delete", "house image")
+		return
+	}
+	h.discard(r.Context(), h.uploader, uploaded)
+
+	h.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "House image deleted successfully",
+	})
+}
+
+// storedRenditions returns the renditions of an uploaded image, so that they
+// can be discarded once the image no longer uses them. It returns nil if the
+// image was not uploaded or cannot be found.
+func (h *HouseImageHandler) storedRenditions(ctx context.Context, houseID, imageID int) *models.Renditions {
+	if h.uploader == nil {
+		return nil
+	}
+	images, err := h.imageRepo.ListHouseImages(ctx, houseID)
+	if err != nil {
+		return nil
+	}
+	for _, image := range images {
+		if image.ID == imageID {
+			return image.Renditions
+		}
+	}
+	return nil
+}
+
+// ReorderHouseImages puts the gallery in the order of the image_ids in the
+// body, which must list every image of the house once.
+func (h *HouseImageHandler) ReorderHouseImages(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house ID")
+		return
+	}
+
+	var order HouseImageOrder
+	if err := validation.DecodeJSON(r.Body, &order); err != nil {
+		h.sendRequestError(w, r, err, "house image")
+		return
+	}
+
+	var v validation.Validator
+	sorted := slices.Sorted(slices.Values(order.ImageIDs))
+	switch {
+	case order.ImageIDs == nil:
+		v.Add("image_ids", validation.CodeRequired, "is required")
+	case len(slices.Compact(sorted)) != len(order.ImageIDs):
+		v.Add("image_ids", validation.CodeInvalid, "must not repeat an image")
+	}
+	if err := v.Err(); err != nil {
+		h.sendRequestError(w, r, err, "house image")
+		return
+	}
+
+	images, err := h.imageRepo.ReorderHouseImages(r.Context(), id, order.ImageIDs)
+	if errors.Is(err, repository.ErrValidation) {
+		v.Add("image_ids", validation.CodeInvalid, "must list every image of the house")
+		h.sendRequestError(w, r, v.Err(), "house image")
+		return
+	}
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "reorder the images of", "house")
+		return
+	}
+
+	h.sendSuccessResponse(w, images, "House images reordered successfully")
+}
+
+// SetHouseCover makes an image the cover of its gallery and returns the
+// gallery.
+func (h *HouseImageHandler) SetHouseCover(w http.ResponseWriter, r *http.Request) {
+	houseID, imageID, ok := h.imagePath(w, r)
+	if !ok {
+		return
+	}
+
+	images, err := h.imageRepo.SetHouseCover(r.Context(), houseID, imageID)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "change the cover of", "house image")
+		return
+	}
+
+	h.sendSuccessResponse(w, images, "House cover changed successfully")
+}
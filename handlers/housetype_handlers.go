@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/validation"
+)
+
+// Column size of house_types.name
+const maxHouseTypeNameLength = 100
+
+type HouseTypeHandler struct {
+	responder
+	houseTypeRepo repository.HouseTypeStore
+}
+
+func NewHouseTypeHandler(houseTypeRepo repository.HouseTypeStore, logger *logger.Logger) *HouseTypeHandler {
+	return &HouseTypeHandler{
+		houseTypeRepo: houseTypeRepo,
+		responder:     responder{logger: logger},
+	}
+}
+
+// validateHouseType normalises the house type in place and reports every
+// invalid field.
+func validateHouseType(houseType *models.HouseType) error {
+	houseType.Name = strings.TrimSpace(houseType.Name)
+
+	var v validation.Validator
+	if v.Required("name", houseType.Name) {
+		v.MaxLength("name", houseType.Name, maxHouseTypeNameLength)
+	}
+
+	return v.Err()
+}
+
+// GetHouseTypes lists all house types with the number of houses using each.
+func (h *HouseTypeHandler) GetHouseTypes(w http.ResponseWriter, r *http.Request) {
+	houseTypes, err := h.houseTypeRepo.GetAllHouseTypesWithCounts(r.Context())
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "house types")
+		return
+	}
+
+	h.sendSuccessResponse(w, houseTypes, "House types retrieved successfully")
+}
+
+func (h *HouseTypeHandler) GetHouseTypeByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house type ID")
+		return
+	}
+
+	houseType, err := h.houseTypeRepo.GetHouseTypeWithCountByID(r.Context(), id)
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "house type")
+		return
+	}
+
+	h.sendSuccessResponse(w, houseType, "House type retrieved successfully")
+}
+
+func (h *HouseTypeHandler) CreateHouseType(w http.ResponseWriter, r *http.Request) {
+	var houseType models.HouseType
+	if err := validation.DecodeJSON(r.Body, &houseType); err != nil {
+		h.sendRequestError(w, r, err, "house type")
+		return
+	}
+
+	if err := validateHouseType(&houseType); err != nil {
+		h.sendRequestError(w, r, err, "house type")
+		return
+	}
+
+	if err := h.houseTypeRepo.CreateHouseType(r.Context(), &houseType); err != nil {
+		h.sendRepositoryError(w, r, err, "create", "house type")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    houseType,
+		Message: "House type created successfully",
+	})
+}
+
+func (h *HouseTypeHandler) UpdateHouseType(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house type ID")
+		return
+	}
+
+	var houseType models.HouseType
+	if err := validation.DecodeJSON(r.Body, &houseType); err != nil {
+		h.sendRequestError(w, r, err, "house type")
+		return
+	}
+
+	if err := validateHouseType(&houseType); err != nil {
+		h.sendRequestError(w, r, err, "house type")
+		return
+	}
+
+	houseType.ID = id
+	if err := h.houseTypeRepo.UpdateHouseType(r.Context(), &houseType); err != nil {
+		h.sendRepositoryError(w, r, err, "update", "house type")
+		return
+	}
+
+	h.sendSuccessResponse(w, houseType, "House type updated successfully")
+}
+
+// DeleteHouseType removes a house type. Houses of that type are kept and
+// have their house_type_id cleared.
+func (h *HouseTypeHandler) DeleteHouseType(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid house type ID")
+		return
+	}
+
+	if err := h.houseTypeRepo.DeleteHouseType(r.Context(), id); err != nil {
+		h.sendRepositoryError(w, r, err, "delete", "house type")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "House type deleted successfully",
+	})
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/money"
+	"thugcorp.io/nomado/repository"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+	// maxPage keeps the offset (page-1)*limit far from overflowing an int
+	maxPage = 1_000_000
+)
+
+// Radius of near searches
+const (
+	defaultRadiusKm = 10
+	maxRadiusKm     = math.Pi * repository.EarthRadiusKm // reaches every point on Earth
+)
+
+// parseHouseListQuery converts the query string of GET /api/houses into a
+// repository filter along with the resolved page and page size.
+//
+// Supported parameters: page, limit, sort (price, created_at or name with an
+// optional ":asc"/":desc" suffix), min_price, max_price, currency (required
+// by the price range and the price sort, which only compare prices in one
+// currency), house_type_id,
+// agent_id, tags (comma-separated, normalised like stored tags),
+// tags_match ("all", the default, or "any"), min_/max_ bounds of bedrooms,
+// bathrooms, floor_area, lot_size, year_built, parking_spaces and floors, and
+// area_unit ("sqm", the default, or "sqft") for the area bounds, near
+// ("lat,lng") with radius_km, and bbox ("west,south,east,north"). Houses near
+// a point are ordered by distance unless sort says otherwise.
+func parseHouseListQuery(query url.Values) (int, int, repository.HouseFilter, error) {
+	var filter repository.HouseFilter
+
+	page, limit, err := parsePage(query)
+	if err != nil {
+		return 0, 0, filter, err
+	}
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	// Newest listings first unless the client asks otherwise
+	filter.SortField = "created_at"
+	filter.SortDesc = true
+	if err := parseLocation(query, &filter); err != nil {
+		return 0, 0, filter, err
+	}
+	if filter.Near != nil {
+		filter.SortField = "distance"
+		filter.SortDesc = false
+	}
+	if sort := query.Get("sort"); sort != "" {
+		field, direction, _ := strings.Cut(sort, ":")
+		if _, ok := repository.HouseSortFields[field]; !ok {
+			return 0, 0, filter, fmt.Errorf("invalid sort field %q", field)
+		}
+		filter.SortField = field
+		switch strings.ToLower(direction) {
+		case "", "asc":
+			filter.SortDesc = false
+		case "desc":
+			filter.SortDesc = true
+		default:
+			return 0, 0, filter, fmt.Errorf("invalid sort direction %q", direction)
+		}
+		if field == "distance" && filter.Near == nil {
+			return 0, 0, filter, fmt.Errorf("sorting by distance requires near")
+		}
+	}
+
+	if filter.MinPrice, err = parseOptionalAmount(query, "min_price"); err != nil {
+		return 0, 0, filter, err
+	}
+	if filter.MaxPrice, err = parseOptionalAmount(query, "max_price"); err != nil {
+		return 0, 0, filter, err
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return 0, 0, filter, fmt.Errorf("min_price must not exceed max_price")
+	}
+	if filter.Currency, err = parseCurrency(query); err != nil {
+		return 0, 0, filter, err
+	}
+	if filter.Currency == "" && (filter.MinPrice != nil || filter.MaxPrice != nil || filter.SortField == "price") {
+		return 0, 0, filter, fmt.Errorf("min_price, max_price and sorting by price require currency")
+	}
+	if filter.HouseTypeID, err = parseOptionalInt(query, "house_type_id"); err != nil {
+		return 0, 0, filter, err
+	}
+	if filter.AgentID, err = parseOptionalInt(query, "agent_id"); err != nil {
+		return 0, 0, filter, err
+	}
+
+	if err := parseAttributeRanges(query, &filter); err != nil {
+		return 0, 0, filter, err
+	}
+
+	if tags := query.Get("tags"); tags != "" {
+		filter.Tags = repository.NormalizeTags(strings.Split(tags, ","))
+	}
+	switch query.Get("tags_match") {
+	case "", "all":
+	case "any":
+		filter.AnyTag = true
+	default:
+		return 0, 0, filter, fmt.Errorf("tags_match must be all or any")
+	}
+
+	return page, limit, filter, nil
+}
+
+// parseCurrency returns the upper-cased currency parameter, or "" if it is
+// missing.
+func parseCurrency(query url.Values) (string, error) {
+	currency := strings.ToUpper(strings.TrimSpace(query.Get("currency")))
+	if currency != "" && !money.ValidCurrency(currency) {
+		return "", fmt.Errorf("currency must be a three-letter ISO 4217 currency code")
+	}
+	return currency, nil
+}
+
+// parseAttributeRanges sets the attribute ranges of filter from their min_
+// and max_ parameters. Area bounds are converted to square metres.
+func parseAttributeRanges(query url.Values, filter *repository.HouseFilter) error {
+	var err error
+	if filter.Bedrooms, err = parseRange(query, "bedrooms", strconv.Atoi); err != nil {
+		return err
+	}
+	if filter.Bathrooms, err = parseRange(query, "bathrooms", parseFloat); err != nil {
+		return err
+	}
+	if filter.FloorArea, err = parseRange(query, "floor_area", parseFloat); err != nil {
+		return err
+	}
+	if filter.LotSize, err = parseRange(query, "lot_size", parseFloat); err != nil {
+		return err
+	}
+	if filter.YearBuilt, err = parseRange(query, "year_built", strconv.Atoi); err != nil {
+		return err
+	}
+	if filter.ParkingSpaces, err = parseRange(query, "parking_spaces", strconv.Atoi); err != nil {
+		return err
+	}
+	if filter.Floors, err = parseRange(query, "floors", strconv.Atoi); err != nil {
+		return err
+	}
+
+	unit := strings.ToLower(query.Get("area_unit"))
+	switch unit {
+	case "":
+	case models.AreaUnitSquareMetres, models.AreaUnitSquareFeet:
+		for _, bound := range []*float64{filter.FloorArea.Min, filter.FloorArea.Max, filter.LotSize.Min, filter.LotSize.Max} {
+			if bound != nil {
+				*bound = repository.SquareMetres(*bound, unit)
+			}
+		}
+	default:
+		return fmt.Errorf("area_unit must be sqm or sqft")
+	}
+	return nil
+}
+
+// parseRange parses the min_<key> and max_<key> bounds of an attribute.
+func parseRange[T int | float64](query url.Values, key string, parse func(string) (T, error)) (repository.Range[T], error) {
+	var r repository.Range[T]
+	for _, bound := range []struct {
+		name  string
+		value **T
+	}{{"min_" + key, &r.Min}, {"max_" + key, &r.Max}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := parse(value)
+		if err != nil {
+			return r, fmt.Errorf("%s must be a number", bound.name)
+		}
+		*bound.value = &parsed
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return r, fmt.Errorf("min_%s must not exceed max_%s", key, key)
+	}
+	return r, nil
+}
+
+// parseFloat parses a finite decimal number.
+func parseFloat(value string) (float64, error) {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err == nil && (math.IsNaN(parsed) || math.IsInf(parsed, 0)) {
+		err = fmt.Errorf("%q is not finite", value)
+	}
+	return parsed, err
+}
+
+// parseLocation sets the near, radius and bounding box filters.
+func parseLocation(query url.Values, filter *repository.HouseFilter) error {
+	if value := query.Get("near"); value != "" {
+		coords, err := parseCoordinates(value, 2)
+		if err != nil || !repository.ValidCoordinates(coords[0], coords[1]) {
+			return fmt.Errorf("near must be a latitude and a longitude such as 30.27,-97.74")
+		}
+		filter.Near = &repository.Point{Lat: coords[0], Lng: coords[1]}
+		filter.RadiusKm = defaultRadiusKm
+	}
+	if value := query.Get("radius_km"); value != "" {
+		if filter.Near == nil {
+			return fmt.Errorf("radius_km requires near")
+		}
+		radius, err := parseFloat(value)
+		if err != nil || radius <= 0 || radius > maxRadiusKm {
+			return fmt.Errorf("radius_km must be a positive number of at most %.0f", maxRadiusKm)
+		}
+		filter.RadiusKm = radius
+	}
+
+	if value := query.Get("bbox"); value != "" {
+		// West may exceed east for a box that crosses the antimeridian
+		coords, err := parseCoordinates(value, 4)
+		if err != nil || !repository.ValidCoordinates(coords[1], coords[0]) ||
+			!repository.ValidCoordinates(coords[3], coords[2]) || coords[1] > coords[3] {
+			return fmt.Errorf("bbox must be west,south,east,north in degrees with south at most north")
+		}
+		filter.BBox = &repository.BoundingBox{West: coords[0], South: coords[1], East: coords[2], North: coords[3]}
+	}
+	return nil
+}
+
+// parseCoordinates parses n comma-separated decimal degrees.
+func parseCoordinates(value string, n int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d numbers, got %d", n, len(parts))
+	}
+	coords := make([]float64, n)
+	for i, part := range parts {
+		var err error
+		if coords[i], err = parseFloat(strings.TrimSpace(part)); err != nil {
+			return nil, err
+		}
+	}
+	return coords, nil
+}
+
+// parsePage returns the page and limit parameters of a paginated endpoint,
+// capping the limit at maxPageLimit. Pages beyond maxPage are rejected.
+func parsePage(query url.Values) (page, limit int, err error) {
+	if page, err = parsePositiveInt(query, "page", 1); err != nil {
+		return 0, 0, err
+	}
+	if page > maxPage {
+		return 0, 0, fmt.Errorf("page must be at most %d", maxPage)
+	}
+	if limit, err = parsePositiveInt(query, "limit", defaultPageLimit); err != nil {
+		return 0, 0, err
+	}
+	return page, min(limit, maxPageLimit), nil
+}
+
+func parsePositiveInt(query url.Values, key string, defaultValue int) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", key)
+	}
+	return parsed, nil
+}
+
+func parseOptionalInt(query url.Values, key string) (*int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", key)
+	}
+	return &parsed, nil
+}
+
+// parseOptionalAmount parses a non-negative amount of money with at most two
+// decimals.
+func parseOptionalAmount(query url.Values, key string) (*money.Amount, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := money.Parse(value)
+	if err != nil || parsed < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative amount with at most two decimals", key)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/middleware"
+	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/validation"
+)
+
+// Response structures for API responses
+type APIResponse struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Message string      `json:"message,omitempty"`
+	// Errors lists the invalid fields of a rejected request body
+	Errors validation.Errors `json:"errors,omitempty"`
+	// RequestID is set on error responses so that users can quote it to support
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type PaginatedResponse struct {
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type Pagination struct {
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// responder provides the JSON response helpers shared by all handlers.
+type responder struct {
+	logger *logger.Logger
+}
+
+// Helper methods for consistent API responses
+func (rs responder) sendSuccessResponse(w http.ResponseWriter, data interface{}, message string) {
+	response := APIResponse{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+	rs.sendJSONResponse(w, http.StatusOK, response)
+}
+
+func (rs responder) sendErrorResponse(w http.ResponseWriter, statusCode int, errorMsg string) {
+	response := APIResponse{
+		Success:   false,
+		Error:     errorMsg,
+		RequestID: w.Header().Get(middleware.RequestIDHeader),
+	}
+	rs.sendJSONResponse(w, statusCode, response)
+}
+
+// sendRepositoryError answers a failed repository call on resource, such as
+// "house" or "house types", with a status matching the error:
+//
+//   - 404 for repository.ErrNotFound
+//   - 409 for repository.ErrConflict, naming the conflicting column if known
+//   - 412 for repository.ErrVersionMismatch
+//   - 422 for repository.ErrForeignKey and repository.ErrValidation
+//   - 504 when the request deadline passed and 503 when the client went away
+//   - 500 for anything else, which is logged as "Failed to <action> <resource>"
+func (rs responder) sendRepositoryError(w http.ResponseWriter, r *http.Request, err error, action, resource string) {
+	// The driver may report a cancelled query as its own error
+	cause := err
+	if ctxErr := r.Context().Err(); ctxErr != nil {
+		cause = ctxErr
+	}
+
+	name := strings.ToUpper(resource[:1]) + resource[1:]
+	switch {
+	case errors.Is(cause, context.DeadlineExceeded):
+		rs.logger.WarnContext(r.Context(), "Request timed out", "error", err)
+		rs.sendErrorResponse(w, http.StatusGatewayTimeout, "Request timed out")
+	case errors.Is(cause, context.Canceled):
+		rs.logger.InfoContext(r.Context(), "Request cancelled by client", "error", err)
+		rs.sendErrorResponse(w, http.StatusServiceUnavailable, "Request cancelled")
+	case errors.Is(err, repository.ErrNotFound):
+		rs.sendErrorResponse(w, http.StatusNotFound, name+" not found")
+	case errors.Is(err, repository.ErrVersionMismatch):
+		rs.sendErrorResponse(w, http.StatusPreconditionFailed, name+" has been modified since it was retrieved")
+	case errors.Is(err, repository.ErrConflict):
+		message := name + " conflicts with an existing record"
+		if column := repository.ErrorColumn(err); column != "" {
+			message = "A " + resource + " with this " + strings.ReplaceAll(column, "_", " ") + " already exists"
+		}
+		rs.sendErrorResponse(w, http.StatusConflict, message)
+	case errors.Is(err, repository.ErrForeignKey):
+		rs.sendErrorResponse(w, http.StatusUnprocessableEntity, name+" references a record that does not exist")
+	case errors.Is(err, repository.ErrValidation):
+		rs.sendErrorResponse(w, http.StatusUnprocessableEntity, name+" has a value that is too long or out of range")
+	default:
+		message := "Failed to " + action + " " + resource
+		rs.logger.ErrorContext(r.Context(), message, err)
+		rs.sendErrorResponse(w, http.StatusInternalServerError, message)
+	}
+}
+
+// sendRequestError answers a request body that failed to decode or validate:
+// 422 with the invalid fields, 400 for malformed JSON. Other errors come from
+// repository lookups made during validation.
+func (rs responder) sendRequestError(w http.ResponseWriter, r *http.Request, err error, resource string) {
+	var fieldErrs validation.Errors
+	switch {
+	case errors.As(err, &fieldErrs):
+		rs.sendJSONResponse(w, http.StatusUnprocessableEntity, APIResponse{
+			Success:   false,
+			Error:     "Validation failed",
+			Errors:    fieldErrs,
+			RequestID: w.Header().Get(middleware.RequestIDHeader),
+		})
+	case errors.Is(err, validation.ErrMalformedJSON):
+		rs.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON body")
+	default:
+		rs.sendRepositoryError(w, r, err, "validate", resource)
+	}
+}
+
+func (rs responder) sendPaginatedResponse(w http.ResponseWriter, data interface{}, page, limit, total int) {
+	response := PaginatedResponse{
+		Success: true,
+		Data:    data,
+		Pagination: Pagination{
+			Page:       page,
+			Limit:      limit,
+			Total:      total,
+			TotalPages: (total + limit - 1) / limit,
+		},
+	}
+	rs.sendJSONResponse(w, http.StatusOK, response)
+}
+
+func (rs responder) sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		rs.logger.Error("Failed to encode JSON response", err)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/router"
+	"thugcorp.io/nomado/storage"
+)
+
+// NewRouter registers every API route and returns the resulting handler.
+// Unknown paths and unsupported methods get JSON error bodies, the latter
+// with an Allow header.
+//
+// files, if not nil, serves the uploaded images kept on the local filesystem
+// under /images/.
+func NewRouter(logger *logger.Logger, houses *HouseHandler, houseImages *HouseImageHandler, agents *AgentHandler, houseTypes *HouseTypeHandler, tags *TagHandler, geocode *GeocodeHandler, files http.Handler) http.Handler {
+	rs := responder{logger: logger}
+	rt := router.New()
+	rt.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs.sendErrorResponse(w, http.StatusNotFound, "Endpoint not found")
+	})
+	rt.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+	})
+
+	rt.HandleFunc(http.MethodGet, "/api", apiInfo)
+	rt.HandleFunc(http.MethodGet, "/api/health", healthCheck)
+
+	rt.HandleFunc(http.MethodGet, "/api/houses", houses.GetAllHouses)
+	rt.HandleFunc(http.MethodPost, "/api/houses", houses.CreateHouse)
+	rt.HandleFunc(http.MethodGet, "/api/houses/top", houses.GetTopHouses)
+	rt.HandleFunc(http.MethodGet, "/api/houses/search", houses.SearchHouses)
+	rt.HandleFunc(http.MethodGet, "/api/houses/trash", houses.GetTrash)
+	rt.HandleFunc(http.MethodGet, "/api/houses/{id}", houses.GetHouseByID)
+	rt.HandleFunc(http.MethodPut, "/api/houses/{id}", houses.UpdateHouse)
+	rt.HandleFunc(http.MethodPatch, "/api/houses/{id}", houses.PatchHouse)
+	rt.HandleFunc(http.MethodDelete, "/api/houses/{id}", houses.DeleteHouse)
+	rt.HandleFunc(http.MethodPost, "/api/houses/{id}/restore", houses.RestoreHouse)
+
+	rt.HandleFunc(http.MethodGet, "/api/houses/{id}/images", houseImages.GetHouseImages)
+	rt.HandleFunc(http.MethodPost, "/api/houses/{id}/images", houseImages.AddHouseImage)
+	rt.HandleFunc(http.MethodPut, "/api/houses/{id}/images/order", houseImages.ReorderHouseImages)
+	rt.HandleFunc(http.MethodGet, "/api/houses/{id}/images/{imageID}", houseImages.GetHouseImage)
+	rt.HandleFunc(http.MethodPatch, "/api/houses/{id}/images/{imageID}", houseImages.PatchHouseImage)
+	rt.HandleFunc(http.MethodDelete, "/api/houses/{id}/images/{imageID}", houseImages.DeleteHouseImage)
+	rt.HandleFunc(http.MethodPut, "/api/houses/{id}/images/{imageID}/cover", houseImages.SetHouseCover)
+
+	rt.HandleFunc(http.MethodGet, "/api/agents", agents.GetAgents)
+	rt.HandleFunc(http.MethodPost, "/api/agents", agents.CreateAgent)
+	rt.HandleFunc(http.MethodGet, "/api/agents/{id}", agents.GetAgentByID)
+	rt.HandleFunc(http.MethodPut, "/api/agents/{id}", agents.UpdateAgent)
+	rt.HandleFunc(http.MethodDelete, "/api/agents/{id}", agents.DeleteAgent)
+	rt.HandleFunc(http.MethodPut, "/api/agents/{id}/image", agents.UploadAgentImage)
+	rt.HandleFunc(http.MethodGet, "/api/agents/{id}/houses", agents.GetAgentHouses)
+
+	rt.HandleFunc(http.MethodGet, "/api/house-types", houseTypes.GetHouseTypes)
+	rt.HandleFunc(http.MethodPost, "/api/house-types", houseTypes.CreateHouseType)
+	rt.HandleFunc(http.MethodGet, "/api/house-types/{id}", houseTypes.GetHouseTypeByID)
+	rt.HandleFunc(http.MethodPut, "/api/house-types/{id}", houseTypes.UpdateHouseType)
+	rt.HandleFunc(http.MethodDelete, "/api/house-types/{id}", houseTypes.DeleteHouseType)
+
+	rt.HandleFunc(http.MethodGet, "/api/tags", tags.GetTags)
+
+	rt.HandleFunc(http.MethodGet, "/api/geocode", geocode.Geocode)
+	rt.HandleFunc(http.MethodGet, "/api/geocode/reverse", geocode.ReverseGeocode)
+
+	if files != nil {
+		rt.Handle(http.MethodGet, storage.LocalURLPrefix+"/", files)
+	}
+
+	return rt.Handler()
+}
+
+// pathID returns the positive integer {id} path parameter.
+func pathID(r *http.Request) (int, bool) {
+	id, err := router.PathInt(r, "id")
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+// Health check endpoint
+func healthCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"healthy","service":"nomado-api"}`))
+}
+
+// API info endpoint
+func apiInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	apiInfo := `{
+			"service": "Nomado Real Estate API",
+			"version": "1.0.0",
+			"endpoints": {
+				"houses": "/api/houses",
+				"top_houses": "/api/houses/top",
+				"search_houses": "/api/houses/search?q={query}",
+				"house_detail": "/api/houses/{id}",
+				"house_trash": "/api/houses/trash",
+				"restore_house": "/api/houses/{id}/restore",
+				"house_images": "/api/houses/{id}/images",
+				"agents": "/api/agents",
+				"agent_detail": "/api/agents/{id}",
+				"agent_image": "/api/agents/{id}/image",
+				"agent_houses": "/api/agents/{id}/houses",
+				"house_types": "/api/house-types",
+				"house_type_detail": "/api/house-types/{id}",
+				"tags": "/api/tags",
+				"geocode": "/api/geocode?q={address}",
+				"reverse_geocode": "/api/geocode/reverse?lat={lat}&lng={lng}",
+				"health": "/api/health"
+			}
+		}`
+	w.Write([]byte(apiInfo))
+}
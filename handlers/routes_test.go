@@ -0,0 +1,992 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/middleware"
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/money"
+	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/repository/memory"
+	"thugcorp.io/nomado/storage"
+	"thugcorp.io/nomado/validation"
+)
+
+// testPlaces is the gazetteer of the test router.
+var testPlaces = []models.Place{
+	{Name: "Austin", Region: "TX", Country: "US", Latitude: 30.2672, Longitude: -97.7431, Population: 961855},
+	{Name: "Round Rock", Region: "TX", Country: "US", Latitude: 30.5083, Longitude: -97.6789, Population: 119468},
+	{Name: "Austin", Region: "MN", Country: "US", Latitude: 43.6666, Longitude: -92.9746, Population: 26174},
+	{Name: "Austin", Region: "TX", Country: "US", PostalCode: "78701", Latitude: 30.2713, Longitude: -97.7426},
+}
+
+// testMaxUpload is the upload size limit of the test router.
+const testMaxUpload = 1 << 20
+
+// newTestRouter serves the API from an in-memory store holding one agent,
+// one house type, testPlaces and the given houses. Uploads are kept in a
+// temporary directory.
+func newTestRouter(t *testing.T, houses ...models.House) http.Handler {
+	t.Helper()
+
+	log, err := logger.New(logger.Config{Outputs: []string{os.DevNull}})
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	t.Cleanup(log.Close)
+
+	ctx := t.Context()
+	store := memory.New()
+	if err := store.CreateAgent(ctx, &models.Agent{FirstName: "Jane", LastName: "Doe"}); err != nil {
+		t.Fatalf("CreateAgent: %v", err)
+	}
+	if err := store.CreateHouseType(ctx, &models.HouseType{Name: "Villa"}); err != nil {
+		t.Fatalf("CreateHouseType: %v", err)
+	}
+	places := func(yield func(models.Place, error) bool) {
+		for _, place := range testPlaces {
+			if !yield(place, nil) {
+				return
+			}
+		}
+	}
+	if _, err := store.ImportPlaces(ctx, places, false); err != nil {
+		t.Fatalf("ImportPlaces: %v", err)
+	}
+	for _, house := range houses {
+		house.AgentID, house.HouseTypeID = 1, 1
+		if err := store.CreateHouse(ctx, &house); err != nil {
+			t.Fatalf("CreateHouse: %v", err)
+		}
+	}
+
+	files, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	uploader := NewImageUploader(files, testMaxUpload)
+
+	return NewRouter(log,
+		NewHouseHandler(store, store, store, store, log),
+		NewHouseImageHandler(store, uploader, log),
+		NewAgentHandler(store, store, uploader, log),
+		NewHouseTypeHandler(store, log),
+		NewTagHandler(store, log),
+		NewGeocodeHandler(store, log),
+		files.Handler(),
+	)
+}
+
+// serve sends a request with the given header name/value pairs to h and
+// decodes the JSON response body into v.
+func serve(t *testing.T, h http.Handler, method, target, body string, v interface{}, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q: %v", method, target, rec.Body.String(), err)
+		}
+	}
+	return rec
+}
+
+func TestListHousesPagination(t *testing.T) {
+	h := newTestRouter(t,
+		models.House{Name: "Cheap", Price: 100 * money.Unit},
+		models.House{Name: "Middle", Price: 200 * money.Unit},
+		models.House{Name: "Dear", Price: 300 * money.Unit},
+	)
+
+	var resp struct {
+		PaginatedResponse
+		Data []models.HouseWithDetails `json:"data"`
+	}
+	rec := serve(t, h, http.MethodGet, "/api/houses?sort=price:desc&currency=USD&limit=2&page=2", "", &resp)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if resp.Pagination != (Pagination{Page: 2, Limit: 2, Total: 3, TotalPages: 2}) {
+		t.Errorf("pagination = %+v", resp.Pagination)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Name != "Cheap" || resp.Data[0].Agent == nil {
+		t.Errorf("data = %+v, want the cheapest house with its agent", resp.Data)
+	}
+
+	rec = serve(t, h, http.MethodGet, "/api/houses?min_price=199.99&max_price=200&currency=usd", "", &resp)
+	if rec.Code != http.StatusOK || len(resp.Data) != 1 || resp.Data[0].Name != "Middle" {
+		t.Errorf("price range: status = %d, data = %+v", rec.Code, resp.Data)
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses?min_price=1.005&currency=USD", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("fraction of a cent: status = %d, want 400", rec.Code)
+	}
+
+	// Prices are only compared within one currency
+	for _, query := range []string{"min_price=100", "sort=price", "min_price=100&currency=dollars"} {
+		if rec := serve(t, h, http.MethodGet, "/api/houses?"+query, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses?min_price=100&currency=JPY", "", &resp)
+	if rec.Code != http.StatusOK || len(resp.Data) != 0 {
+		t.Errorf("price range in yen: status = %d, data = %+v", rec.Code, resp.Data)
+	}
+
+	// A page far enough out would overflow the offset
+	rec = serve(t, h, http.MethodGet, "/api/houses?page=92233720368547758", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("huge page: status = %d, want 400", rec.Code)
+	}
+
+	rec = serve(t, h, http.MethodGet, "/api/houses?sort=colour", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown sort field: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestTags(t *testing.T) {
+	h := newTestRouter(t,
+		models.House{Name: "Beach", Price: 100 * money.Unit, Tags: []string{"Ocean Views", "pool"}},
+		models.House{Name: "Garden", Price: 200 * money.Unit, Tags: []string{"garden", "Pool"}},
+	)
+
+	var tags struct {
+		Data []models.TagCount `json:"data"`
+	}
+	rec := serve(t, h, http.MethodGet, "/api/tags", "", &tags)
+	want := []models.TagCount{{Tag: "pool", HouseCount: 2}, {Tag: "garden", HouseCount: 1}, {Tag: "ocean-views", HouseCount: 1}}
+	if rec.Code != http.StatusOK || !slices.Equal(tags.Data, want) {
+		t.Errorf("tags: status = %d, data = %+v, want %+v", rec.Code, tags.Data, want)
+	}
+
+	var houses struct {
+		Data []models.HouseWithDetails `json:"data"`
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses?tags=Ocean+Views,garden&tags_match=any&sort=price&currency=USD", "", &houses)
+	if rec.Code != http.StatusOK || len(houses.Data) != 2 {
+		t.Errorf("any tag: status = %d, data = %+v", rec.Code, houses.Data)
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses?tags=Ocean+Views,garden", "", &houses)
+	if rec.Code != http.StatusOK || len(houses.Data) != 0 {
+		t.Errorf("all tags: status = %d, data = %+v", rec.Code, houses.Data)
+	}
+	if rec := serve(t, h, http.MethodGet, "/api/houses?tags_match=some", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid tags_match: status = %d, want 400", rec.Code)
+	}
+
+	var created struct {
+		Data models.House `json:"data"`
+	}
+	body := `{"name":"Loft","price":300,"house_type_id":1,"agent_id":1,"tags":["Roof Terrace","roof-terrace"]}`
+	rec = serve(t, h, http.MethodPost, "/api/houses", body, &created)
+	if rec.Code != http.StatusCreated || !slices.Equal(created.Data.Tags, []string{"roof-terrace"}) {
+		t.Errorf("create with tags: status = %d, tags = %q", rec.Code, created.Data.Tags)
+	}
+	var resp APIResponse
+	body = `{"name":"Loft","price":300,"house_type_id":1,"agent_id":1,"tags":["!!"]}`
+	rec = serve(t, h, http.MethodPost, "/api/houses", body, &resp)
+	if rec.Code != http.StatusUnprocessableEntity || len(resp.Errors) != 1 || resp.Errors[0].Code != validation.CodeInvalid {
+		t.Errorf("create with a blank tag: status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAttributeFilters(t *testing.T) {
+	three, four, six := 3, 4, 6
+	floorArea := 1500.0
+	h := newTestRouter(t,
+		models.House{Name: "Flat", Price: 250_000 * money.Unit, Bedrooms: &three},
+		models.House{Name: "Villa", Price: 450_000 * money.Unit, Bedrooms: &four, FloorArea: &floorArea, AreaUnit: models.AreaUnitSquareFeet},
+		models.House{Name: "Estate", Price: 900_000 * money.Unit, Bedrooms: &six},
+		models.House{Name: "Plot", Price: 50_000 * money.Unit},
+	)
+
+	var resp struct {
+		Data []models.HouseWithDetails `json:"data"`
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"min_bedrooms=3&max_price=500000", []string{"Flat", "Villa"}},
+		{"min_bedrooms=4&max_bedrooms=4", []string{"Villa"}},
+		{"min_floor_area=1400&area_unit=sqft", []string{"Villa"}},
+		{"min_floor_area=140&max_floor_area=140", nil},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, "/api/houses?sort=price&currency=USD&"+tt.query, "", &resp)
+		var names []string
+		for _, house := range resp.Data {
+			names = append(names, house.Name)
+		}
+		if rec.Code != http.StatusOK || !slices.Equal(names, tt.want) {
+			t.Errorf("%s: status = %d, houses = %q, want %q", tt.query, rec.Code, names, tt.want)
+		}
+	}
+
+	for _, query := range []string{"min_bedrooms=many", "min_bedrooms=4&max_bedrooms=3", "min_bathrooms=NaN", "area_unit=acre"} {
+		if rec := serve(t, h, http.MethodGet, "/api/houses?"+query, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestLocationFilters(t *testing.T) {
+	at := func(name string, lat, lng float64) models.House {
+		return models.House{Name: name, Price: 1000 * money.Unit, Latitude: &lat, Longitude: &lng}
+	}
+	h := newTestRouter(t,
+		at("Capitol", 30.2747, -97.7404),
+		at("Zilker", 30.2670, -97.7729),
+		at("Round Rock", 30.5083, -97.6789),
+		models.House{Name: "Unmapped", Price: 1000 * money.Unit},
+	)
+
+	var resp struct {
+		Data []models.HouseWithDetails `json:"data"`
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"near=30.2670,-97.7729", []string{"Zilker", "Capitol"}},
+		{"near=30.2670,-97.7729&radius_km=50", []string{"Zilker", "Capitol", "Round Rock"}},
+		{"near=30.2670,-97.7729&radius_km=50&sort=distance:desc", []string{"Round Rock", "Capitol", "Zilker"}},
+		{"near=30.2670,-97.7729&radius_km=50&sort=name", []string{"Capitol", "Round Rock", "Zilker"}},
+		{"bbox=-97.8,30.2,-97.7,30.3&sort=name", []string{"Capitol", "Zilker"}},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, "/api/houses?"+tt.query, "", &resp)
+		var names []string
+		for _, house := range resp.Data {
+			names = append(names, house.Name)
+		}
+		if rec.Code != http.StatusOK || !slices.Equal(names, tt.want) {
+			t.Errorf("%s: status = %d, houses = %q, want %q", tt.query, rec.Code, names, tt.want)
+		}
+	}
+	serve(t, h, http.MethodGet, "/api/houses?near=30.2670,-97.7729", "", &resp)
+	if len(resp.Data) == 0 || resp.Data[0].DistanceKm == nil || *resp.Data[0].DistanceKm != 0 {
+		t.Errorf("near: data = %+v, want the distance of each house", resp.Data)
+	}
+
+	for _, query := range []string{
+		"near=30.27", "near=91,0", "near=north,south", "radius_km=5", "near=30,-97&radius_km=0",
+		"bbox=-97.8,30.3,-97.7,30.2", "bbox=-97.8,30.2,-97.7", "sort=distance",
+	} {
+		if rec := serve(t, h, http.MethodGet, "/api/houses?"+query, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestGeocode(t *testing.T) {
+	h := newTestRouter(t)
+
+	var resp struct {
+		Data []models.Place `json:"data"`
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"q=Austin", []string{"Austin TX", "Austin MN", "Austin TX"}},
+		{"q=austin,+mn", []string{"Austin MN", "Austin TX", "Austin TX"}},
+		{"q=78701", []string{"Austin TX"}},
+		{"q=Round&limit=1", []string{"Round Rock TX"}},
+		{"q=Dallas", nil},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, "/api/geocode?"+tt.query, "", &resp)
+		var names []string
+		for _, place := range resp.Data {
+			names = append(names, place.Name+" "+place.Region)
+		}
+		if rec.Code != http.StatusOK || resp.Data == nil || !slices.Equal(names, tt.want) {
+			t.Errorf("%s: status = %d, places = %q, want %q", tt.query, rec.Code, names, tt.want)
+		}
+	}
+
+	rec := serve(t, h, http.MethodGet, "/api/geocode/reverse?lat=30.5&lng=-97.68&limit=2", "", &resp)
+	if rec.Code != http.StatusOK || len(resp.Data) != 2 || resp.Data[0].Name != "Round Rock" ||
+		resp.Data[0].DistanceKm == nil || *resp.Data[0].DistanceKm > 1 || *resp.Data[1].DistanceKm < 25 {
+		t.Errorf("reverse: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	for _, query := range []string{
+		"/api/geocode", "/api/geocode?q=+", "/api/geocode?q=Austin&limit=0",
+		"/api/geocode/reverse?lat=30", "/api/geocode/reverse?lat=91&lng=0", "/api/geocode/reverse?lat=x&lng=y",
+	} {
+		if rec := serve(t, h, http.MethodGet, query, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestGeocodeHouses(t *testing.T) {
+	h := newTestRouter(t)
+
+	var created struct {
+		Data models.House `json:"data"`
+	}
+	tests := []struct {
+		address  string
+		lat, lng *float64
+	}{
+		{`{"city":"austin","region":"TX"}`, ptr(30.2672), ptr(-97.7431)},
+		{`{"postal_code":"78701"}`, ptr(30.2713), ptr(-97.7426)},
+		// Only a prefix of Austin, and in another country
+		{`{"city":"Aus"}`, nil, nil},
+		{`{"city":"Austin","country":"GB"}`, nil, nil},
+		{`{"street":"1 Main St"}`, nil, nil},
+	}
+	for _, tt := range tests {
+		body := `{"name":"Home","price":1000,"house_type_id":1,"agent_id":1,"address":` + tt.address + `}`
+		rec := serve(t, h, http.MethodPost, "/api/houses", body, &created)
+		got := created.Data
+		if rec.Code != http.StatusCreated || !equalPtr(got.Latitude, tt.lat) || !equalPtr(got.Longitude, tt.lng) {
+			t.Errorf("create at %s: status = %d, coordinates = %v, %v", tt.address, rec.Code, got.Latitude, got.Longitude)
+		}
+	}
+
+	// Given coordinates are kept
+	body := `{"name":"Home","price":1000,"house_type_id":1,"agent_id":1,"address":{"city":"Austin"},"latitude":30.3,"longitude":-97.8}`
+	rec := serve(t, h, http.MethodPut, "/api/houses/1", body, &created, "If-Match", "*")
+	if got := created.Data; rec.Code != http.StatusOK || !equalPtr(got.Latitude, ptr(30.3)) || !equalPtr(got.Longitude, ptr(-97.8)) {
+		t.Errorf("update with coordinates: status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
+
+// ptr returns a pointer to v.
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// equalPtr reports whether a and b are both nil or point to equal values.
+func equalPtr[T comparable](a, b *T) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func TestHouseLifecycle(t *testing.T) {
+	h := newTestRouter(t)
+
+	var created struct {
+		Data models.House `json:"data"`
+	}
+	body := `{"name":"Sea View","price":250000,"house_type_id":1,"agent_id":1,"tags":["beach"]}`
+	rec := serve(t, h, http.MethodPost, "/api/houses", body, &created)
+	if rec.Code != http.StatusCreated || created.Data.ID == 0 {
+		t.Fatalf("create: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	var got struct {
+		Data models.HouseWithDetails `json:"data"`
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses/1", "", &got)
+	if rec.Code != http.StatusOK || got.Data.Name != "Sea View" || got.Data.HouseType == nil {
+		t.Errorf("get: status = %d, data = %+v", rec.Code, got.Data)
+	}
+
+	rec = serve(t, h, http.MethodDelete, "/api/houses/1", "", nil, "If-Match", rec.Header().Get("ETag"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("delete: status = %d, want 200", rec.Code)
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses/1", "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	h := newTestRouter(t, models.House{Name: "Sea View", Price: 100 * money.Unit}, models.House{Name: "Loft", Price: 200 * money.Unit})
+
+	if rec := serve(t, h, http.MethodDelete, "/api/houses/1", "", nil, "If-Match", `"1"`); rec.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	var trash struct {
+		PaginatedResponse
+		Data []models.HouseWithDetails `json:"data"`
+	}
+	rec := serve(t, h, http.MethodGet, "/api/houses/trash", "", &trash)
+	if rec.Code != http.StatusOK || trash.Pagination.Total != 1 || len(trash.Data) != 1 ||
+		trash.Data[0].Name != "Sea View" || trash.Data[0].DeletedAt == nil {
+		t.Fatalf("trash: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, h, http.MethodGet, "/api/houses/1", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("get a deleted house: status = %d, want 404", rec.Code)
+	}
+
+	var restored struct {
+		Data models.House `json:"data"`
+	}
+	rec = serve(t, h, http.MethodPost, "/api/houses/1/restore", "", &restored)
+	if rec.Code != http.StatusOK || restored.Data.DeletedAt != nil || rec.Header().Get("ETag") != `"3"` {
+		t.Errorf("restore: status = %d, ETag = %s, body = %s", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "deleted_at") {
+		t.Errorf("restored house still carries deleted_at: %s", rec.Body)
+	}
+	if rec := serve(t, h, http.MethodPost, "/api/houses/2/restore", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("restore a live house: status = %d, want 404", rec.Code)
+	}
+
+	rec = serve(t, h, http.MethodGet, "/api/houses/trash", "", &trash)
+	if rec.Code != http.StatusOK || trash.Pagination.Total != 0 || trash.Data == nil {
+		t.Errorf("empty trash: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, h, http.MethodGet, "/api/houses/trash?page=0", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid page: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHouseImages(t *testing.T) {
+	h := newTestRouter(t, models.House{Name: "Sea View", Price: 100 * money.Unit})
+
+	var added struct {
+		Data models.HouseImage `json:"data"`
+	}
+	rec := serve(t, h, http.MethodPost, "/api/houses/1/images", `{"url":" /images/front.jpg ","alt_text":"Front of the house"}`, &added)
+	if rec.Code != http.StatusCreated || added.Data.ID != 1 || added.Data.URL != "/images/front.jpg" || !added.Data.IsCover {
+		t.Fatalf("add: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, h, http.MethodPost, "/api/houses/1/images", `{"url":"https://example.com/pool.jpg","caption":"The pool","is_cover":true}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add a cover: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	var house struct {
+		Data models.HouseWithDetails `json:"data"`
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses/1", "", &house)
+	if rec.Code != http.StatusOK || len(house.Data.Images) != 2 || !house.Data.Images[1].IsCover || !strings.HasPrefix(rec.Header().Get("ETag"), `"3-`) {
+		t.Fatalf("house details: status = %d, ETag = %s, body = %s", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+
+	var gallery struct {
+		Data []models.HouseImage `json:"data"`
+	}
+	rec = serve(t, h, http.MethodPut, "/api/houses/1/images/order", `{"image_ids":[2,1]}`, &gallery)
+	if rec.Code != http.StatusOK || len(gallery.Data) != 2 || gallery.Data[0].ID != 2 || gallery.Data[1].Position != 1 {
+		t.Errorf("reorder: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, h, http.MethodPut, "/api/houses/1/images/1/cover", "", &gallery)
+	if rec.Code != http.StatusOK || len(gallery.Data) != 2 || !gallery.Data[1].IsCover || gallery.Data[0].IsCover {
+		t.Errorf("set cover: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	var patched struct {
+		Data models.HouseImage `json:"data"`
+	}
+	rec = serve(t, h, http.MethodPatch, "/api/houses/1/images/2", `{"caption":"The heated pool"}`, &patched, "Content-Type", "application/merge-patch+json")
+	if rec.Code != http.StatusOK || patched.Data.Caption != "The heated pool" || patched.Data.URL != "https://example.com/pool.jpg" {
+		t.Errorf("patch: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses/1/images/2", "", &patched)
+	if rec.Code != http.StatusOK || patched.Data.Caption != "The heated pool" {
+		t.Errorf("get: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	if rec := serve(t, h, http.MethodDelete, "/api/houses/1/images/1", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("delete: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses/1/images", "", &gallery)
+	if rec.Code != http.StatusOK || len(gallery.Data) != 1 || gallery.Data[0].ID != 2 || !gallery.Data[0].IsCover || gallery.Data[0].Position != 0 {
+		t.Errorf("list after delete: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name, method, target, body string
+		want                       int
+		field                      string
+	}{
+		{"missing url", http.MethodPost, "/api/houses/1/images", `{"caption":"No photo"}`, http.StatusUnprocessableEntity, "url"},
+		{"invalid url", http.MethodPost, "/api/houses/1/images", `{"url":"ftp://example.com/a.jpg"}`, http.StatusUnprocessableEntity, "url"},
+		{"read-only field", http.MethodPatch, "/api/houses/1/images/2", `{"position":3}`, http.StatusUnprocessableEntity, "position"},
+		{"incomplete order", http.MethodPut, "/api/houses/1/images/order", `{"image_ids":[]}`, http.StatusUnprocessableEntity, "image_ids"},
+		{"repeated image", http.MethodPut, "/api/houses/1/images/order", `{"image_ids":[2,2]}`, http.StatusUnprocessableEntity, "image_ids"},
+		{"missing house", http.MethodGet, "/api/houses/9/images", "", http.StatusNotFound, ""},
+		{"image of another house", http.MethodGet, "/api/houses/9/images/2", "", http.StatusNotFound, ""},
+		{"missing image", http.MethodPut, "/api/houses/1/images/7/cover", "", http.StatusNotFound, ""},
+		{"invalid image ID", http.MethodDelete, "/api/houses/1/images/first", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		var resp APIResponse
+		rec := serve(t, h, tt.method, tt.target, tt.body, &resp)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d, body = %s", tt.name, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if tt.field != "" && (len(resp.Errors) != 1 || resp.Errors[0].Field != tt.field) {
+			t.Errorf("%s: errors = %+v, want one for %s", tt.name, resp.Errors, tt.field)
+		}
+	}
+}
+
+// formBody encodes a multipart/form-data body holding the given field
+// name/value pairs and, unless it is nil, image as the image field.
+func formBody(t *testing.T, image []byte, fields ...string) (body, contentType string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	for i := 0; i+1 < len(fields); i += 2 {
+		form.WriteField(fields[i], fields[i+1])
+	}
+	if image != nil {
+		// The declared file name and type are ignored
+		part, err := form.CreateFormFile("image", "photo.txt")
+		if err != nil {
+			t.Fatalf("CreateFormFile: %v", err)
+		}
+		part.Write(image)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatalf("failed to encode form: %v", err)
+	}
+	return buf.String(), form.FormDataContentType()
+}
+
+// testPNG encodes a w x h PNG image.
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageUploads(t *testing.T) {
+	h := newTestRouter(t, models.House{Name: "Sea View", Price: 100 * money.Unit})
+	photo := testPNG(t, 640, 400)
+
+	var added struct {
+		Data models.HouseImage `json:"data"`
+	}
+	body, contentType := formBody(t, photo, "caption", "The garden", "is_cover", "true")
+	rec := serve(t, h, http.MethodPost, "/api/houses/1/images", body, &added, "Content-Type", contentType)
+	upload := added.Data.Renditions
+	if rec.Code != http.StatusCreated || upload == nil || added.Data.URL != upload.Large || added.Data.Caption != "The garden" || !added.Data.IsCover {
+		t.Fatalf("upload: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	// Renditions are JPEG files served under /images/, never enlarged
+	for url, want := range map[string]image.Point{upload.Thumbnail: {320, 200}, upload.Medium: {640, 400}, upload.Large: {640, 400}} {
+		rec := serve(t, h, http.MethodGet, url, "", nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" || !strings.HasPrefix(url, "/images/houses/1/") {
+			t.Errorf("GET %s: status = %d, Content-Type = %s", url, rec.Code, rec.Header().Get("Content-Type"))
+			continue
+		}
+		config, err := jpeg.DecodeConfig(rec.Body)
+		if err != nil || config.Width != want.X || config.Height != want.Y {
+			t.Errorf("GET %s: %dx%d, %v, want %dx%d", url, config.Width, config.Height, err, want.X, want.Y)
+		}
+	}
+
+	// A new URL or deleting the photo discards the files
+	var patched struct {
+		Data models.HouseImage `json:"data"`
+	}
+	rec = serve(t, h, http.MethodPatch, "/api/houses/1/images/1", `{"url":"https://example.com/garden.jpg"}`, &patched, "Content-Type", "application/merge-patch+json")
+	if rec.Code != http.StatusOK || patched.Data.Renditions != nil {
+		t.Errorf("patch url: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, h, http.MethodGet, upload.Large, "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET %s after replacing it: status = %d, want 404", upload.Large, rec.Code)
+	}
+	body, contentType = formBody(t, photo)
+	serve(t, h, http.MethodPost, "/api/houses/1/images", body, &added, "Content-Type", contentType)
+	if rec := serve(t, h, http.MethodDelete, "/api/houses/1/images/2", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("delete: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, h, http.MethodGet, added.Data.Renditions.Thumbnail, "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET %s after deleting it: status = %d, want 404", added.Data.Renditions.Thumbnail, rec.Code)
+	}
+
+	// Agents have a single image, replaced by each upload
+	var agent, replaced struct {
+		Data models.Agent `json:"data"`
+	}
+	body, contentType = formBody(t, photo)
+	rec = serve(t, h, http.MethodPut, "/api/agents/1/image", body, &agent, "Content-Type", contentType)
+	first := agent.Data.ImageRenditions
+	if rec.Code != http.StatusOK || first == nil || agent.Data.ImageURL == nil || *agent.Data.ImageURL != first.Large {
+		t.Fatalf("agent upload: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, h, http.MethodPut, "/api/agents/1/image", body, &replaced, "Content-Type", contentType)
+	if rec.Code != http.StatusOK || replaced.Data.ImageRenditions == nil || *replaced.Data.ImageRenditions == *first {
+		t.Errorf("second agent upload: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, h, http.MethodGet, first.Medium, "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET %s after replacing it: status = %d, want 404", first.Medium, rec.Code)
+	}
+
+	text, textType := formBody(t, []byte("not an image"))
+	unknown, unknownType := formBody(t, photo, "colour", "red")
+	empty, emptyType := formBody(t, nil, "caption", "No photo")
+	huge, hugeType := formBody(t, bytes.Repeat([]byte{0}, testMaxUpload+1))
+	tests := []struct {
+		name, method, target, body, contentType string
+		want                                    int
+		field                                   string
+	}{
+		{"not an image", http.MethodPost, "/api/houses/1/images", text, textType, http.StatusUnsupportedMediaType, ""},
+		{"too large", http.MethodPut, "/api/agents/1/image", huge, hugeType, http.StatusRequestEntityTooLarge, ""},
+		{"not a form", http.MethodPut, "/api/agents/1/image", `{"image_url":"/a.jpg"}`, "application/json", http.StatusUnsupportedMediaType, ""},
+		{"unknown field", http.MethodPost, "/api/houses/1/images", unknown, unknownType, http.StatusUnprocessableEntity, "colour"},
+		{"missing image", http.MethodPost, "/api/houses/1/images", empty, emptyType, http.StatusUnprocessableEntity, "image"},
+		{"missing house", http.MethodPost, "/api/houses/9/images", body, contentType, http.StatusNotFound, ""},
+		{"missing agent", http.MethodPut, "/api/agents/9/image", body, contentType, http.StatusNotFound, ""},
+		{"missing file", http.MethodGet, "/images/houses/1/missing.jpg", "", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		var resp APIResponse
+		rec := serve(t, h, tt.method, tt.target, tt.body, nil, "Content-Type", tt.contentType)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d, body = %s", tt.name, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if tt.field != "" && (json.Unmarshal(rec.Body.Bytes(), &resp) != nil || len(resp.Errors) != 1 || resp.Errors[0].Field != tt.field) {
+			t.Errorf("%s: body = %s, want one error for %s", tt.name, rec.Body, tt.field)
+		}
+	}
+}
+
+func TestPatchHouse(t *testing.T) {
+	imageURL := "/images/sea.jpg"
+	h := newTestRouter(t, models.House{Name: "Sea View", Price: 1000 * money.Unit, Tags: []string{"beach"}, ImageURL: &imageURL})
+
+	var patched struct {
+		Data models.House `json:"data"`
+	}
+	rec := serve(t, h, http.MethodPatch, "/api/houses/1", `{"price":1500.25,"currency":"eur"}`, &patched, "If-Match", `"1"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch price: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got := patched.Data; got.Price != 1500*money.Unit+25 || got.Currency != "EUR" || got.Name != "Sea View" || len(got.Tags) != 1 ||
+		got.ImageURL == nil || got.AgentID != 1 {
+		t.Errorf("after patching the price: %+v", got)
+	}
+
+	rec = serve(t, h, http.MethodPatch, "/api/houses/1", `{"tags":["pool","garden"],"image_url":null,"bedrooms":3,"area_unit":"SQFT","address":{"city":" Austin ","country":"us"}}`, &patched, "If-Match", `"2"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch tags: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got := patched.Data; strings.Join(got.Tags, ",") != "pool,garden" || got.ImageURL != nil || got.Price != 1500*money.Unit+25 ||
+		got.Bedrooms == nil || *got.Bedrooms != 3 || got.AreaUnit != models.AreaUnitSquareFeet ||
+		got.Address != (models.Address{City: "Austin", Country: "US"}) {
+		t.Errorf("after patching tags, image and attributes: %+v", got)
+	}
+	if got := patched.Data; got.Latitude == nil || *got.Latitude != 30.2672 || got.Longitude == nil || *got.Longitude != -97.7431 {
+		t.Errorf("patching the address: coordinates = %v, %v, want those of Austin, TX", got.Latitude, got.Longitude)
+	}
+
+	// Address parts are merged one by one
+	rec = serve(t, h, http.MethodPatch, "/api/houses/1", `{"address":{"street":"200 Congress Ave"}}`, &patched, "If-Match", `"3"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch street: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got := patched.Data; got.Address != (models.Address{Street: "200 Congress Ave", City: "Austin", Country: "US"}) ||
+		got.Latitude == nil || *got.Latitude != 30.2672 {
+		t.Errorf("after patching the street: address = %+v, latitude = %v", got.Address, got.Latitude)
+	}
+
+	// The old coordinates do not fit an address that cannot be geocoded
+	rec = serve(t, h, http.MethodPatch, "/api/houses/1", `{"address":{"city":"Nowhere"}}`, &patched, "If-Match", `"4"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch city: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got := patched.Data; got.Address.Street != "200 Congress Ave" || got.Address.City != "Nowhere" || got.Latitude != nil || got.Longitude != nil {
+		t.Errorf("after moving to an unknown city: address = %+v, coordinates = %v, %v", got.Address, got.Latitude, got.Longitude)
+	}
+
+	var resp APIResponse
+	rec = serve(t, h, http.MethodPatch, "/api/houses/1", `{"name":null,"id":7,"agent_id":42,"latitude":30}`, &resp, "If-Match", "*")
+	want := validation.Errors{
+		{Field: "id", Code: validation.CodeReadOnly},
+		{Field: "name", Code: validation.CodeRequired},
+		{Field: "longitude", Code: validation.CodeRequired},
+		{Field: "agent_id", Code: validation.CodeNotFound},
+	}
+	if rec.Code != http.StatusUnprocessableEntity || len(resp.Errors) != len(want) {
+		t.Fatalf("invalid patch: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	for i, fe := range resp.Errors {
+		if fe.Field != want[i].Field || fe.Code != want[i].Code {
+			t.Errorf("errors[%d] = %+v, want %+v", i, fe, want[i])
+		}
+	}
+
+	for body, status := range map[string]int{
+		`[]`:   http.StatusBadRequest,
+		`null`: http.StatusBadRequest,
+		`{}`:   http.StatusOK,
+	} {
+		if rec := serve(t, h, http.MethodPatch, "/api/houses/1", body, nil, "If-Match", "*"); rec.Code != status {
+			t.Errorf("patch %s: status = %d, want %d", body, rec.Code, status)
+		}
+	}
+	if rec := serve(t, h, http.MethodPatch, "/api/houses/42", `{"price":1}`, nil, "If-Match", "*"); rec.Code != http.StatusNotFound {
+		t.Errorf("patch missing house: status = %d, want 404", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/houses/1", strings.NewReader(`[{"op":"remove","path":"/tags"}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req.Header.Set("If-Match", "*")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("JSON Patch: status = %d, want 415", rec.Code)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	h := newTestRouter(t, models.House{Name: "Contested", Price: 1000 * money.Unit})
+
+	rec := serve(t, h, http.MethodGet, "/api/houses/1", "", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("get: status = %d, ETag = %q", rec.Code, etag)
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses/1", "", nil, "If-None-Match", `"0", `+etag)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Errorf("get with matching If-None-Match: status = %d, body = %q", rec.Code, rec.Body)
+	}
+
+	body := `{"name":"Contested","price":1100,"house_type_id":1,"agent_id":1}`
+	if rec := serve(t, h, http.MethodPut, "/api/houses/1", body, nil); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("put without If-Match: status = %d, want 428", rec.Code)
+	}
+	rec = serve(t, h, http.MethodPut, "/api/houses/1", body, nil, "If-Match", etag)
+	updated := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || updated == "" || updated == etag {
+		t.Fatalf("put with If-Match: status = %d, ETag %q -> %q", rec.Code, etag, updated)
+	}
+
+	// A second client still holding the old ETag
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if rec := serve(t, h, method, "/api/houses/1", body, nil, "If-Match", etag); rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s with a stale If-Match: status = %d, want 412", method, rec.Code)
+		}
+	}
+	if rec := serve(t, h, http.MethodGet, "/api/houses/1", "", nil, "If-None-Match", etag); rec.Code != http.StatusOK {
+		t.Errorf("get with a stale If-None-Match: status = %d, want 200", rec.Code)
+	}
+
+	rec = serve(t, h, http.MethodPatch, "/api/houses/1", `{"price":1200}`, nil, "If-Match", etag+", "+updated)
+	if rec.Code != http.StatusOK {
+		t.Errorf("patch with a list of ETags: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, h, http.MethodDelete, "/api/houses/1", "", nil, "If-Match", "W/"+rec.Header().Get("ETag")); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("delete with a weak ETag: status = %d, want 412", rec.Code)
+	}
+
+	// The embedded agent is part of the representation, not of the house
+	etag = serve(t, h, http.MethodGet, "/api/houses/1", "", nil).Header().Get("ETag")
+	if rec := serve(t, h, http.MethodPut, "/api/agents/1", `{"first_name":"Janet","last_name":"Doe"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("rename agent: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, h, http.MethodGet, "/api/houses/1", "", nil, "If-None-Match", etag)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("get after renaming the agent: status = %d, ETag %q -> %q", rec.Code, etag, rec.Header().Get("ETag"))
+	}
+	if rec := serve(t, h, http.MethodPatch, "/api/houses/1", `{"price":1300}`, nil, "If-Match", etag); rec.Code != http.StatusOK {
+		t.Errorf("patch after renaming the agent: status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	h := newTestRouter(t)
+
+	tests := []struct {
+		method, target, body string
+		status               int
+	}{
+		{http.MethodGet, "/api/houses/42", "", http.StatusNotFound},
+		{http.MethodGet, "/api/houses/abc", "", http.StatusBadRequest},
+		{http.MethodPut, "/api/houses/42", `{"name":"Gone","price":1,"house_type_id":1,"agent_id":1}`, http.StatusNotFound},
+		{http.MethodDelete, "/api/houses/42", "", http.StatusNotFound},
+		{http.MethodPost, "/api/houses", `{"name":"","price":1}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/api/houses", `{"name":`, http.StatusBadRequest},
+		{http.MethodPost, "/api/houses", `{"name":"Lost","price":1,"house_type_id":1,"agent_id":42}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/api/houses", `{"name":"Dear","price":1e12,"house_type_id":1,"agent_id":1}`, http.StatusUnprocessableEntity},
+		{http.MethodDelete, "/api/agents/42", "", http.StatusNotFound},
+		{http.MethodGet, "/api/agents/42/houses", "", http.StatusNotFound},
+		{http.MethodPost, "/api/house-types", `{"name":"Villa"}`, http.StatusConflict},
+		{http.MethodGet, "/api/nowhere", "", http.StatusNotFound},
+		{http.MethodPatch, "/api/agents", "", http.StatusMethodNotAllowed},
+		{http.MethodPatch, "/api/houses", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		var resp APIResponse
+		rec := serve(t, h, tt.method, tt.target, tt.body, &resp, "If-Match", "*")
+		if rec.Code != tt.status || resp.Success || resp.Error == "" {
+			t.Errorf("%s %s: status = %d, body = %s, want %d with an error", tt.method, tt.target, rec.Code, rec.Body, tt.status)
+		}
+	}
+
+	// Conflicts name the column of the violated constraint
+	var resp APIResponse
+	serve(t, h, http.MethodPost, "/api/house-types", `{"name":"Villa"}`, &resp)
+	if want := "A house type with this name already exists"; resp.Error != want {
+		t.Errorf("duplicate house type: error = %q, want %q", resp.Error, want)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	h := newTestRouter(t)
+
+	tests := []struct {
+		method, target, body string
+		want                 validation.Errors
+	}{
+		{
+			http.MethodPost, "/api/houses",
+			`{"name":" ","price":-5,"house_type_id":1,"agent_id":42,"image_url":"ftp://x"}`,
+			validation.Errors{
+				{Field: "name", Code: validation.CodeRequired},
+				{Field: "price", Code: validation.CodeNotPositive},
+				{Field: "image_url", Code: validation.CodeInvalid},
+				{Field: "agent_id", Code: validation.CodeNotFound},
+			},
+		},
+		{
+			http.MethodPut, "/api/houses/1",
+			`{"name":"Tall","price":1,"house_type_id":1,"agent_id":1,"colour":"red"}`,
+			validation.Errors{{Field: "colour", Code: validation.CodeUnknownField}},
+		},
+		{
+			http.MethodPost, "/api/houses",
+			`{"name":"Typed","price":"cheap","house_type_id":1,"agent_id":1}`,
+			validation.Errors{{Field: "price", Code: validation.CodeInvalidType}},
+		},
+		{
+			http.MethodPost, "/api/houses",
+			`{"name":"Exact","price":10.005,"currency":"USD","house_type_id":1,"agent_id":1}`,
+			validation.Errors{{Field: "price", Code: validation.CodeInvalidType}},
+		},
+		{
+			http.MethodPost, "/api/houses",
+			`{"name":"Pirate","price":10,"currency":"doubloons","house_type_id":1,"agent_id":1}`,
+			validation.Errors{{Field: "currency", Code: validation.CodeInvalid}},
+		},
+		{
+			http.MethodPost, "/api/houses",
+			`{"name":"Odd","price":10,"house_type_id":1,"agent_id":1,"bedrooms":-1,"bathrooms":1.25,
+			  "floor_area":0,"area_unit":"acres","year_built":99999,"floors":0}`,
+			validation.Errors{
+				{Field: "bedrooms", Code: validation.CodeOutOfRange},
+				{Field: "bathrooms", Code: validation.CodeInvalid},
+				{Field: "floor_area", Code: validation.CodeNotPositive},
+				{Field: "area_unit", Code: validation.CodeInvalid},
+				{Field: "year_built", Code: validation.CodeOutOfRange},
+				{Field: "floors", Code: validation.CodeOutOfRange},
+			},
+		},
+		{
+			// The limits are those of the columns, after rounding to their scale
+			http.MethodPost, "/api/houses",
+			`{"name":"Vast","price":10,"house_type_id":1,"agent_id":1,"bathrooms":100,"floor_area":99999999.996}`,
+			validation.Errors{
+				{Field: "bathrooms", Code: validation.CodeOutOfRange},
+				{Field: "floor_area", Code: validation.CodeTooLarge},
+			},
+		},
+		{
+			http.MethodPost, "/api/houses",
+			`{"name":"Lost","price":10,"house_type_id":1,"agent_id":1,"address":{"city":"` + strings.Repeat("a", 101) + `",
+			  "country":"usa"},"latitude":95}`,
+			validation.Errors{
+				{Field: "address.city", Code: validation.CodeTooLong},
+				{Field: "address.country", Code: validation.CodeInvalid},
+				{Field: "latitude", Code: validation.CodeOutOfRange},
+				{Field: "longitude", Code: validation.CodeRequired},
+			},
+		},
+		{
+			http.MethodPost, "/api/agents",
+			`{"first_name":"` + strings.Repeat("a", 101) + `"}`,
+			validation.Errors{
+				{Field: "first_name", Code: validation.CodeTooLong},
+				{Field: "last_name", Code: validation.CodeRequired},
+			},
+		},
+		{
+			http.MethodPost, "/api/house-types", `{}`,
+			validation.Errors{{Field: "name", Code: validation.CodeRequired}},
+		},
+	}
+	for _, tt := range tests {
+		var resp APIResponse
+		rec := serve(t, h, tt.method, tt.target, tt.body, &resp, "If-Match", "*")
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s: status = %d, want 422", tt.method, tt.target, rec.Code)
+			continue
+		}
+		if len(resp.Errors) != len(tt.want) {
+			t.Errorf("%s %s: errors = %+v, want %+v", tt.method, tt.target, resp.Errors, tt.want)
+			continue
+		}
+		for i, fe := range resp.Errors {
+			if fe.Field != tt.want[i].Field || fe.Code != tt.want[i].Code {
+				t.Errorf("%s %s: errors[%d] = %+v, want %+v", tt.method, tt.target, i, fe, tt.want[i])
+			}
+		}
+	}
+}
+
+// stalledHouseStore never answers ListHouses before the context is done.
+type stalledHouseStore struct {
+	repository.HouseStore
+}
+
+func (stalledHouseStore) ListHouses(ctx context.Context, filter repository.HouseFilter) ([]models.HouseWithDetails, int, error) {
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestRequestTimeout(t *testing.T) {
+	log, err := logger.New(logger.Config{Outputs: []string{os.DevNull}})
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	t.Cleanup(log.Close)
+
+	store := memory.New()
+	houses := NewHouseHandler(stalledHouseStore{store}, store, store, nil, log)
+	h := middleware.Timeout(10*time.Millisecond, NewRouter(log, houses, NewHouseImageHandler(store, nil, log),
+		NewAgentHandler(store, store, nil, log), NewHouseTypeHandler(store, log), NewTagHandler(store, log),
+		NewGeocodeHandler(nil, log), nil))
+
+	var resp APIResponse
+	rec := serve(t, h, http.MethodGet, "/api/houses", "", &resp)
+	if rec.Code != http.StatusGatewayTimeout || resp.Error != "Request timed out" {
+		t.Errorf("status = %d, body = %s, want 504", rec.Code, rec.Body)
+	}
+}
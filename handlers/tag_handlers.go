@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+)
+
+type TagHandler struct {
+	responder
+	tagRepo repository.TagStore
+}
+
+func NewTagHandler(tagRepo repository.TagStore, logger *logger.Logger) *TagHandler {
+	return &TagHandler{
+		tagRepo:   tagRepo,
+		responder: responder{logger: logger},
+	}
+}
+
+// GetTags lists every tag in use with the number of houses carrying it, most
+// used first.
+func (h *TagHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.tagRepo.ListTags(r.Context())
+	if err != nil {
+		h.sendRepositoryError(w, r, err, "retrieve", "tags")
+		return
+	}
+
+	if tags == nil {
+		tags = []models.TagCount{}
+	}
+
+	h.sendSuccessResponse(w, tags, "Tags retrieved successfully")
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"thugcorp.io/nomado/imaging"
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/storage"
+	"thugcorp.io/nomado/validation"
+)
+
+const (
+	// uploadField is the form field holding the uploaded file
+	uploadField = "image"
+	// maxUploadFieldSize bounds the other form fields, such as a caption
+	maxUploadFieldSize = 4096
+)
+
+// ImageUploader stores the renditions of uploaded images.
+type ImageUploader struct {
+	storage storage.Storage
+	maxSize int64
+}
+
+// NewImageUploader returns an uploader accepting files of up to maxSize
+// bytes. The handlers answer uploads with 503 when given a nil uploader.
+func NewImageUploader(store storage.Storage, maxSize int64) *ImageUploader {
+	return &ImageUploader{storage: store, maxSize: maxSize}
+}
+
+// upload is a parsed multipart/form-data request body.
+type upload struct {
+	image  []byte
+	fields map[string]string
+}
+
+var (
+	errNotMultipart   = errors.New("request body is not multipart/form-data")
+	errUploadTooLarge = errors.New("upload is too large")
+)
+
+// isMultipart reports whether a Content-Type header announces a form upload.
+func isMultipart(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// parse reads the body of a form upload. The parts are streamed, so nothing
+// is written to temporary files; the file may be at most u.maxSize bytes and
+// every other field at most maxUploadFieldSize.
+func (u *ImageUploader) parse(w http.ResponseWriter, r *http.Request) (*upload, error) {
+	if !isMultipart(r.Header.Get("Content-Type")) {
+		return nil, errNotMultipart
+	}
+	// Leaves room for the part headers and the other fields
+	r.Body = http.MaxBytesReader(w, r.Body, u.maxSize+64*1024)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	up := &upload{fields: make(map[string]string)}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return up, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := part.FormName()
+		limit := int64(maxUploadFieldSize)
+		if name == uploadField {
+			limit = u.maxSize
+		}
+		data, err := io.ReadAll(io.LimitReader(part, limit+1))
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > limit {
+			return nil, errUploadTooLarge
+		}
+
+		if name == uploadField {
+			up.image = data
+		} else if name != "" {
+			up.fields[name] = string(data)
+		}
+	}
+}
+
+// receive parses a form upload and checks its image and field names, of
+// which only allowed are accepted. If the upload is not acceptable, it
+// answers the request and returns nil.
+func (rs responder) receive(w http.ResponseWriter, r *http.Request, u *ImageUploader, allowed map[string]bool) *upload {
+	if u == nil {
+		rs.sendErrorResponse(w, http.StatusServiceUnavailable, "Image uploads are disabled")
+		return nil
+	}
+
+	up, err := u.parse(w, r)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errNotMultipart):
+		rs.sendErrorResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be multipart/form-data")
+		return nil
+	case errors.Is(err, errUploadTooLarge) || errors.As(err, &maxBytesErr):
+		rs.sendErrorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Image must be at most %d MB", u.maxSize>>20))
+		return nil
+	case err != nil:
+		rs.sendErrorResponse(w, http.StatusBadRequest, "Invalid multipart body")
+		return nil
+	}
+
+	var v validation.Validator
+	names := make([]string, 0, len(up.fields))
+	for name := range up.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !allowed[name] {
+			v.Add(name, validation.CodeUnknownField, "")
+		}
+	}
+	if len(up.image) == 0 {
+		v.Add(uploadField, validation.CodeRequired, "is required")
+	}
+	if err := v.Err(); err != nil {
+		rs.sendRequestError(w, r, err, "image")
+		return nil
+	}
+
+	// The declared type and file name are not trusted
+	if _, ok := imaging.Sniff(up.image); !ok {
+		rs.sendErrorResponse(w, http.StatusUnsupportedMediaType, "Image must be a JPEG, PNG or GIF file")
+		return nil
+	}
+	return up
+}
+
+// store resizes an uploaded image and stores its renditions under prefix,
+// such as houses/1. If the image cannot be decoded or stored, it answers the
+// request and returns false.
+func (rs responder) store(w http.ResponseWriter, r *http.Request, u *ImageUploader, prefix string, image []byte) (models.Renditions, bool) {
+	renditions, err := imaging.Process(r.Context(), image)
+	if err != nil {
+		var v validation.Validator
+		switch {
+		case r.Context().Err() != nil:
+			rs.sendRepositoryError(w, r, err, "process", "image")
+			return models.Renditions{}, false
+		case errors.Is(err, imaging.ErrTooLarge):
+			v.Add(uploadField, validation.CodeTooLarge, fmt.Sprintf("must be at most %d megapixels", imaging.MaxPixels/1_000_000))
+		case errors.Is(err, imaging.ErrUnsupported):
+			v.Add(uploadField, validation.CodeInvalid, "is not a valid image")
+		default:
+			rs.logger.ErrorContext(r.Context(), "Failed to process image", err)
+			rs.sendErrorResponse(w, http.StatusInternalServerError, "Failed to process image")
+			return models.Renditions{}, false
+		}
+		rs.sendRequestError(w, r, v.Err(), "image")
+		return models.Renditions{}, false
+	}
+
+	// Every upload gets new keys, so cached copies never go stale
+	token := make([]byte, 8)
+	rand.Read(token)
+	dir := prefix + "/" + hex.EncodeToString(token)
+
+	var urls models.Renditions
+	targets := map[string]*string{"thumbnail": &urls.Thumbnail, "medium": &urls.Medium, "large": &urls.Large}
+	for _, rendition := range renditions {
+		key := dir + "/" + rendition.Name + ".jpg"
+		if err := u.storage.Put(r.Context(), key, rendition.Data, "image/jpeg"); err != nil {
+			rs.discard(r.Context(), u, &urls)
+			rs.sendRepositoryError(w, r, err, "store", "image")
+			return models.Renditions{}, false
+		}
+		*targets[rendition.Name] = u.storage.URL(key)
+	}
+	return urls, true
+}
+
+// discard deletes stored renditions that are no longer referenced. Failures
+// do not fail the request but are logged with the key, so that the orphaned
+// file can be removed by hand.
+func (rs responder) discard(ctx context.Context, u *ImageUploader, renditions *models.Renditions) {
+	if u == nil || renditions == nil {
+		return
+	}
+	// The files go even if the request was cancelled
+	ctx = context.WithoutCancel(ctx)
+	for _, url := range renditions.URLs() {
+		key, ok := u.storage.Key(url)
+		if !ok {
+			continue
+		}
+		if err := u.storage.Delete(ctx, key); err != nil {
+			rs.logger.WarnContext(ctx, "Failed to delete stored image", "key", key, "error", err)
+		}
+	}
+}
+
+// formBool parses a boolean form field, which is false when missing.
+func formBool(v *validation.Validator, fields map[string]string, name string) bool {
+	switch strings.TrimSpace(fields[name]) {
+	case "", "false", "0", "off":
+		return false
+	case "true", "1", "on":
+		return true
+	default:
+		v.Add(name, validation.CodeInvalidType, "must be true or false")
+		return false
+	}
+}
@@ -0,0 +1,171 @@
+// Package imaging turns uploaded photos into the resized JPEG renditions
+// served by the API. Re-encoding drops every metadata block of the upload,
+// including EXIF data such as the GPS position of the camera, after the EXIF
+// orientation has been applied to the pixels.
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registers the decoders used by image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// Content types accepted by Process, as sniffed from the data
+var ContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// MaxPixels is the largest image Process decodes, so that a small file
+// cannot claim gigabytes of memory.
+const MaxPixels = 50_000_000
+
+// MaxConcurrent is how many images Process decodes at once. An image of
+// MaxPixels pixels takes about 200 MB for each of its decoded, flattened and
+// oriented copies, so further uploads wait for a turn instead of exhausting
+// memory.
+const MaxConcurrent = 2
+
+// slots holds a token for each image being decoded.
+var slots = make(chan struct{}, MaxConcurrent)
+
+// quality is the JPEG quality of the renditions.
+const quality = 85
+
+// Size is a rendition: the image scaled down, never up, so that its longer
+// side is at most MaxSide pixels.
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+// Sizes are the renditions made of every upload, smallest first.
+var Sizes = []Size{
+	{Name: "thumbnail", MaxSide: 320},
+	{Name: "medium", MaxSide: 1024},
+	{Name: "large", MaxSide: 2048},
+}
+
+// ErrUnsupported is returned for data that is not a JPEG, PNG or GIF image.
+var ErrUnsupported = errors.New("unsupported image format")
+
+// ErrTooLarge is returned for images of more than MaxPixels pixels.
+var ErrTooLarge = errors.New("image is too large")
+
+// Rendition is an encoded JPEG rendition.
+type Rendition struct {
+	Size
+	Width, Height int
+	Data          []byte
+}
+
+// Sniff returns the content type of data from its first bytes, ignoring
+// whatever the client claimed. ok reports whether it is one of ContentTypes.
+func Sniff(data []byte) (contentType string, ok bool) {
+	contentType = http.DetectContentType(data)
+	for _, accepted := range ContentTypes {
+		if contentType == accepted {
+			return contentType, true
+		}
+	}
+	return contentType, false
+}
+
+// Process decodes an uploaded image and returns its renditions in the order
+// of Sizes. The first frame of an animated GIF is used, and transparent
+// areas are filled with white. While MaxConcurrent images are being
+// processed, Process waits for one to finish or for ctx to be done.
+func Process(ctx context.Context, data []byte) ([]Rendition, error) {
+	if _, ok := Sniff(data); !ok {
+		return nil, ErrUnsupported
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupported, err)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrTooLarge, config.Width, config.Height)
+	}
+
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupported, err)
+	}
+	photo := orient(flatten(img), orientation(data))
+
+	renditions := make([]Rendition, 0, len(Sizes))
+	for _, size := range Sizes {
+		resized := resize(photo, size.MaxSide)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s rendition: %w", size.Name, err)
+		}
+		bounds := resized.Bounds()
+		renditions = append(renditions, Rendition{
+			Size:   size,
+			Width:  bounds.Dx(),
+			Height: bounds.Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+	return renditions, nil
+}
+
+// flatten draws img onto a white, opaque canvas whose origin is (0, 0).
+func flatten(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), img, bounds.Min, draw.Over)
+	return canvas
+}
+
+// resize scales img down so that its longer side is at most maxSide pixels,
+// averaging the source pixels covered by each pixel of the result.
+func resize(img *image.RGBA, maxSide int) *image.RGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+	if sw <= maxSide && sh <= maxSide {
+		return img
+	}
+	dw, dh := maxSide, max(1, sh*maxSide/sw)
+	if sh > sw {
+		dw, dh = max(1, sw*maxSide/sh), maxSide
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		sy0, sy1 := dy*sh/dh, max((dy+1)*sh/dh, dy*sh/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			sx0, sx1 := dx*sw/dw, max((dx+1)*sw/dw, dx*sw/dw+1)
+			var r, g, b, n int
+			for sy := sy0; sy < sy1; sy++ {
+				row := img.Pix[sy*img.Stride+sx0*4 : sy*img.Stride+sx1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += int(row[i])
+					g += int(row[i+1])
+					b += int(row[i+2])
+					n++
+				}
+			}
+			i := dy*out.Stride + dx*4
+			out.Pix[i] = uint8(r / n)
+			out.Pix[i+1] = uint8(g / n)
+			out.Pix[i+2] = uint8(b / n)
+			out.Pix[i+3] = 0xff
+		}
+	}
+	return out
+}
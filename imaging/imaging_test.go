@@ -0,0 +1,193 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red  = color.RGBA{0xff, 0, 0, 0xff}
+	blue = color.RGBA{0, 0, 0xff, 0xff}
+)
+
+// halves returns a w×h image whose left half is red and right half blue.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, blue)
+			}
+		}
+	}
+	return img
+}
+
+// withExif encodes img as a JPEG carrying an EXIF segment with the given
+// orientation and a GPS marker that must not survive processing.
+func withExif(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Big-endian TIFF with one IFD entry: Orientation (SHORT, count 1)
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	binary.BigEndian.PutUint16(tiff[18:], orientation)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	payload = append(payload, "GPS 30.2672 -97.7431"...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+
+	data := append([]byte{0xff, 0xd8}, segment...)
+	data = append(data, payload...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+// colorAt reports whether the pixel at x, y of img is mostly red or blue.
+func colorAt(img image.Image, x, y int) string {
+	r, _, b, _ := img.At(x, y).RGBA()
+	if r > b {
+		return "red"
+	}
+	return "blue"
+}
+
+func decode(t *testing.T, rendition Rendition) image.Image {
+	t.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(rendition.Data))
+	if err != nil {
+		t.Fatalf("%s rendition is not a JPEG: %v", rendition.Name, err)
+	}
+	return img
+}
+
+func TestProcessSizes(t *testing.T) {
+	var data bytes.Buffer
+	if err := png.Encode(&data, halves(3000, 1500)); err != nil {
+		t.Fatal(err)
+	}
+	renditions, err := Process(t.Context(), data.Bytes())
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	want := map[string][2]int{"thumbnail": {320, 160}, "medium": {1024, 512}, "large": {2048, 1024}}
+	if len(renditions) != len(want) {
+		t.Fatalf("Process returned %d renditions, want %d", len(renditions), len(want))
+	}
+	for _, r := range renditions {
+		img := decode(t, r)
+		size := want[r.Name]
+		if r.Width != size[0] || r.Height != size[1] || img.Bounds().Dx() != size[0] || img.Bounds().Dy() != size[1] {
+			t.Errorf("%s = %dx%d (decoded %v), want %dx%d", r.Name, r.Width, r.Height, img.Bounds(), size[0], size[1])
+		}
+		if colorAt(img, 1, 1) != "red" || colorAt(img, r.Width-2, 1) != "blue" {
+			t.Errorf("%s rendition lost the picture", r.Name)
+		}
+	}
+}
+
+func TestProcessNeverUpscales(t *testing.T) {
+	var data bytes.Buffer
+	if err := png.Encode(&data, halves(200, 300)); err != nil {
+		t.Fatal(err)
+	}
+	renditions, err := Process(t.Context(), data.Bytes())
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	for _, r := range renditions {
+		if r.Width != 200 || r.Height != 300 {
+			t.Errorf("%s = %dx%d, want the original 200x300", r.Name, r.Width, r.Height)
+		}
+	}
+}
+
+func TestProcessOrientsAndStripsExif(t *testing.T) {
+	// Stored sideways: the camera wants it turned 90° clockwise for display
+	data := withExif(t, halves(64, 32), 6)
+	if got := orientation(data); got != 6 {
+		t.Fatalf("orientation = %d, want 6", got)
+	}
+
+	renditions, err := Process(t.Context(), data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	for _, r := range renditions {
+		if bytes.Contains(r.Data, []byte("Exif")) || bytes.Contains(r.Data, []byte("GPS")) {
+			t.Errorf("%s rendition kept the EXIF data", r.Name)
+		}
+		img := decode(t, r)
+		// The left half of the stored image is the top of the upright one
+		if r.Width != 32 || r.Height != 64 || colorAt(img, 16, 4) != "red" || colorAt(img, 16, 60) != "blue" {
+			t.Errorf("%s = %dx%d with %s on top, want 32x64 with red on top", r.Name, r.Width, r.Height, colorAt(img, 16, 4))
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// Every orientation turns the stored image back to upright
+	upright := halves(4, 2)
+	for o, want := range map[int][2]int{1: {4, 2}, 2: {4, 2}, 3: {4, 2}, 4: {4, 2}, 5: {2, 4}, 6: {2, 4}, 7: {2, 4}, 8: {2, 4}} {
+		got := orient(upright, o)
+		if got.Bounds().Dx() != want[0] || got.Bounds().Dy() != want[1] {
+			t.Errorf("orient(%d) = %v, want %dx%d", o, got.Bounds(), want[0], want[1])
+		}
+	}
+	if got := orient(upright, 3); colorAt(got, 0, 0) != "blue" || colorAt(got, 3, 1) != "red" {
+		t.Error("orient(3) did not turn the image upside down")
+	}
+	if got := orient(upright, 8); colorAt(got, 0, 0) != "blue" || colorAt(got, 0, 3) != "red" {
+		t.Error("orient(8) did not turn the image counter-clockwise")
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	if _, err := Process(t.Context(), []byte("%PDF-1.7 not a photo")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Process(PDF) = %v, want ErrUnsupported", err)
+	}
+	// A PNG header claiming 100000x100000 pixels
+	ihdr := []byte("IHDR\x00\x01\x86\xa0\x00\x01\x86\xa0\x08\x02\x00\x00\x00")
+	header := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d"), ihdr...)
+	header = binary.BigEndian.AppendUint32(header, crc32.ChecksumIEEE(ihdr))
+	if _, err := Process(t.Context(), header); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Process(huge PNG) = %v, want ErrTooLarge", err)
+	}
+	if contentType, ok := Sniff([]byte("GIF89a")); !ok || contentType != "image/gif" {
+		t.Errorf("Sniff(GIF) = %s, %v", contentType, ok)
+	}
+}
+
+func TestProcessWaitsForSlot(t *testing.T) {
+	var data bytes.Buffer
+	if err := png.Encode(&data, halves(4, 2)); err != nil {
+		t.Fatal(err)
+	}
+	for range MaxConcurrent {
+		slots <- struct{}{}
+	}
+	defer func() {
+		for range MaxConcurrent {
+			<-slots
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := Process(ctx, data.Bytes()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Process with every slot taken = %v, want context.Canceled", err)
+	}
+}
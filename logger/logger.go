@@ -1,41 +1,213 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"time"
 )
 
+// Config selects the minimum level, the output format and the sinks of a Logger.
+type Config struct {
+	// Level is one of debug, info, warn or error. Defaults to info.
+	Level string
+	// Format is either text or json. Defaults to text.
+	Format string
+	// Outputs lists the sinks to write to: "stdout", "stderr" or a file path.
+	// Defaults to stdout.
+	Outputs []string
+}
+
+// Logger is a levelled, structured logger built on log/slog. Every record is
+// written to all configured outputs.
 type Logger struct {
-	infoLogger  *log.Logger
-	errorLogger *log.Logger
-	file        *os.File
+	handler slog.Handler
+	files   []*os.File
 }
 
-// NewLogger creates a new logger with output to both file and stdout
+// NewLogger creates a text logger at info level with output to both stdout
+// and the given file.
 func NewLogger(logFilePath string) (*Logger, error) {
-	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, err
+	return New(Config{Outputs: []string{"stdout", logFilePath}})
+}
+
+// New creates a logger from the given configuration.
+func New(cfg Config) (*Logger, error) {
+	var level slog.Level
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+		}
+	}
+
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{"stdout"}
+	}
+
+	l := &Logger{}
+	var handlers []slog.Handler
+	for _, output := range outputs {
+		var w io.Writer
+		switch output {
+		case "stdout":
+			w = os.Stdout
+		case "stderr":
+			w = os.Stderr
+		default:
+			file, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				l.Close()
+				return nil, err
+			}
+			l.files = append(l.files, file)
+			w = file
+		}
+
+		opts := &slog.HandlerOptions{AddSource: true, Level: level, ReplaceAttr: shortSource}
+		switch strings.ToLower(cfg.Format) {
+		case "", "text":
+			handlers = append(handlers, slog.NewTextHandler(w, opts))
+		case "json":
+			handlers = append(handlers, slog.NewJSONHandler(w, opts))
+		default:
+			l.Close()
+			return nil, fmt.Errorf("invalid log format %q", cfg.Format)
+		}
+	}
+
+	l.handler = fanoutHandler(handlers)
+	return l, nil
+}
+
+// shortSource trims the source attribute to file:line, like log.Lshortfile.
+func shortSource(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.SourceKey && len(groups) == 0 {
+		if source, ok := a.Value.Any().(*slog.Source); ok {
+			file := source.File
+			if i := strings.LastIndex(file, "/"); i >= 0 {
+				file = file[i+1:]
+			}
+			return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", file, source.Line))
+		}
 	}
+	return a
+}
+
+// With returns a logger that adds the given key-value pairs to every record.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{handler: slog.New(l.handler).With(args...).Handler()}
+}
+
+// Debug logs a debug message with optional key-value pairs.
+func (l *Logger) Debug(msg string, args ...any) {
+	l.log(context.Background(), slog.LevelDebug, msg, args...)
+}
+
+// Info logs an informational message with optional key-value pairs.
+func (l *Logger) Info(msg string, args ...any) {
+	l.log(context.Background(), slog.LevelInfo, msg, args...)
+}
+
+// Warn logs a warning with optional key-value pairs.
+func (l *Logger) Warn(msg string, args ...any) {
+	l.log(context.Background(), slog.LevelWarn, msg, args...)
+}
+
+// Error logs an error message, recording err under the "error" key, with
+// optional key-value pairs.
+func (l *Logger) Error(msg string, err error, args ...any) {
+	l.log(context.Background(), slog.LevelError, msg, append([]any{"error", err}, args...)...)
+}
 
-	return &Logger{
-		infoLogger:  log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile),
-		errorLogger: log.New(file, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
-		file:        file,
-	}, nil
+// DebugContext is like Debug and also records the request ID stored in ctx.
+func (l *Logger) DebugContext(ctx context.Context, msg string, args ...any) {
+	l.log(ctx, slog.LevelDebug, msg, args...)
 }
 
-// Info logs informational messages to stdout
-func (l *Logger) Info(msg string) {
-	l.infoLogger.Printf("%s", msg)
+// InfoContext is like Info and also records the request ID stored in ctx.
+func (l *Logger) InfoContext(ctx context.Context, msg string, args ...any) {
+	l.log(ctx, slog.LevelInfo, msg, args...)
 }
 
-// Error logs error messages to file
-func (l *Logger) Error(msg string, err error) {
-	l.errorLogger.Printf("%s: %v", msg, err)
+// WarnContext is like Warn and also records the request ID stored in ctx.
+func (l *Logger) WarnContext(ctx context.Context, msg string, args ...any) {
+	l.log(ctx, slog.LevelWarn, msg, args...)
 }
 
-// Close closes the log file
+// ErrorContext is like Error and also records the request ID stored in ctx.
+func (l *Logger) ErrorContext(ctx context.Context, msg string, err error, args ...any) {
+	l.log(ctx, slog.LevelError, msg, append([]any{"error", err}, args...)...)
+}
+
+func (l *Logger) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if !l.handler.Enabled(ctx, level) {
+		return
+	}
+
+	// Skip runtime.Callers, log and the exported method to report the caller
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+
+	record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	record.Add(args...)
+	l.handler.Handle(ctx, record)
+}
+
+// Close flushes and closes the log files. Loggers derived with With share
+// the files of their parent and must not be used after it is closed.
 func (l *Logger) Close() {
-	l.file.Close()
-}
\ No newline at end of file
+	for _, file := range l.files {
+		file.Sync()
+		file.Close()
+	}
+	l.files = nil
+}
+
+// fanoutHandler forwards every record to all of its handlers.
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
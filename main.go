@@ -1,120 +1,206 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"thugcorp.io/nomado/config"
 	"thugcorp.io/nomado/db"
 	"thugcorp.io/nomado/handlers"
 	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/middleware"
 	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/storage"
 )
 
-func initializeLogger() *logger.Logger {
-	logInstance, err := logger.NewLogger("nomado.log")
+func initializeLogger(cfg config.LogConfig) *logger.Logger {
+	logInstance, err := logger.New(logger.Config{
+		Level:   cfg.Level,
+		Format:  cfg.Format,
+		Outputs: cfg.Outputs,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 	return logInstance
 }
 
-func enableCORS(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// newStorage returns the backend keeping uploaded images and, for the local
+// backend, the handler serving them.
+func newStorage(cfg config.StorageConfig) (storage.Storage, http.Handler, error) {
+	if cfg.Backend == "s3" {
+		store, err := storage.NewS3(storage.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			PathStyle:       cfg.S3.PathStyle,
+			PublicURL:       cfg.S3.PublicURL,
+		})
+		return store, nil, err
+	}
+	store, err := storage.NewLocal(cfg.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, store.Handler(), nil
+}
 
-	// Handle preflight requests
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+// displayURL turns a listen address such as ":8080" into a clickable URL.
+func displayURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
 	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
 }
 
-func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		enableCORS(w, r)
-		if r.Method == "OPTIONS" {
+func main() {
+	cfg, args, err := config.Load("nomado", os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
 			return
 		}
-		next(w, r)
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrateCommand(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "gazetteer" {
+		runGazetteerCommand(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 {
+		log.Fatalf("Unknown command %q", args[0])
+	}
+
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func main() {
+// run starts the API server and blocks until it fails or receives SIGINT or
+// SIGTERM, in which case in-flight requests are drained before returning.
+// Deferred cleanup closes the database pool and then flushes the logger.
+func run(cfg *config.Config) error {
 	// Initialize the logger instance
-	logInstance := initializeLogger()
+	logInstance := initializeLogger(cfg.Log)
 	defer logInstance.Close()
 
+	serverCfg := cfg.Server
+
 	// Initialize database
-	database, err := db.NewDatabase()
+	database, err := db.NewDatabase(cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
 		logInstance.Error("Failed to initialize database", err)
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer database.Close()
 
-	// Create tables and seed data
-	if err := database.CreateTables(); err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
-		logInstance.Error("Failed to create tables", err)
+	// Apply pending migrations and seed data
+	if _, err := database.MigrateUp(context.Background()); err != nil {
+		logInstance.Error("Failed to migrate database", err)
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	if err := database.SeedData(); err != nil {
-		log.Printf("Warning: Failed to seed data: %v", err)
-		logInstance.Error("Failed to seed data", err)
+		logInstance.Warn("Failed to seed data", "error", err)
 	}
 
 	// Initialize repositories
 	houseRepo := repository.NewHouseRepository(database.DB)
 	agentRepo := repository.NewAgentRepository(database.DB)
 	houseTypeRepo := repository.NewHouseTypeRepository(database.DB)
+	var geocoder repository.Geocoder
+	if cfg.Geocoding.Provider == "gazetteer" {
+		geocoder = repository.NewGazetteerRepository(database.DB)
+	}
+
+	files, fileHandler, err := newStorage(cfg.Storage)
+	if err != nil {
+		logInstance.Error("Failed to initialize image storage", err)
+		return fmt.Errorf("failed to initialize image storage: %w", err)
+	}
+	uploader := handlers.NewImageUploader(files, int64(cfg.Storage.MaxUploadMB)<<20)
 
 	// Initialize handlers
-	houseHandler := handlers.NewHouseHandler(houseRepo, agentRepo, houseTypeRepo, logInstance)
-
-	// Setup API routes with CORS middleware
-	http.HandleFunc("/api/houses/top", corsMiddleware(houseHandler.GetTopHouses))
-	http.HandleFunc("/api/houses/", corsMiddleware(houseHandler.HandleHousesRoute))
-	http.HandleFunc("/api/houses", corsMiddleware(houseHandler.HandleHousesRoute))
-	http.HandleFunc("/api/agents", corsMiddleware(houseHandler.GetAgents))
-	http.HandleFunc("/api/house-types", corsMiddleware(houseHandler.GetHouseTypes))
-
-	// Health check endpoint
-	http.HandleFunc("/api/health", corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy","service":"nomado-api"}`))
-	}))
-
-	// API info endpoint
-	http.HandleFunc("/api", corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		apiInfo := `{
-			"service": "Nomado Real Estate API",
-			"version": "1.0.0",
-			"endpoints": {
-				"houses": "/api/houses",
-				"top_houses": "/api/houses/top",
-				"house_detail": "/api/houses/{id}",
-				"agents": "/api/agents",
-				"house_types": "/api/house-types",
-				"health": "/api/health"
-			}
-		}`
-		w.Write([]byte(apiInfo))
-	}))
+	houseHandler := handlers.NewHouseHandler(houseRepo, agentRepo, houseTypeRepo, geocoder, logInstance)
+	houseImageHandler := handlers.NewHouseImageHandler(houseRepo, uploader, logInstance)
+	agentHandler := handlers.NewAgentHandler(agentRepo, houseRepo, uploader, logInstance)
+	houseTypeHandler := handlers.NewHouseTypeHandler(houseTypeRepo, logInstance)
+	tagHandler := handlers.NewTagHandler(houseRepo, logInstance)
+	geocodeHandler := handlers.NewGeocodeHandler(geocoder, logInstance)
+
+	routes := handlers.NewRouter(logInstance, houseHandler, houseImageHandler, agentHandler, houseTypeHandler, tagHandler, geocodeHandler, fileHandler)
+
+	server := &http.Server{
+		Addr:              serverCfg.Addr,
+		Handler:           middleware.AccessLog(logInstance, middleware.CORS(middleware.Timeout(serverCfg.RequestTimeout, routes))),
+		ReadHeaderTimeout: serverCfg.ReadTimeout,
+		ReadTimeout:       serverCfg.ReadTimeout,
+		WriteTimeout:      serverCfg.WriteTimeout,
+		IdleTimeout:       serverCfg.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		runTrashPurge(ctx, houseRepo, files, cfg.Trash, logInstance)
+	}()
 
 	fmt.Println("🚀 Nomado Real Estate API Server starting...")
-	logInstance.Info("API Server starting on :8080")
-	fmt.Printf("📡 API endpoints available at: http://localhost:8080/api\n")
-	fmt.Printf("🔍 Health check: http://localhost:8080/api/health\n")
+	logInstance.Info("API Server starting", "addr", serverCfg.Addr)
+	baseURL := displayURL(serverCfg.Addr)
+	fmt.Printf("📡 API endpoints available at: %s/api\n", baseURL)
+	fmt.Printf("🔍 Health check: %s/api/health\n", baseURL)
 
-	const addr = ":8080"
-	err = http.ListenAndServe(addr, nil)
-	if err != nil {
-		log.Fatalf("Server has failed: %v", err)
+	select {
+	case err := <-serveErr:
 		logInstance.Error("Server has failed", err)
+		stop()
+		<-purgeDone
+		return fmt.Errorf("server has failed: %w", err)
+	case <-ctx.Done():
 	}
+	// A second signal kills the process instead of waiting for the drain
+	stop()
+	// The purge must not outlive the database pool closed on return
+	<-purgeDone
+
+	logInstance.Info("Shutting down, draining in-flight requests", "timeout", serverCfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverCfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logInstance.Error("Graceful shutdown failed, closing remaining connections", err)
+		server.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logInstance.Error("Server has failed", err)
+	}
+
+	logInstance.Info("Server stopped")
+	return nil
 }
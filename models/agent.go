@@ -5,4 +5,7 @@ type Agent struct {
 	FirstName string  `json:"first_name"`
 	LastName  string  `json:"last_name"`
 	ImageURL  *string `json:"image_url"` // nullable
+	// ImageRenditions are set while the image is an upload, whose URL is the
+	// large one
+	ImageRenditions *Renditions `json:"image_renditions,omitempty"`
 }
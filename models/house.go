@@ -1,14 +1,66 @@
 package models
 
+import (
+	"time"
+
+	"thugcorp.io/nomado/money"
+)
+
+// Units of House.FloorArea and House.LotSize
+const (
+	AreaUnitSquareMetres = "sqm"
+	AreaUnitSquareFeet   = "sqft"
+)
+
 type House struct {
-	ID          int     `json:"id"`
-	Name		string  `json:"name"`
-	Description string  `json:"description"`
-	HouseTypeID int     `json:"house_type_id"`
-	Price       float64 `json:"price"`
-	Tags		[]string `json:"tags"`
-	ImageURL	*string `json:"image_url"` // nullable
-	CreatedAt   string  `json:"created_at"`
-	UpdatedAt   string  `json:"updated_at"`
-	AgentID     int     `json:"agent_id"`
-}
\ No newline at end of file
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	HouseTypeID int          `json:"house_type_id"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"` // ISO 4217 code of Price
+	Tags        []string     `json:"tags"`
+	ImageURL    *string      `json:"image_url"` // nullable
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	AgentID     int          `json:"agent_id"`
+	Version     int          `json:"-"`                    // sent as the ETag header
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty"` // set while in the trash
+
+	// Property attributes, nil when unknown
+	Bedrooms      *int     `json:"bedrooms"`
+	Bathrooms     *float64 `json:"bathrooms"`  // in halves, e.g. 2.5
+	FloorArea     *float64 `json:"floor_area"` // in AreaUnit
+	LotSize       *float64 `json:"lot_size"`   // in AreaUnit
+	AreaUnit      string   `json:"area_unit"`  // AreaUnitSquareMetres or AreaUnitSquareFeet
+	YearBuilt     *int     `json:"year_built"`
+	ParkingSpaces *int     `json:"parking_spaces"`
+	Floors        *int     `json:"floors"`
+
+	// Location, with both coordinates or neither
+	Address   Address  `json:"address"`
+	Latitude  *float64 `json:"latitude"`  // WGS 84 degrees, north positive
+	Longitude *float64 `json:"longitude"` // WGS 84 degrees, east positive
+}
+
+// Address is the postal address of a house. Unknown parts are empty.
+type Address struct {
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	Region     string `json:"region"` // state, province or county
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"` // ISO 3166-1 alpha-2 code, e.g. US
+}
+
+// HouseWithDetails is a house together with its agent, house type and
+// photo gallery.
+type HouseWithDetails struct {
+	House
+	Agent     *Agent     `json:"agent,omitempty"`
+	HouseType *HouseType `json:"house_type,omitempty"`
+	// Images is the gallery in display order, empty rather than nil
+	Images []HouseImage `json:"images"`
+
+	// DistanceKm is set when houses are listed near a point.
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+}
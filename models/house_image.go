@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// HouseImage is a photo in the gallery of a house.
+type HouseImage struct {
+	ID        int       `json:"id"`
+	HouseID   int       `json:"house_id"`
+	URL       string    `json:"url"`
+	Caption   string    `json:"caption"`
+	AltText   string    `json:"alt_text"` // describes the photo to screen readers
+	Position  int       `json:"position"` // place in the gallery, from 0
+	IsCover   bool      `json:"is_cover"` // the photo shown first in listings
+	CreatedAt time.Time `json:"created_at"`
+	// Renditions are set for uploaded photos, whose URL is the large one
+	Renditions *Renditions `json:"renditions,omitempty"`
+}
@@ -1,6 +1,12 @@
 package models
 
 type HouseType struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// HouseTypeWithCount is a house type with the number of houses using it.
+type HouseTypeWithCount struct {
+	HouseType
+	HouseCount int `json:"house_count"`
 }
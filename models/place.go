@@ -0,0 +1,14 @@
+package models
+
+// Place is a named location of the gazetteer used for geocoding, such as a
+// town or the area of a postal code.
+type Place struct {
+	Name       string   `json:"name"`
+	Region     string   `json:"region"`  // state, province or county, or its code
+	Country    string   `json:"country"` // ISO 3166-1 alpha-2 code
+	PostalCode string   `json:"postal_code"`
+	Latitude   float64  `json:"latitude"`
+	Longitude  float64  `json:"longitude"`
+	Population int64    `json:"population"`
+	DistanceKm *float64 `json:"distance_km,omitempty"` // set by reverse geocoding
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Renditions are the URLs of the resized copies made of an uploaded image.
+// The longer side of each is at most 320, 1024 and 2048 pixels.
+type Renditions struct {
+	Thumbnail string `json:"thumbnail"`
+	Medium    string `json:"medium"`
+	Large     string `json:"large"`
+}
+
+// URLs lists the renditions, smallest first.
+func (r Renditions) URLs() []string {
+	return []string{r.Thumbnail, r.Medium, r.Large}
+}
+
+// Value stores the renditions in a JSONB column.
+func (r Renditions) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan reads a JSONB column.
+func (r *Renditions) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, r)
+	case string:
+		return json.Unmarshal([]byte(src), r)
+	default:
+		return fmt.Errorf("cannot scan %T into models.Renditions", src)
+	}
+}
@@ -0,0 +1,7 @@
+package models
+
+// TagCount is a tag with the number of houses carrying it.
+type TagCount struct {
+	Tag        string `json:"tag"`
+	HouseCount int    `json:"house_count"`
+}
@@ -0,0 +1,149 @@
+// Package money represents prices exactly, as whole numbers of cents, rather
+// than as binary floating point numbers.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of prices given without one.
+const DefaultCurrency = "USD"
+
+// Amount is an exact amount of money in minor units, i.e. cents. It reads and
+// writes the decimal notation used by DECIMAL(12,2) columns and by JSON.
+type Amount int64
+
+// Unit is one major unit of currency, such as one dollar.
+const Unit Amount = 100
+
+// maxExponent bounds the exponent accepted by Parse so that inputs such as
+// "1e999999999" fail fast instead of building huge numbers.
+const maxExponent = 30
+
+// decimalPattern is the plain decimal notation accepted by Parse. It keeps
+// out the other forms big.Rat reads, such as "0x10", "0b11", "1_000" or "1/3".
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// ErrInvalidAmount is returned by Parse for anything but a decimal number
+// with at most two decimal places that fits an Amount.
+var ErrInvalidAmount = errors.New("invalid amount of money")
+
+// Parse parses a decimal number such as "1250", "-3.5", "0.05" or "1e6". It
+// rejects amounts with fractions of a cent rather than rounding them.
+func Parse(s string) (Amount, error) {
+	if !decimalPattern.MatchString(s) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if _, exponent, ok := strings.Cut(strings.ToLower(s), "e"); ok {
+		exp, err := strconv.Atoi(exponent)
+		if err != nil || exp > maxExponent || exp < -maxExponent {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+		}
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	r.Mul(r, big.NewRat(int64(Unit), 1))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	return Amount(r.Num().Int64()), nil
+}
+
+// String formats the amount with two decimals, e.g. "1250.00".
+func (a Amount) String() string {
+	sign := ""
+	cents := uint64(a)
+	if a < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// Float64 returns the amount in major units. It is exact up to 2^53 cents.
+func (a Amount) Float64() float64 {
+	return float64(a) / float64(Unit)
+}
+
+// MarshalJSON writes the amount as a JSON number with two decimals.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number, or a string holding one. A value that is
+// not an exact amount is reported as a *json.UnmarshalTypeError.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	amount, err := Parse(text)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: string(data), Type: reflect.TypeFor[Amount]()}
+	}
+	*a = amount
+	return nil
+}
+
+// JSONType describes the JSON values accepted by UnmarshalJSON.
+func (Amount) JSONType() string {
+	return "a number with at most two decimals"
+}
+
+// Value passes the amount to the database in decimal notation, which
+// PostgreSQL converts to DECIMAL without loss.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads a DECIMAL column.
+func (a *Amount) Scan(src interface{}) error {
+	var text string
+	switch src := src.(type) {
+	case []byte:
+		text = string(src)
+	case string:
+		text = src
+	case int64:
+		*a = Amount(src) * Unit
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+
+	amount, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code:
+// three upper case letters.
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	valid := map[string]Amount{
+		"1250":    125000,
+		"1250.5":  125050,
+		"0.05":    5,
+		"-3.5":    -350,
+		"1e6":     100000000,
+		"1.25E2":  12500,
+		"0.10000": 10,
+		".5":      50,
+		"+2":      200,
+	}
+	for s, want := range valid {
+		if got, err := Parse(s); err != nil || got != want {
+			t.Errorf("Parse(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+
+	invalid := []string{
+		"", "abc", "0.005", "1/3", "0x10", "1e999999999", "99999999999999999999", "1.2.3",
+		// Notations big.Rat accepts but prices are never written in
+		"0X10", "-0x10", "0b11", "0o7", "1_000", " 1", "e5", "1e", "Inf", "NaN",
+	}
+	for _, s := range invalid {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Parse(%q): expected ErrInvalidAmount, got %v", s, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := map[Amount]string{
+		0:        "0.00",
+		5:        "0.05",
+		125050:   "1250.50",
+		-350:     "-3.50",
+		1e12 - 1: "9999999999.99",
+	}
+	for amount, want := range tests {
+		if got := amount.String(); got != want {
+			t.Errorf("Amount(%d).String() = %q, want %q", amount, got, want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Price Amount `json:"price"`
+	}
+	if err := json.Unmarshal([]byte(`{"price":850000.1}`), &v); err != nil || v.Price != 85000010 {
+		t.Fatalf("Unmarshal = %d, %v", v.Price, err)
+	}
+	if err := json.Unmarshal([]byte(`{"price":"12.34"}`), &v); err != nil || v.Price != 1234 {
+		t.Errorf("Unmarshal of a string = %d, %v", v.Price, err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal([]byte(`{"price":0.001}`), &v); !errors.As(err, &typeErr) {
+		t.Errorf("Unmarshal of a fraction of a cent: expected a type error, got %v", err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil || string(data) != `{"price":12.34}` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+}
+
+func TestValidCurrency(t *testing.T) {
+	for code, want := range map[string]bool{"USD": true, "EUR": true, "usd": false, "US": false, "USDT": false, "U$D": false} {
+		if got := ValidCurrency(code); got != want {
+			t.Errorf("ValidCurrency(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"thugcorp.io/nomado/config"
+	"thugcorp.io/nomado/logger"
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/storage"
+)
+
+// runTrashPurge removes houses that have been in the trash for longer than
+// the configured retention, with the stored files of their uploaded photos,
+// once at startup and then every purge interval, until ctx is done.
+func runTrashPurge(ctx context.Context, houses repository.HouseStore, files storage.Storage, cfg config.TrashConfig, logger *logger.Logger) {
+	ticker := time.NewTicker(cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, uploads, err := houses.PurgeDeletedHouses(ctx, time.Now().Add(-cfg.Retention))
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Error("Failed to purge deleted houses", err)
+		case purged > 0:
+			logger.Info("Purged deleted houses", "count", purged, "retention", cfg.Retention)
+		}
+		deleteUploads(ctx, files, uploads, logger)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteUploads deletes the stored files of the renditions of purged photos.
+// Failures are logged with the key, so that the file can be removed by hand.
+func deleteUploads(ctx context.Context, files storage.Storage, uploads []models.Renditions, logger *logger.Logger) {
+	// The rows are gone, so the files go even if the server is stopping
+	ctx = context.WithoutCancel(ctx)
+	for _, renditions := range uploads {
+		for _, url := range renditions.URLs() {
+			key, ok := files.Key(url)
+			if !ok {
+				continue
+			}
+			if err := files.Delete(ctx, key); err != nil {
+				logger.Warn("Failed to delete stored image", "key", key, "error", err)
+			}
+		}
+	}
+}
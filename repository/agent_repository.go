@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -15,14 +16,14 @@ func NewAgentRepository(db *sql.DB) *AgentRepository {
 	return &AgentRepository{db: db}
 }
 
-func (ar *AgentRepository) GetAllAgents() ([]models.Agent, error) {
+func (ar *AgentRepository) GetAllAgents(ctx context.Context) ([]models.Agent, error) {
 	query := `
-		SELECT id, first_name, last_name, image_url
+		SELECT id, first_name, last_name, image_url, image_renditions
 		FROM agents
 		ORDER BY first_name, last_name
 	`
 
-	rows, err := ar.db.Query(query)
+	rows, err := ar.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query agents: %w", err)
 	}
@@ -31,7 +32,7 @@ func (ar *AgentRepository) GetAllAgents() ([]models.Agent, error) {
 	var agents []models.Agent
 	for rows.Next() {
 		var agent models.Agent
-		err := rows.Scan(&agent.ID, &agent.FirstName, &agent.LastName, &agent.ImageURL)
+		err := rows.Scan(&agent.ID, &agent.FirstName, &agent.LastName, &agent.ImageURL, &agent.ImageRenditions)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan agent: %w", err)
 		}
@@ -41,21 +42,21 @@ func (ar *AgentRepository) GetAllAgents() ([]models.Agent, error) {
 	return agents, nil
 }
 
-func (ar *AgentRepository) GetAgentByID(id int) (*models.Agent, error) {
+func (ar *AgentRepository) GetAgentByID(ctx context.Context, id int) (*models.Agent, error) {
 	query := `
-		SELECT id, first_name, last_name, image_url
+		SELECT id, first_name, last_name, image_url, image_renditions
 		FROM agents
 		WHERE id = $1
 	`
 
 	var agent models.Agent
-	err := ar.db.QueryRow(query, id).Scan(
-		&agent.ID, &agent.FirstName, &agent.LastName, &agent.ImageURL,
+	err := ar.db.QueryRowContext(ctx, query, id).Scan(
+		&agent.ID, &agent.FirstName, &agent.LastName, &agent.ImageURL, &agent.ImageRenditions,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("agent with id %d not found", id)
+			return nil, fmt.Errorf("agent with id %d %w", id, ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to query agent: %w", err)
 	}
@@ -63,54 +64,78 @@ func (ar *AgentRepository) GetAgentByID(id int) (*models.Agent, error) {
 	return &agent, nil
 }
 
-func (ar *AgentRepository) CreateAgent(agent *models.Agent) error {
+func (ar *AgentRepository) CreateAgent(ctx context.Context, agent *models.Agent) error {
 	query := `
-		INSERT INTO agents (first_name, last_name, image_url)
-		VALUES ($1, $2, $3)
+		INSERT INTO agents (first_name, last_name, image_url, image_renditions)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id
 	`
 
-	err := ar.db.QueryRow(
-		query, agent.FirstName, agent.LastName, agent.ImageURL,
+	err := ar.db.QueryRowContext(
+		ctx, query, agent.FirstName, agent.LastName, agent.ImageURL, agent.ImageRenditions,
 	).Scan(&agent.ID)
 
 	if err != nil {
-		return fmt.Errorf("failed to create agent: %w", err)
+		return fmt.Errorf("failed to create agent: %w", translatePQError(err))
 	}
 
 	return nil
 }
 
-func (ar *AgentRepository) UpdateAgent(agent *models.Agent) error {
+// UpdateAgent replaces the names and image URL of an agent. The image
+// renditions are kept while the image URL is unchanged and cleared otherwise;
+// agent.ImageRenditions is set to the result.
+func (ar *AgentRepository) UpdateAgent(ctx context.Context, agent *models.Agent) error {
 	query := `
-		UPDATE agents 
-		SET first_name = $1, last_name = $2, image_url = $3
+		UPDATE agents
+		SET first_name = $1, last_name = $2,
+			image_renditions = CASE WHEN image_url IS NOT DISTINCT FROM $3 THEN image_renditions END,
+			image_url = $3
 		WHERE id = $4
+		RETURNING image_renditions
 	`
 
-	result, err := ar.db.Exec(
-		query, agent.FirstName, agent.LastName, agent.ImageURL, agent.ID,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to update agent: %w", err)
+	err := ar.db.QueryRowContext(
+		ctx, query, agent.FirstName, agent.LastName, agent.ImageURL, agent.ID,
+	).Scan(&agent.ImageRenditions)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("agent with id %d %w", agent.ID, ErrNotFound)
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to update agent: %w", translatePQError(err))
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("agent with id %d not found", agent.ID)
+	return nil
+}
+
+// SetAgentImage makes the large rendition of an uploaded image the image of
+// an agent and returns the agent.
+func (ar *AgentRepository) SetAgentImage(ctx context.Context, id int, renditions models.Renditions) (*models.Agent, error) {
+	query := `
+		UPDATE agents
+		SET image_url = $1, image_renditions = $2
+		WHERE id = $3
+		RETURNING id, first_name, last_name, image_url, image_renditions
+	`
+
+	var agent models.Agent
+	err := ar.db.QueryRowContext(ctx, query, renditions.Large, renditions, id).Scan(
+		&agent.ID, &agent.FirstName, &agent.LastName, &agent.ImageURL, &agent.ImageRenditions,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("agent with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set agent image: %w", translatePQError(err))
 	}
 
-	return nil
+	return &agent, nil
 }
 
-func (ar *AgentRepository) DeleteAgent(id int) error {
+func (ar *AgentRepository) DeleteAgent(ctx context.Context, id int) error {
 	query := `DELETE FROM agents WHERE id = $1`
 
-	result, err := ar.db.Exec(query, id)
+	result, err := ar.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete agent: %w", err)
 	}
@@ -121,7 +146,7 @@ func (ar *AgentRepository) DeleteAgent(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("agent with id %d not found", id)
+		return fmt.Errorf("agent with id %d %w", id, ErrNotFound)
 	}
 
 	return nil
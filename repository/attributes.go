@@ -0,0 +1,42 @@
+package repository
+
+import "thugcorp.io/nomado/models"
+
+// Range is an inclusive range of an optional numeric attribute. Nil bounds
+// are open. A house whose value is unknown falls outside every range with a
+// bound, like NULL in SQL.
+type Range[T int | float64] struct {
+	Min, Max *T
+}
+
+// IsSet reports whether the range has a bound.
+func (r Range[T]) IsSet() bool {
+	return r.Min != nil || r.Max != nil
+}
+
+// Contains reports whether value lies in the range.
+func (r Range[T]) Contains(value *T) bool {
+	if !r.IsSet() {
+		return true
+	}
+	if value == nil {
+		return false
+	}
+	return (r.Min == nil || *value >= *r.Min) && (r.Max == nil || *value <= *r.Max)
+}
+
+// squareFoot is the area of one square foot in square metres.
+const squareFoot = 0.09290304
+
+// SquareMetres converts an area measured in unit to square metres.
+func SquareMetres(area float64, unit string) float64 {
+	if unit == models.AreaUnitSquareFeet {
+		return area * squareFoot
+	}
+	return area
+}
+
+// ValidAreaUnit reports whether unit is one of the supported area units.
+func ValidAreaUnit(unit string) bool {
+	return unit == models.AreaUnitSquareMetres || unit == models.AreaUnitSquareFeet
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrNotFound is returned, wrapped with the entity and id, when a lookup,
+// update or delete matches no rows. Check for it with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// ErrVersionMismatch is returned when a conditional write names a version
+// that is no longer current because the row was changed in the meantime.
+var ErrVersionMismatch = errors.New("has been modified")
+
+// ErrConflict is returned when a write would violate a unique constraint.
+var ErrConflict = errors.New("already exists")
+
+// ErrForeignKey is returned when a write references a row that does not
+// exist, such as a house with an unknown agent_id.
+var ErrForeignKey = errors.New("references a missing record")
+
+// ErrValidation is returned when a value does not fit its column, for example
+// a string that is too long or a number that is out of range.
+var ErrValidation = errors.New("invalid value")
+
+// ColumnError wraps one of the sentinel errors with the column it concerns.
+type ColumnError struct {
+	Column string
+	Err    error
+}
+
+func (e *ColumnError) Error() string {
+	return e.Column + " " + e.Err.Error()
+}
+
+func (e *ColumnError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorColumn returns the column named by a ColumnError in the chain of err,
+// or "" if there is none.
+func ErrorColumn(err error) string {
+	var columnErr *ColumnError
+	if errors.As(err, &columnErr) {
+		return columnErr.Column
+	}
+	return ""
+}
+
+// PostgreSQL error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqUniqueViolation      = "23505"
+	pqForeignKeyViolation  = "23503"
+	pqNotNullViolation     = "23502"
+	pqCheckViolation       = "23514"
+	pqStringTooLong        = "22001"
+	pqNumericOutOfRange    = "22003"
+	pqInvalidTextRepresent = "22P02"
+)
+
+// hasPQCode reports whether err wraps a PostgreSQL error with the given code.
+func hasPQCode(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
+
+// translatePQError wraps constraint and data errors reported by PostgreSQL in
+// the matching sentinel error, naming the offending column when known. The
+// original error stays in the chain. Other errors are returned unchanged.
+func translatePQError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	var sentinel error
+	switch pqErr.Code {
+	case pqUniqueViolation:
+		sentinel = ErrConflict
+	case pqForeignKeyViolation:
+		sentinel = ErrForeignKey
+	case pqNotNullViolation, pqCheckViolation, pqStringTooLong, pqNumericOutOfRange, pqInvalidTextRepresent:
+		sentinel = ErrValidation
+	default:
+		return err
+	}
+
+	if column := constraintColumn(pqErr); column != "" {
+		return &ColumnError{Column: column, Err: fmt.Errorf("%w: %w", sentinel, err)}
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// constraintColumn returns the column named by a PostgreSQL error, falling
+// back to the column part of a <table>_<column>_<suffix> constraint name.
+func constraintColumn(pqErr *pq.Error) string {
+	if pqErr.Column != "" {
+		return pqErr.Column
+	}
+	name := strings.TrimPrefix(pqErr.Constraint, pqErr.Table+"_")
+	if i := strings.LastIndex(name, "_"); i > 0 && name != pqErr.Constraint {
+		return name[:i]
+	}
+	return ""
+}
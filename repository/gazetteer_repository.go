@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"strings"
+
+	"github.com/lib/pq"
+	"thugcorp.io/nomado/models"
+)
+
+// GazetteerRepository geocodes from the places table, filled by ImportPlaces
+// from GeoNames or OpenStreetMap extracts. It needs no network access.
+type GazetteerRepository struct {
+	db *sql.DB
+}
+
+func NewGazetteerRepository(db *sql.DB) *GazetteerRepository {
+	return &GazetteerRepository{db: db}
+}
+
+const placeColumns = `p.name, p.region, p.country, p.postal_code, p.latitude, p.longitude, p.population`
+
+func scanPlace(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.Place, error) {
+	var place models.Place
+	dest := []interface{}{
+		&place.Name, &place.Region, &place.Country, &place.PostalCode,
+		&place.Latitude, &place.Longitude, &place.Population,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return place, err
+}
+
+// Geocode scores places like GeocodeQuery.Score. Candidates are found
+// through the indexes on lower(name) and lower(postal_code).
+func (gr *GazetteerRepository) Geocode(ctx context.Context, query string, limit int) ([]models.Place, error) {
+	q := ParseGeocodeQuery(query)
+	if len(q.Terms) == 0 {
+		return nil, nil
+	}
+
+	var qb queryBuilder
+	terms := qb.arg(pq.Array(q.Terms))
+	// NULL never matches, so a short first part matches no prefixes
+	var prefix interface{}
+	if q.Prefix != "" {
+		prefix = likeEscaper.Replace(q.Prefix) + "%"
+	}
+	like := qb.arg(prefix)
+
+	sqlQuery := `SELECT ` + placeColumns + ` FROM (
+			SELECT p.*,
+				CASE WHEN lower(p.name) = ANY(` + terms + `::text[]) THEN 4
+					WHEN lower(p.name) LIKE ` + like + `::text THEN 1 ELSE 0 END +
+				CASE WHEN p.postal_code <> '' AND lower(p.postal_code) = ANY(` + terms + `::text[]) THEN 4 ELSE 0 END +
+				CASE WHEN p.region <> '' AND lower(p.region) = ANY(` + terms + `::text[]) THEN 2 ELSE 0 END +
+				CASE WHEN p.country <> '' AND lower(p.country) = ANY(` + terms + `::text[]) THEN 2 ELSE 0 END AS score
+			FROM places p
+			WHERE lower(p.name) = ANY(` + terms + `::text[]) OR lower(p.name) LIKE ` + like + `::text
+				OR lower(p.postal_code) = ANY(` + terms + `::text[])
+		) p
+		ORDER BY p.score DESC, p.population DESC, p.name, p.id
+		LIMIT ` + qb.arg(limit)
+
+	rows, err := gr.db.QueryContext(ctx, sqlQuery, qb.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode: %w", err)
+	}
+	defer rows.Close()
+
+	var places []models.Place
+	for rows.Next() {
+		place, err := scanPlace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan place: %w", err)
+		}
+		places = append(places, place)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate places: %w", err)
+	}
+
+	return places, nil
+}
+
+// likeEscaper escapes the LIKE wildcards of a literal prefix.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// reverseCandidates is how many of the places nearest on the flat
+// longitude/latitude plane, as served by the GiST index, are ranked by
+// great-circle distance.
+const reverseCandidates = 50
+
+// ReverseGeocode ranks the places nearest on the plane by their true
+// distance. Near the poles and the antimeridian the plane distorts distances,
+// which the candidate margin absorbs for all but remote places.
+func (gr *GazetteerRepository) ReverseGeocode(ctx context.Context, p Point, limit int) ([]models.Place, error) {
+	var qb queryBuilder
+	origin := `point(` + qb.arg(p.Lng) + `::float8, ` + qb.arg(p.Lat) + `::float8)`
+	candidates := qb.arg(max(limit, reverseCandidates))
+	sqlQuery := `SELECT ` + placeColumns + `, ` + distanceSQL(&qb, "p", p) + ` AS distance_km
+		FROM (
+			SELECT * FROM places
+			ORDER BY point(longitude, latitude) <-> ` + origin + `
+			LIMIT ` + candidates + `
+		) p
+		ORDER BY distance_km, p.id
+		LIMIT ` + qb.arg(limit)
+
+	rows, err := gr.db.QueryContext(ctx, sqlQuery, qb.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reverse geocode: %w", err)
+	}
+	defer rows.Close()
+
+	var places []models.Place
+	for rows.Next() {
+		var distance float64
+		place, err := scanPlace(rows, &distance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan place: %w", err)
+		}
+		place.DistanceKm = &distance
+		places = append(places, place)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate places: %w", err)
+	}
+
+	return places, nil
+}
+
+// ImportPlaces streams the places into the table with COPY in a single
+// transaction.
+func (gr *GazetteerRepository) ImportPlaces(ctx context.Context, places iter.Seq2[models.Place, error], replace bool) (int, error) {
+	tx, err := gr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.ExecContext(ctx, `TRUNCATE places RESTART IDENTITY`); err != nil {
+			return 0, fmt.Errorf("failed to clear places: %w", err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("places",
+		"name", "region", "country", "postal_code", "latitude", "longitude", "population"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to start copying places: %w", err)
+	}
+	defer stmt.Close()
+
+	imported := 0
+	for place, err := range places {
+		if err != nil {
+			return 0, err
+		}
+		if err := CheckPlace(place); err != nil {
+			return 0, err
+		}
+		if _, err := stmt.ExecContext(ctx, place.Name, place.Region, place.Country, place.PostalCode,
+			place.Latitude, place.Longitude, place.Population); err != nil {
+			return 0, fmt.Errorf("failed to copy place: %w", translatePQError(err))
+		}
+		imported++
+	}
+	// Flushes the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, fmt.Errorf("failed to copy places: %w", translatePQError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit places: %w", err)
+	}
+	return imported, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"math"
+	"regexp"
+)
+
+// EarthRadiusKm is the mean radius of the Earth used for distances.
+const EarthRadiusKm = 6371.0088
+
+// Point is a WGS 84 position in degrees.
+type Point struct {
+	Lat, Lng float64
+}
+
+// BoundingBox is an area between two parallels and two meridians. A box
+// whose West edge lies east of its East edge crosses the antimeridian.
+type BoundingBox struct {
+	West, South, East, North float64
+}
+
+// Contains reports whether p lies in the box, edges included.
+func (b BoundingBox) Contains(p Point) bool {
+	if p.Lat < b.South || p.Lat > b.North {
+		return false
+	}
+	if b.West <= b.East {
+		return p.Lng >= b.West && p.Lng <= b.East
+	}
+	return p.Lng >= b.West || p.Lng <= b.East
+}
+
+// split returns the box as one or two boxes that do not cross the
+// antimeridian.
+func (b BoundingBox) split() []BoundingBox {
+	if b.West <= b.East {
+		return []BoundingBox{b}
+	}
+	return []BoundingBox{
+		{West: b.West, South: b.South, East: 180, North: b.North},
+		{West: -180, South: b.South, East: b.East, North: b.North},
+	}
+}
+
+// DistanceKm returns the great-circle distance between two points using the
+// haversine formula.
+func DistanceKm(a, b Point) float64 {
+	dLat := radians(b.Lat - a.Lat)
+	dLng := radians(b.Lng - a.Lng)
+	h := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*math.Pow(math.Sin(dLng/2), 2)
+	return EarthRadiusKm * 2 * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// CircleBounds returns the smallest box holding every point within radiusKm
+// of center, so that an index can narrow a radius search before distances
+// are computed.
+func CircleBounds(center Point, radiusKm float64) BoundingBox {
+	angle := radiusKm / EarthRadiusKm
+	south, north := center.Lat-degrees(angle), center.Lat+degrees(angle)
+	if south <= -90 || north >= 90 {
+		// The circle holds a pole and so every longitude
+		return BoundingBox{West: -180, South: max(south, -90), East: 180, North: min(north, 90)}
+	}
+
+	dLng := degrees(math.Asin(math.Sin(angle) / math.Cos(radians(center.Lat))))
+	west, east := center.Lng-dLng, center.Lng+dLng
+	if west < -180 {
+		west += 360
+	}
+	if east > 180 {
+		east -= 360
+	}
+	return BoundingBox{West: west, South: south, East: east, North: north}
+}
+
+// ValidCoordinates reports whether lat and lng are a position on Earth.
+func ValidCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// ValidCountry reports whether code looks like an ISO 3166-1 alpha-2 code.
+// An empty code stands for an unknown country.
+func ValidCountry(code string) bool {
+	return code == "" || countryCode.MatchString(code)
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
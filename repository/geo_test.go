@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	london := Point{Lat: 51.5074, Lng: -0.1278}
+	paris := Point{Lat: 48.8566, Lng: 2.3522}
+	if got := DistanceKm(london, paris); math.Abs(got-343.5) > 1 {
+		t.Errorf("DistanceKm(London, Paris) = %.1f, want about 343.5", got)
+	}
+	if got := DistanceKm(paris, paris); got != 0 {
+		t.Errorf("DistanceKm(Paris, Paris) = %v, want 0", got)
+	}
+	// Across the antimeridian, one degree of longitude at the equator
+	if got := DistanceKm(Point{Lng: 179.5}, Point{Lng: -179.5}); math.Abs(got-111.2) > 0.1 {
+		t.Errorf("DistanceKm across the antimeridian = %.1f, want about 111.2", got)
+	}
+}
+
+func TestBoundingBoxContains(t *testing.T) {
+	box := BoundingBox{West: -10, South: 40, East: 10, North: 60}
+	pacific := BoundingBox{West: 170, South: -20, East: -170, North: 20}
+	tests := []struct {
+		box  BoundingBox
+		p    Point
+		want bool
+	}{
+		{box, Point{Lat: 50, Lng: 0}, true},
+		{box, Point{Lat: 60, Lng: 10}, true},
+		{box, Point{Lat: 61, Lng: 0}, false},
+		{box, Point{Lat: 50, Lng: 11}, false},
+		{pacific, Point{Lat: 0, Lng: 175}, true},
+		{pacific, Point{Lat: 0, Lng: -175}, true},
+		{pacific, Point{Lat: 0, Lng: 0}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.box.Contains(tt.p); got != tt.want {
+			t.Errorf("%+v.Contains(%+v) = %v, want %v", tt.box, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestCircleBounds(t *testing.T) {
+	centers := []Point{
+		{Lat: 30.27, Lng: -97.74},
+		{Lat: -33.87, Lng: 151.21},
+		{Lat: 0, Lng: 179.9}, // crosses the antimeridian
+		{Lat: 89.9, Lng: 0},  // holds the north pole
+	}
+	for _, center := range centers {
+		const radius = 50
+		box := CircleBounds(center, radius)
+		// Points on the circle in every direction lie in the box
+		for bearing := 0.0; bearing < 360; bearing += 15 {
+			p := destination(center, bearing, radius*0.999)
+			if !box.Contains(p) {
+				t.Errorf("CircleBounds(%+v) = %+v does not contain %+v at bearing %v", center, box, p, bearing)
+			}
+		}
+	}
+}
+
+// destination returns the point distanceKm from start along bearing degrees.
+func destination(start Point, bearing, distanceKm float64) Point {
+	angle := distanceKm / EarthRadiusKm
+	lat1, lng1, theta := radians(start.Lat), radians(start.Lng), radians(bearing)
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(angle) + math.Cos(lat1)*math.Sin(angle)*math.Cos(theta))
+	lng2 := lng1 + math.Atan2(math.Sin(theta)*math.Sin(angle)*math.Cos(lat1), math.Cos(angle)-math.Sin(lat1)*math.Sin(lat2))
+	lng := math.Remainder(degrees(lng2), 360)
+	return Point{Lat: degrees(lat2), Lng: lng}
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"thugcorp.io/nomado/models"
+)
+
+// Column sizes of the places table
+const (
+	maxPlaceNameLength   = 200
+	maxPlaceRegionLength = 100
+	maxPlacePostalLength = 20
+)
+
+// minPrefixLength is the shortest query part that matches the start of place
+// names, so that "aus" finds Austin but "a" finds nothing.
+const minPrefixLength = 3
+
+// GeocodeQuery is a free-text geocoding query split into its lower-cased,
+// comma-separated parts.
+type GeocodeQuery struct {
+	Terms []string
+	// Prefix is the first part if it is long enough to match name prefixes
+	Prefix string
+}
+
+// ParseGeocodeQuery splits a query such as "Austin, TX, US" into its parts.
+func ParseGeocodeQuery(query string) GeocodeQuery {
+	var q GeocodeQuery
+	for _, part := range strings.Split(query, ",") {
+		if part = strings.ToLower(strings.Join(strings.Fields(part), " ")); part != "" {
+			q.Terms = append(q.Terms, part)
+		}
+	}
+	if len(q.Terms) > 0 && utf8.RuneCountInString(q.Terms[0]) >= minPrefixLength {
+		q.Prefix = q.Terms[0]
+	}
+	return q
+}
+
+// Score rates how well a place matches the query, 0 meaning not at all. A
+// place must match a part by name or postal code, or start with the prefix;
+// parts naming its region or country raise the score.
+func (q GeocodeQuery) Score(place models.Place) int {
+	score := 0
+	switch name := strings.ToLower(place.Name); {
+	case q.has(name):
+		score += 4
+	case q.Prefix != "" && strings.HasPrefix(name, q.Prefix):
+		score++
+	}
+	if place.PostalCode != "" && q.has(strings.ToLower(place.PostalCode)) {
+		score += 4
+	}
+	if score == 0 {
+		return 0
+	}
+	if place.Region != "" && q.has(strings.ToLower(place.Region)) {
+		score += 2
+	}
+	if place.Country != "" && q.has(strings.ToLower(place.Country)) {
+		score += 2
+	}
+	return score
+}
+
+func (q GeocodeQuery) has(value string) bool {
+	for _, term := range q.Terms {
+		if term == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AddressQuery returns the geocoding query for an address, or "" if the
+// address names neither a city nor a postal code. The street is left out:
+// the gazetteer knows towns and postal codes only.
+func AddressQuery(address models.Address) string {
+	if address.City == "" && address.PostalCode == "" {
+		return ""
+	}
+	var parts []string
+	for _, part := range []string{address.City, address.PostalCode, address.Region, address.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CheckPlace applies the column constraints of the places table.
+func CheckPlace(place models.Place) error {
+	switch {
+	case place.Name == "":
+		return fmt.Errorf("place name %w: is required", ErrValidation)
+	case utf8.RuneCountInString(place.Name) > maxPlaceNameLength:
+		return fmt.Errorf("place name %w: %q is longer than %d characters", ErrValidation, place.Name, maxPlaceNameLength)
+	case utf8.RuneCountInString(place.Region) > maxPlaceRegionLength:
+		return fmt.Errorf("place region %w: %q is longer than %d characters", ErrValidation, place.Region, maxPlaceRegionLength)
+	case utf8.RuneCountInString(place.PostalCode) > maxPlacePostalLength:
+		return fmt.Errorf("place postal code %w: %q is longer than %d characters", ErrValidation, place.PostalCode, maxPlacePostalLength)
+	case !ValidCountry(place.Country):
+		return fmt.Errorf("place country %w: %q is not a country code", ErrValidation, place.Country)
+	case !ValidCoordinates(place.Latitude, place.Longitude):
+		return fmt.Errorf("place coordinates %w: %v, %v is out of range", ErrValidation, place.Latitude, place.Longitude)
+	case place.Population < 0:
+		return fmt.Errorf("place population %w: %d is negative", ErrValidation, place.Population)
+	}
+	return nil
+}
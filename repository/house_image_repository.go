@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"thugcorp.io/nomado/models"
+)
+
+const houseImageColumns = `i.id, i.house_id, i.url, i.caption, i.alt_text, i.position, i.is_cover, i.created_at, i.renditions`
+
+func scanHouseImage(row interface{ Scan(...interface{}) error }) (models.HouseImage, error) {
+	var image models.HouseImage
+	err := row.Scan(&image.ID, &image.HouseID, &image.URL, &image.Caption, &image.AltText,
+		&image.Position, &image.IsCover, &image.CreatedAt, &image.Renditions)
+	image.CreatedAt = image.CreatedAt.UTC()
+	return image, err
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryHouseImages returns the images of the houses, ordered by house and
+// position.
+func queryHouseImages(ctx context.Context, q queryer, houseIDs ...int) ([]models.HouseImage, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+houseImageColumns+` FROM house_images i
+		WHERE i.house_id = ANY($1::int[])
+		ORDER BY i.house_id, i.position`, pq.Array(houseIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query house images: %w", err)
+	}
+	defer rows.Close()
+
+	images := []models.HouseImage{}
+	for rows.Next() {
+		image, err := scanHouseImage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan house image: %w", err)
+		}
+		images = append(images, image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate house images: %w", err)
+	}
+
+	return images, nil
+}
+
+// pointers returns pointers to the elements of houses.
+func pointers(houses []models.HouseWithDetails) []*models.HouseWithDetails {
+	ptrs := make([]*models.HouseWithDetails, len(houses))
+	for i := range houses {
+		ptrs[i] = &houses[i]
+	}
+	return ptrs
+}
+
+// loadImages fills in the galleries of the houses with a single query.
+func (hr *HouseRepository) loadImages(ctx context.Context, houses ...*models.HouseWithDetails) error {
+	if len(houses) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.HouseWithDetails, len(houses))
+	ids := make([]int, 0, len(houses))
+	for _, house := range houses {
+		house.Images = []models.HouseImage{}
+		byID[house.ID] = house
+		ids = append(ids, house.ID)
+	}
+
+	images, err := queryHouseImages(ctx, hr.db, ids...)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		house := byID[image.HouseID]
+		house.Images = append(house.Images, image)
+	}
+	return nil
+}
+
+// ListHouseImages returns the gallery of a house.
+func (hr *HouseRepository) ListHouseImages(ctx context.Context, houseID int) ([]models.HouseImage, error) {
+	if _, err := hr.GetHouseByID(ctx, houseID); err != nil {
+		return nil, err
+	}
+	return queryHouseImages(ctx, hr.db, houseID)
+}
+
+// changeGallery runs change in a transaction after locking the house and
+// incrementing its version, so that changes to one gallery never interleave.
+func (hr *HouseRepository) changeGallery(ctx context.Context, houseID int, change func(tx *sql.Tx) error) error {
+	tx, err := hr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `UPDATE houses SET updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id`, houseID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("house with id %d %w", houseID, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock house: %w", err)
+	}
+
+	if err := change(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit house images: %w", translatePQError(err))
+	}
+	return nil
+}
+
+func imageNotFound(houseID, imageID int) error {
+	return fmt.Errorf("image with id %d of house %d %w", imageID, houseID, ErrNotFound)
+}
+
+// AddHouseImage appends an image to the gallery of image.HouseID.
+func (hr *HouseRepository) AddHouseImage(ctx context.Context, image *models.HouseImage) error {
+	return hr.changeGallery(ctx, image.HouseID, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM house_images WHERE house_id = $1`, image.HouseID).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count house images: %w", err)
+		}
+		if image.IsCover && count > 0 {
+			if _, err := tx.ExecContext(ctx, `UPDATE house_images SET is_cover = FALSE WHERE house_id = $1 AND is_cover`, image.HouseID); err != nil {
+				return fmt.Errorf("failed to change house cover: %w", err)
+			}
+		}
+
+		image.Position = count
+		image.IsCover = image.IsCover || count == 0
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO house_images (house_id, url, caption, alt_text, position, is_cover, renditions)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, created_at`,
+			image.HouseID, image.URL, image.Caption, image.AltText, image.Position, image.IsCover, image.Renditions,
+		).Scan(&image.ID, &image.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to add house image: %w", translatePQError(err))
+		}
+		image.CreatedAt = image.CreatedAt.UTC()
+		return nil
+	})
+}
+
+// UpdateHouseImage changes the columns set in patch and returns the image. An
+// empty patch returns the image unchanged.
+func (hr *HouseRepository) UpdateHouseImage(ctx context.Context, houseID, imageID int, patch HouseImagePatch) (*models.HouseImage, error) {
+	if patch.IsEmpty() {
+		images, err := hr.ListHouseImages(ctx, houseID)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range images {
+			if image.ID == imageID {
+				return &image, nil
+			}
+		}
+		return nil, imageNotFound(houseID, imageID)
+	}
+
+	var qb queryBuilder
+	if patch.URL != nil {
+		url := qb.arg(*patch.URL)
+		// Renditions belong to the URL they were uploaded as
+		qb.assignments = append(qb.assignments,
+			"renditions = CASE WHEN url = "+url+" THEN renditions END", "url = "+url)
+	}
+	if patch.Caption != nil {
+		qb.set("caption", *patch.Caption)
+	}
+	if patch.AltText != nil {
+		qb.set("alt_text", *patch.AltText)
+	}
+	query := `UPDATE house_images i SET ` + qb.setClause() + `
+		WHERE i.id = ` + qb.arg(imageID) + ` AND i.house_id = ` + qb.arg(houseID) + `
+		RETURNING ` + houseImageColumns
+
+	var image models.HouseImage
+	err := hr.changeGallery(ctx, houseID, func(tx *sql.Tx) error {
+		var err error
+		image, err = scanHouseImage(tx.QueryRowContext(ctx, query, qb.args...))
+		if err == sql.ErrNoRows {
+			return imageNotFound(houseID, imageID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update house image: %w", translatePQError(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// DeleteHouseImage removes an image from the gallery.
+func (hr *HouseRepository) DeleteHouseImage(ctx context.Context, houseID, imageID int) error {
+	return hr.changeGallery(ctx, houseID, func(tx *sql.Tx) error {
+		var position int
+		var cover bool
+		err := tx.QueryRowContext(ctx, `DELETE FROM house_images WHERE id = $1 AND house_id = $2
+			RETURNING position, is_cover`, imageID, houseID).Scan(&position, &cover)
+		if err == sql.ErrNoRows {
+			return imageNotFound(houseID, imageID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete house image: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE house_images SET position = position - 1
+			WHERE house_id = $1 AND position > $2`, houseID, position); err != nil {
+			return fmt.Errorf("failed to reorder house images: %w", err)
+		}
+		if cover {
+			if _, err := tx.ExecContext(ctx, `UPDATE house_images SET is_cover = TRUE
+				WHERE house_id = $1 AND position = 0`, houseID); err != nil {
+				return fmt.Errorf("failed to change house cover: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ReorderHouseImages moves the images to the positions of their IDs in
+// imageIDs.
+func (hr *HouseRepository) ReorderHouseImages(ctx context.Context, houseID int, imageIDs []int) ([]models.HouseImage, error) {
+	var images []models.HouseImage
+	err := hr.changeGallery(ctx, houseID, func(tx *sql.Tx) error {
+		current, err := queryHouseImages(ctx, tx, houseID)
+		if err != nil {
+			return err
+		}
+		ids := make([]int, len(current))
+		for i, image := range current {
+			ids[i] = image.ID
+		}
+		if err := CheckImageOrder(ids, imageIDs); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE house_images i SET position = o.ordinality - 1
+			FROM unnest($2::int[]) WITH ORDINALITY AS o(id, ordinality)
+			WHERE i.house_id = $1 AND i.id = o.id`, houseID, pq.Array(imageIDs)); err != nil {
+			return fmt.Errorf("failed to reorder house images: %w", err)
+		}
+
+		images, err = queryHouseImages(ctx, tx, houseID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// SetHouseCover makes an image the cover of its gallery.
+func (hr *HouseRepository) SetHouseCover(ctx context.Context, houseID, imageID int) ([]models.HouseImage, error) {
+	var images []models.HouseImage
+	err := hr.changeGallery(ctx, houseID, func(tx *sql.Tx) error {
+		// The cover index is not deferrable, so the old cover goes first
+		if _, err := tx.ExecContext(ctx, `UPDATE house_images SET is_cover = FALSE
+			WHERE house_id = $1 AND is_cover AND id <> $2`, houseID, imageID); err != nil {
+			return fmt.Errorf("failed to change house cover: %w", err)
+		}
+		result, err := tx.ExecContext(ctx, `UPDATE house_images SET is_cover = TRUE
+			WHERE house_id = $1 AND id = $2`, houseID, imageID)
+		if err != nil {
+			return fmt.Errorf("failed to change house cover: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		} else if n == 0 {
+			return imageNotFound(houseID, imageID)
+		}
+
+		images, err = queryHouseImages(ctx, tx, houseID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
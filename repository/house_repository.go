@@ -1,13 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/money"
 )
 
 type HouseRepository struct {
@@ -18,167 +20,785 @@ func NewHouseRepository(db *sql.DB) *HouseRepository {
 	return &HouseRepository{db: db}
 }
 
-func (hr *HouseRepository) GetAllHouses() ([]models.House, error) {
-	query := `
-		SELECT h.id, h.name, h.description, h.house_type_id, h.price, 
-			   h.tags, h.image_url, h.created_at, h.updated_at, h.agent_id
-		FROM houses h
-		ORDER BY h.created_at DESC
-	`
+// HouseFilter describes the filtering, sorting and pagination options
+// accepted by ListHouses. Nil pointers and empty slices mean "no filter".
+type HouseFilter struct {
+	MinPrice    *money.Amount
+	MaxPrice    *money.Amount
+	Currency    string // prices are only comparable within one currency
+	HouseTypeID *int
+	AgentID     *int
+	Tags        []string // normalised tags; houses must carry every one
+	AnyTag      bool     // houses need to carry only one of Tags
+	SortField   string   // one of HouseSortFields, defaults to created_at
+	SortDesc    bool
+	Limit       int
+	Offset      int
+
+	Bedrooms      Range[int]
+	Bathrooms     Range[float64]
+	FloorArea     Range[float64] // in square metres, whatever the house's unit
+	LotSize       Range[float64] // in square metres
+	YearBuilt     Range[int]
+	ParkingSpaces Range[int]
+	Floors        Range[int]
+
+	// Near limits the houses to those within RadiusKm of a point, or to those
+	// with coordinates if RadiusKm is 0, and makes the distance available as
+	// the "distance" sort key. BBox limits them to a bounding box.
+	Near     *Point
+	RadiusKm float64
+	BBox     *BoundingBox
+}
+
+// HousePatch lists the columns changed by PatchHouse. Nil fields are left
+// unchanged; a non-nil ImageURL pointing to nil clears the image. The parts of
+// the address are patched one by one.
+type HousePatch struct {
+	Name        *string
+	Description *string
+	HouseTypeID *int
+	Price       *money.Amount
+	Currency    *string
+	Tags        *[]string
+	ImageURL    **string
+	AgentID     *int
+
+	Bedrooms      **int
+	Bathrooms     **float64
+	FloorArea     **float64
+	LotSize       **float64
+	AreaUnit      *string
+	YearBuilt     **int
+	ParkingSpaces **int
+	Floors        **int
+
+	Street     *string
+	City       *string
+	Region     *string
+	PostalCode *string
+	Country    *string
+	Latitude   **float64
+	Longitude  **float64
+}
+
+// IsEmpty reports whether the patch changes no column.
+func (p HousePatch) IsEmpty() bool {
+	return p == HousePatch{}
+}
+
+// Apply sets the patched fields of house.
+func (p HousePatch) Apply(house *models.House) {
+	if p.Name != nil {
+		house.Name = *p.Name
+	}
+	if p.Description != nil {
+		house.Description = *p.Description
+	}
+	if p.HouseTypeID != nil {
+		house.HouseTypeID = *p.HouseTypeID
+	}
+	if p.Price != nil {
+		house.Price = *p.Price
+	}
+	if p.Currency != nil {
+		house.Currency = *p.Currency
+	}
+	if p.Tags != nil {
+		house.Tags = *p.Tags
+	}
+	if p.ImageURL != nil {
+		house.ImageURL = *p.ImageURL
+	}
+	if p.AgentID != nil {
+		house.AgentID = *p.AgentID
+	}
+	if p.Bedrooms != nil {
+		house.Bedrooms = *p.Bedrooms
+	}
+	if p.Bathrooms != nil {
+		house.Bathrooms = *p.Bathrooms
+	}
+	if p.FloorArea != nil {
+		house.FloorArea = *p.FloorArea
+	}
+	if p.LotSize != nil {
+		house.LotSize = *p.LotSize
+	}
+	if p.AreaUnit != nil {
+		house.AreaUnit = *p.AreaUnit
+	}
+	if p.YearBuilt != nil {
+		house.YearBuilt = *p.YearBuilt
+	}
+	if p.ParkingSpaces != nil {
+		house.ParkingSpaces = *p.ParkingSpaces
+	}
+	if p.Floors != nil {
+		house.Floors = *p.Floors
+	}
+	if p.Street != nil {
+		house.Address.Street = *p.Street
+	}
+	if p.City != nil {
+		house.Address.City = *p.City
+	}
+	if p.Region != nil {
+		house.Address.Region = *p.Region
+	}
+	if p.PostalCode != nil {
+		house.Address.PostalCode = *p.PostalCode
+	}
+	if p.Country != nil {
+		house.Address.Country = *p.Country
+	}
+	if p.Latitude != nil {
+		house.Latitude = *p.Latitude
+	}
+	if p.Longitude != nil {
+		house.Longitude = *p.Longitude
+	}
+}
+
+// HouseSortFields maps the public sort keys to their SQL columns. Names sort
+// by their bytes with ASCII letters lowered, whatever the database locale, and
+// sorting by distance requires HouseFilter.Near.
+var HouseSortFields = map[string]string{
+	"price":      "h.price",
+	"created_at": "h.created_at",
+	"name":       `LOWER(h.name COLLATE "C")`,
+	"distance":   "distance_km",
+}
+
+// HouseSearchResult is a house matched by a full-text search together with
+// its relevance rank and a highlighted description snippet.
+type HouseSearchResult struct {
+	House   models.HouseWithDetails
+	Rank    float64
+	Snippet string
+}
+
+// houseColumns selects a house row. Nullable columns are coalesced so that a
+// house whose agent or house type was deleted (ON DELETE SET NULL) still scans.
+const houseColumns = `h.id, h.name, COALESCE(h.description, ''), COALESCE(h.house_type_id, 0), h.price,
+			   h.currency, h.tags, h.image_url, h.created_at, h.updated_at, COALESCE(h.agent_id, 0),
+			   h.version, h.deleted_at, h.bedrooms, h.bathrooms, h.floor_area, h.lot_size,
+			   h.area_unit, h.year_built, h.parking_spaces, h.floors,
+			   h.street, h.city, h.region, h.postal_code, h.country, h.latitude, h.longitude`
+
+// scanHouse scans the columns listed in houseColumns, followed by any extra
+// destinations selected after them.
+func scanHouse(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.House, error) {
+	var house models.House
+
+	dest := []interface{}{
+		&house.ID, &house.Name, &house.Description, &house.HouseTypeID,
+		&house.Price, &house.Currency, pq.Array(&house.Tags), &house.ImageURL, &house.CreatedAt,
+		&house.UpdatedAt, &house.AgentID, &house.Version, &house.DeletedAt,
+		&house.Bedrooms, &house.Bathrooms, &house.FloorArea, &house.LotSize,
+		&house.AreaUnit, &house.YearBuilt, &house.ParkingSpaces, &house.Floors,
+		&house.Address.Street, &house.Address.City, &house.Address.Region, &house.Address.PostalCode,
+		&house.Address.Country, &house.Latitude, &house.Longitude,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return house, err
+	}
+
+	// Houses without tags have nil Tags, as in the memory store
+	if len(house.Tags) == 0 {
+		house.Tags = nil
+	}
+	house.CreatedAt = house.CreatedAt.UTC()
+	house.UpdatedAt = house.UpdatedAt.UTC()
+	if house.DeletedAt != nil {
+		deletedAt := house.DeletedAt.UTC()
+		house.DeletedAt = &deletedAt
+	}
+
+	return house, nil
+}
+
+// houseDetailsColumns extends houseColumns with the agent and house type
+// columns made available by houseDetailsFrom.
+const houseDetailsColumns = houseColumns + `,
+			   a.id, a.first_name, a.last_name, a.image_url, a.image_renditions, ht.id, ht.name`
+
+// houseDetailsFrom joins each house to its agent and house type so that list
+// endpoints load everything in a single query.
+const houseDetailsFrom = ` FROM houses h
+		LEFT JOIN agents a ON a.id = h.agent_id
+		LEFT JOIN house_types ht ON ht.id = h.house_type_id`
+
+// scanHouseWithDetails scans the columns listed in houseDetailsColumns,
+// followed by any extra destinations selected after them.
+func scanHouseWithDetails(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.HouseWithDetails, error) {
+	var details models.HouseWithDetails
+	var agentID, houseTypeID sql.NullInt64
+	var agentFirstName, agentLastName, houseTypeName sql.NullString
+	var agentImageURL *string
+	var agentImageRenditions *models.Renditions
+
+	dest := append([]interface{}{
+		&agentID, &agentFirstName, &agentLastName, &agentImageURL, &agentImageRenditions,
+		&houseTypeID, &houseTypeName,
+	}, extra...)
+	house, err := scanHouse(row, dest...)
+	if err != nil {
+		return details, err
+	}
+
+	details.House = house
+	if agentID.Valid {
+		details.Agent = &models.Agent{
+			ID:              int(agentID.Int64),
+			FirstName:       agentFirstName.String,
+			LastName:        agentLastName.String,
+			ImageURL:        agentImageURL,
+			ImageRenditions: agentImageRenditions,
+		}
+	}
+	if houseTypeID.Valid {
+		details.HouseType = &models.HouseType{
+			ID:   int(houseTypeID.Int64),
+			Name: houseTypeName.String,
+		}
+	}
+
+	return details, nil
+}
+
+// applyHouseFilter adds the WHERE conditions described by filter. Houses in
+// the trash never match.
+func applyHouseFilter(qb *queryBuilder, filter HouseFilter) {
+	qb.where("h.deleted_at IS NULL")
+	if filter.Currency != "" {
+		qb.where("h.currency = ?", filter.Currency)
+	}
+	if filter.MinPrice != nil {
+		qb.where("h.price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		qb.where("h.price <= ?", *filter.MaxPrice)
+	}
+	if filter.HouseTypeID != nil {
+		qb.where("h.house_type_id = ?", *filter.HouseTypeID)
+	}
+	if filter.AgentID != nil {
+		qb.where("h.agent_id = ?", *filter.AgentID)
+	}
+	// Both operators are served by the GIN index on tags
+	if len(filter.Tags) > 0 {
+		if filter.AnyTag {
+			qb.where("h.tags && ?::text[]", pq.Array(filter.Tags))
+		} else {
+			qb.where("h.tags @> ?::text[]", pq.Array(filter.Tags))
+		}
+	}
+	whereRange(qb, "h.bedrooms", filter.Bedrooms)
+	whereRange(qb, "h.bathrooms", filter.Bathrooms)
+	whereRange(qb, "h.floor_area_sqm", filter.FloorArea)
+	whereRange(qb, "h.lot_size_sqm", filter.LotSize)
+	whereRange(qb, "h.year_built", filter.YearBuilt)
+	whereRange(qb, "h.parking_spaces", filter.ParkingSpaces)
+	whereRange(qb, "h.floors", filter.Floors)
+
+	if filter.BBox != nil {
+		whereBox(qb, *filter.BBox)
+	}
+	if filter.Near != nil {
+		qb.where("h.latitude IS NOT NULL")
+		if filter.RadiusKm > 0 {
+			// The bounding box of the circle narrows the rows through the index
+			whereBox(qb, CircleBounds(*filter.Near, filter.RadiusKm))
+			qb.where(distanceSQL(qb, "h", *filter.Near)+" <= ?", filter.RadiusKm)
+		}
+	}
+}
+
+// whereBox adds the condition that a house lies in b, in the form served by
+// the GiST index on point(longitude, latitude).
+func whereBox(qb *queryBuilder, b BoundingBox) {
+	var boxes []string
+	for _, part := range b.split() {
+		boxes = append(boxes, fmt.Sprintf("point(h.longitude, h.latitude) <@ box(point(%s, %s), point(%s, %s))",
+			qb.arg(part.West), qb.arg(part.South), qb.arg(part.East), qb.arg(part.North)))
+	}
+	qb.where("(" + strings.Join(boxes, " OR ") + ")")
+}
+
+// distanceSQL returns an expression for the great-circle distance in
+// kilometres from p to the latitude and longitude columns of the table
+// aliased as alias, computed like DistanceKm.
+func distanceSQL(qb *queryBuilder, alias string, p Point) string {
+	lat, lng := qb.arg(p.Lat), qb.arg(p.Lng)
+	return fmt.Sprintf(`(%[1]v * 2 * asin(LEAST(1, sqrt(
+			power(sin(radians(%[2]s.latitude - %[3]s) / 2), 2) +
+			cos(radians(%[3]s)) * cos(radians(%[2]s.latitude)) * power(sin(radians(%[2]s.longitude - %[4]s) / 2), 2)))))`,
+		EarthRadiusKm, alias, lat, lng)
+}
+
+// distanceColumn returns the distance_km column selected by the list
+// queries, NULL unless filter.Near is set.
+func distanceColumn(qb *queryBuilder, filter HouseFilter) string {
+	if filter.Near == nil {
+		return `, NULL::DOUBLE PRECISION AS distance_km`
+	}
+	return `, ` + distanceSQL(qb, "h", *filter.Near) + ` AS distance_km`
+}
+
+// whereRange adds the conditions of an attribute range. NULL values never
+// match a bound.
+func whereRange[T int | float64](qb *queryBuilder, column string, r Range[T]) {
+	if r.Min != nil {
+		qb.where(column+" >= ?", *r.Min)
+	}
+	if r.Max != nil {
+		qb.where(column+" <= ?", *r.Max)
+	}
+}
+
+// orderAndPage renders the ORDER BY, LIMIT and OFFSET clauses for filter.
+// fallback is used when filter.SortField is not a known sort key.
+func orderAndPage(qb *queryBuilder, filter HouseFilter, fallback string) string {
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
+	}
+
+	clause := " ORDER BY " + fallback
+	if sortColumn, ok := HouseSortFields[filter.SortField]; ok {
+		clause = fmt.Sprintf(" ORDER BY %s %s, h.id %s", sortColumn, direction, direction)
+	}
+	if filter.Limit > 0 {
+		clause += " LIMIT " + qb.arg(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		clause += " OFFSET " + qb.arg(filter.Offset)
+	}
+	return clause
+}
+
+// ListHouses returns a page of houses, with their agent and house type,
+// matching the filter together with the total number of matching rows.
+func (hr *HouseRepository) ListHouses(ctx context.Context, filter HouseFilter) ([]models.HouseWithDetails, int, error) {
+	var qb queryBuilder
+	applyHouseFilter(&qb, filter)
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM houses h` + qb.whereClause()
+	if err := hr.db.QueryRowContext(ctx, countQuery, qb.args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count houses: %w", err)
+	}
+
+	query := `SELECT ` + houseDetailsColumns + distanceColumn(&qb, filter) + houseDetailsFrom + qb.whereClause() +
+		orderAndPage(&qb, filter, "h.created_at DESC, h.id DESC")
 
-	rows, err := hr.db.Query(query)
+	rows, err := hr.db.QueryContext(ctx, query, qb.args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query houses: %w", err)
+		return nil, 0, fmt.Errorf("failed to query houses: %w", err)
 	}
 	defer rows.Close()
 
-	var houses []models.House
+	var houses []models.HouseWithDetails
 	for rows.Next() {
-		var house models.House
-		var tagsStr string
-
-		err := rows.Scan(
-			&house.ID, &house.Name, &house.Description, &house.HouseTypeID,
-			&house.Price, &tagsStr, &house.ImageURL, &house.CreatedAt,
-			&house.UpdatedAt, &house.AgentID,
-		)
+		var distance *float64
+		house, err := scanHouseWithDetails(rows, &distance)
 		if err != nil {
-			log.Printf("Error scanning house: %v", err)
-			continue
+			return nil, 0, fmt.Errorf("failed to scan house: %w", err)
 		}
+		house.DistanceKm = distance
+		houses = append(houses, house)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate houses: %w", err)
+	}
+	if err := hr.loadImages(ctx, pointers(houses)...); err != nil {
+		return nil, 0, err
+	}
 
-		// Parse tags from comma-separated string
-		if tagsStr != "" {
-			house.Tags = strings.Split(tagsStr, ",")
-		}
+	return houses, total, nil
+}
 
-		houses = append(houses, house)
+// escapedDescription is the house description with the HTML special
+// characters escaped like html.EscapeString does, so that the <mark></mark>
+// tags added by ts_headline are the only markup in a snippet.
+const escapedDescription = `replace(replace(replace(replace(replace(COALESCE(h.description, ''),
+	'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+
+// SearchHouses runs a full-text search over house names, tags and
+// descriptions. The search text uses web search syntax ("quoted phrases",
+// OR, -excluded). Results are ordered by relevance unless filter.SortField
+// names a sort key, and the snippet marks matches with <mark></mark>.
+func (hr *HouseRepository) SearchHouses(ctx context.Context, search string, filter HouseFilter) ([]HouseSearchResult, int, error) {
+	var qb queryBuilder
+	tsquery := ` CROSS JOIN websearch_to_tsquery('english', ` + qb.arg(search) + `) AS query`
+	qb.where("h.search_vector @@ query")
+	applyHouseFilter(&qb, filter)
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM houses h` + tsquery + qb.whereClause()
+	if err := hr.db.QueryRowContext(ctx, countQuery, qb.args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
 	}
 
-	return houses, nil
+	query := `SELECT ` + houseDetailsColumns + `,
+			   ts_rank(h.search_vector, query) AS rank,
+			   ts_headline('english', ` + escapedDescription + `, query,
+				   'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15')` +
+		distanceColumn(&qb, filter) + houseDetailsFrom + tsquery + qb.whereClause() +
+		orderAndPage(&qb, filter, "rank DESC, h.id")
+
+	rows, err := hr.db.QueryContext(ctx, query, qb.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search houses: %w", err)
+	}
+	defer rows.Close()
+
+	var results []HouseSearchResult
+	for rows.Next() {
+		var result HouseSearchResult
+		var distance *float64
+		result.House, err = scanHouseWithDetails(rows, &result.Rank, &result.Snippet, &distance)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		result.House.DistanceKm = distance
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate search results: %w", err)
+	}
+	houses := make([]*models.HouseWithDetails, len(results))
+	for i := range results {
+		houses[i] = &results[i].House
+	}
+	if err := hr.loadImages(ctx, houses...); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
 }
 
-func (hr *HouseRepository) GetTopHouses(limit int) ([]models.House, error) {
-	query := `
-		SELECT h.id, h.name, h.description, h.house_type_id, h.price, 
-			   h.tags, h.image_url, h.created_at, h.updated_at, h.agent_id
-		FROM houses h
-		ORDER BY h.price DESC
-		LIMIT $1
+// GetTopHousesWithDetails returns the most expensive houses priced in
+// currency with their agent and house type loaded in the same query.
+func (hr *HouseRepository) GetTopHousesWithDetails(ctx context.Context, currency string, limit int) ([]models.HouseWithDetails, error) {
+	query := `SELECT ` + houseDetailsColumns + houseDetailsFrom + `
+		WHERE h.deleted_at IS NULL AND h.currency = $1
+		ORDER BY h.price DESC, h.id DESC
+		LIMIT $2
 	`
 
-	rows, err := hr.db.Query(query, limit)
+	rows, err := hr.db.QueryContext(ctx, query, currency, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query top houses: %w", err)
 	}
 	defer rows.Close()
 
-	var houses []models.House
+	var houses []models.HouseWithDetails
 	for rows.Next() {
-		var house models.House
-		var tagsStr string
-
-		err := rows.Scan(
-			&house.ID, &house.Name, &house.Description, &house.HouseTypeID,
-			&house.Price, &tagsStr, &house.ImageURL, &house.CreatedAt,
-			&house.UpdatedAt, &house.AgentID,
-		)
+		house, err := scanHouseWithDetails(rows)
 		if err != nil {
-			log.Printf("Error scanning house: %v", err)
-			continue
+			return nil, fmt.Errorf("failed to scan house: %w", err)
 		}
-
-		// Parse tags from comma-separated string
-		if tagsStr != "" {
-			house.Tags = strings.Split(tagsStr, ",")
-		}
-
 		houses = append(houses, house)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate houses: %w", err)
+	}
+	if err := hr.loadImages(ctx, pointers(houses)...); err != nil {
+		return nil, err
+	}
 
 	return houses, nil
 }
 
-func (hr *HouseRepository) GetHouseByID(id int) (*models.House, error) {
-	query := `
-		SELECT h.id, h.name, h.description, h.house_type_id, h.price, 
-			   h.tags, h.image_url, h.created_at, h.updated_at, h.agent_id
+func (hr *HouseRepository) GetHouseByID(ctx context.Context, id int) (*models.House, error) {
+	query := `SELECT ` + houseColumns + `
 		FROM houses h
-		WHERE h.id = $1
+		WHERE h.id = $1 AND h.deleted_at IS NULL
 	`
 
-	var house models.House
-	var tagsStr string
+	house, err := scanHouse(hr.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("house with id %d %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to query house: %w", err)
+	}
 
-	err := hr.db.QueryRow(query, id).Scan(
-		&house.ID, &house.Name, &house.Description, &house.HouseTypeID,
-		&house.Price, &tagsStr, &house.ImageURL, &house.CreatedAt,
-		&house.UpdatedAt, &house.AgentID,
-	)
+	return &house, nil
+}
+
+// GetHouseWithDetailsByID returns a house with its agent, house type and
+// images.
+func (hr *HouseRepository) GetHouseWithDetailsByID(ctx context.Context, id int) (*models.HouseWithDetails, error) {
+	query := `SELECT ` + houseDetailsColumns + houseDetailsFrom + `
+		WHERE h.id = $1 AND h.deleted_at IS NULL
+	`
 
+	house, err := scanHouseWithDetails(hr.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("house with id %d not found", id)
+			return nil, fmt.Errorf("house with id %d %w", id, ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to query house: %w", err)
 	}
-
-	// Parse tags from comma-separated string
-	if tagsStr != "" {
-		house.Tags = strings.Split(tagsStr, ",")
+	if err := hr.loadImages(ctx, &house); err != nil {
+		return nil, err
 	}
 
 	return &house, nil
 }
 
-func (hr *HouseRepository) CreateHouse(house *models.House) error {
+func (hr *HouseRepository) CreateHouse(ctx context.Context, house *models.House) error {
 	query := `
-		INSERT INTO houses (name, description, house_type_id, price, tags, image_url, agent_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at, updated_at
+		INSERT INTO houses (name, description, house_type_id, price, currency, tags, image_url, agent_id,
+			bedrooms, bathrooms, floor_area, lot_size, area_unit, year_built, parking_spaces, floors,
+			street, city, region, postal_code, country, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+			$17, $18, $19, $20, $21, $22, $23)
+		RETURNING id, created_at, updated_at, version
 	`
 
-	tagsStr := strings.Join(house.Tags, ",")
+	house.Tags = NormalizeTags(house.Tags)
+	setHouseDefaults(house)
 
-	err := hr.db.QueryRow(
-		query, house.Name, house.Description, house.HouseTypeID,
-		house.Price, tagsStr, house.ImageURL, house.AgentID,
-	).Scan(&house.ID, &house.CreatedAt, &house.UpdatedAt)
+	err := hr.db.QueryRowContext(
+		ctx, query, house.Name, house.Description, house.HouseTypeID,
+		house.Price, house.Currency, tagArray(house.Tags), house.ImageURL, house.AgentID,
+		house.Bedrooms, house.Bathrooms, house.FloorArea, house.LotSize, house.AreaUnit,
+		house.YearBuilt, house.ParkingSpaces, house.Floors,
+		house.Address.Street, house.Address.City, house.Address.Region, house.Address.PostalCode,
+		house.Address.Country, house.Latitude, house.Longitude,
+	).Scan(&house.ID, &house.CreatedAt, &house.UpdatedAt, &house.Version)
 
 	if err != nil {
-		return fmt.Errorf("failed to create house: %w", err)
+		return fmt.Errorf("failed to create house: %w", translatePQError(err))
 	}
+	house.CreatedAt = house.CreatedAt.UTC()
+	house.UpdatedAt = house.UpdatedAt.UTC()
 
 	return nil
 }
 
-func (hr *HouseRepository) UpdateHouse(house *models.House) error {
+// setHouseDefaults fills in the column defaults of an unset currency and
+// area unit.
+func setHouseDefaults(house *models.House) {
+	if house.Currency == "" {
+		house.Currency = money.DefaultCurrency
+	}
+	if house.AreaUnit == "" {
+		house.AreaUnit = models.AreaUnitSquareMetres
+	}
+}
+
+// tagArray converts tags to a TEXT[] parameter. No tags become an empty
+// array rather than NULL.
+func tagArray(tags []string) interface{} {
+	if tags == nil {
+		tags = []string{}
+	}
+	return pq.Array(tags)
+}
+
+// ListTags returns every tag in use with the number of houses carrying it,
+// most used first.
+func (hr *HouseRepository) ListTags(ctx context.Context) ([]models.TagCount, error) {
+	query := `
+		SELECT tag, COUNT(*)
+		FROM houses h, unnest(h.tags) AS tag
+		WHERE h.deleted_at IS NULL
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag COLLATE "C"
+	`
+
+	rows, err := hr.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []models.TagCount
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.HouseCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// missingOrModified explains why a conditional write to house id matched no
+// row: the house does not exist or is in the trash, or its version has
+// changed.
+func (hr *HouseRepository) missingOrModified(ctx context.Context, id int) error {
+	var exists bool
+	err := hr.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM houses WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to query house: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("house with id %d %w", id, ErrVersionMismatch)
+	}
+	return fmt.Errorf("house with id %d %w", id, ErrNotFound)
+}
+
+// UpdateHouse replaces every column of a house. If house.Version is set, the
+// update only succeeds while the stored version matches it. On success
+// house.Version holds the new version.
+func (hr *HouseRepository) UpdateHouse(ctx context.Context, house *models.House) error {
 	query := `
 		UPDATE houses 
-		SET name = $1, description = $2, house_type_id = $3, price = $4, 
-			tags = $5, image_url = $6, agent_id = $7, updated_at = NOW()
-		WHERE id = $8
-		RETURNING updated_at
+		SET name = $1, description = $2, house_type_id = $3, price = $4, currency = $5,
+			tags = $6, image_url = $7, agent_id = $8, bedrooms = $9, bathrooms = $10,
+			floor_area = $11, lot_size = $12, area_unit = $13, year_built = $14,
+			parking_spaces = $15, floors = $16, street = $17, city = $18, region = $19,
+			postal_code = $20, country = $21, latitude = $22, longitude = $23,
+			updated_at = NOW(), version = version + 1
+		WHERE id = $24 AND deleted_at IS NULL AND ($25 = 0 OR version = $25)
+		RETURNING created_at, updated_at, version
 	`
 
-	tagsStr := strings.Join(house.Tags, ",")
+	house.Tags = NormalizeTags(house.Tags)
+	setHouseDefaults(house)
 
-	err := hr.db.QueryRow(
-		query, house.Name, house.Description, house.HouseTypeID,
-		house.Price, tagsStr, house.ImageURL, house.AgentID, house.ID,
-	).Scan(&house.UpdatedAt)
+	err := hr.db.QueryRowContext(
+		ctx, query, house.Name, house.Description, house.HouseTypeID, house.Price, house.Currency,
+		tagArray(house.Tags), house.ImageURL, house.AgentID, house.Bedrooms, house.Bathrooms,
+		house.FloorArea, house.LotSize, house.AreaUnit, house.YearBuilt, house.ParkingSpaces,
+		house.Floors, house.Address.Street, house.Address.City, house.Address.Region,
+		house.Address.PostalCode, house.Address.Country, house.Latitude, house.Longitude,
+		house.ID, house.Version,
+	).Scan(&house.CreatedAt, &house.UpdatedAt, &house.Version)
 
 	if err != nil {
-		return fmt.Errorf("failed to update house: %w", err)
+		if err == sql.ErrNoRows {
+			return hr.missingOrModified(ctx, house.ID)
+		}
+		return fmt.Errorf("failed to update house: %w", translatePQError(err))
 	}
+	house.CreatedAt = house.CreatedAt.UTC()
+	house.UpdatedAt = house.UpdatedAt.UTC()
 
 	return nil
 }
 
-func (hr *HouseRepository) DeleteHouse(id int) error {
-	query := `DELETE FROM houses WHERE id = $1`
+// PatchHouse updates only the columns set in patch and returns the updated
+// house. A non-zero version must match the stored one. An empty patch returns
+// the house unchanged.
+func (hr *HouseRepository) PatchHouse(ctx context.Context, id, version int, patch HousePatch) (*models.House, error) {
+	if patch.IsEmpty() {
+		house, err := hr.GetHouseByID(ctx, id)
+		if err == nil && version != 0 && house.Version != version {
+			return nil, fmt.Errorf("house with id %d %w", id, ErrVersionMismatch)
+		}
+		return house, err
+	}
 
-	result, err := hr.db.Exec(query, id)
+	var qb queryBuilder
+	if patch.Name != nil {
+		qb.set("name", *patch.Name)
+	}
+	if patch.Description != nil {
+		qb.set("description", *patch.Description)
+	}
+	if patch.HouseTypeID != nil {
+		qb.set("house_type_id", *patch.HouseTypeID)
+	}
+	if patch.Price != nil {
+		qb.set("price", *patch.Price)
+	}
+	if patch.Currency != nil {
+		qb.set("currency", *patch.Currency)
+	}
+	if patch.Tags != nil {
+		qb.set("tags", tagArray(NormalizeTags(*patch.Tags)))
+	}
+	if patch.ImageURL != nil {
+		qb.set("image_url", *patch.ImageURL)
+	}
+	if patch.AgentID != nil {
+		qb.set("agent_id", *patch.AgentID)
+	}
+	if patch.Bedrooms != nil {
+		qb.set("bedrooms", *patch.Bedrooms)
+	}
+	if patch.Bathrooms != nil {
+		qb.set("bathrooms", *patch.Bathrooms)
+	}
+	if patch.FloorArea != nil {
+		qb.set("floor_area", *patch.FloorArea)
+	}
+	if patch.LotSize != nil {
+		qb.set("lot_size", *patch.LotSize)
+	}
+	if patch.AreaUnit != nil {
+		qb.set("area_unit", *patch.AreaUnit)
+	}
+	if patch.YearBuilt != nil {
+		qb.set("year_built", *patch.YearBuilt)
+	}
+	if patch.ParkingSpaces != nil {
+		qb.set("parking_spaces", *patch.ParkingSpaces)
+	}
+	if patch.Floors != nil {
+		qb.set("floors", *patch.Floors)
+	}
+	if patch.Street != nil {
+		qb.set("street", *patch.Street)
+	}
+	if patch.City != nil {
+		qb.set("city", *patch.City)
+	}
+	if patch.Region != nil {
+		qb.set("region", *patch.Region)
+	}
+	if patch.PostalCode != nil {
+		qb.set("postal_code", *patch.PostalCode)
+	}
+	if patch.Country != nil {
+		qb.set("country", *patch.Country)
+	}
+	if patch.Latitude != nil {
+		qb.set("latitude", *patch.Latitude)
+	}
+	if patch.Longitude != nil {
+		qb.set("longitude", *patch.Longitude)
+	}
+
+	query := `UPDATE houses h SET ` + qb.setClause() + `, updated_at = NOW(), version = h.version + 1
+		WHERE h.deleted_at IS NULL AND h.id = ` + qb.arg(id)
+	if version != 0 {
+		query += ` AND h.version = ` + qb.arg(version)
+	}
+	query += ` RETURNING ` + houseColumns
+
+	house, err := scanHouse(hr.db.QueryRowContext(ctx, query, qb.args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, hr.missingOrModified(ctx, id)
+		}
+		return nil, fmt.Errorf("failed to patch house: %w", translatePQError(err))
+	}
+
+	return &house, nil
+}
+
+// DeleteHouse moves a house to the trash. A non-zero version must match the
+// stored one.
+func (hr *HouseRepository) DeleteHouse(ctx context.Context, id, version int) error {
+	query := `
+		UPDATE houses
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+	`
+
+	result, err := hr.db.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return fmt.Errorf("failed to delete house: %w", err)
 	}
@@ -189,8 +809,109 @@ func (hr *HouseRepository) DeleteHouse(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("house with id %d not found", id)
+		return hr.missingOrModified(ctx, id)
 	}
 
 	return nil
 }
+
+// ListDeletedHouses returns a page of the houses in the trash, most recently
+// deleted first, together with the number of houses in the trash.
+func (hr *HouseRepository) ListDeletedHouses(ctx context.Context, limit, offset int) ([]models.HouseWithDetails, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM houses WHERE deleted_at IS NOT NULL`
+	if err := hr.db.QueryRowContext(ctx, countQuery).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count deleted houses: %w", err)
+	}
+
+	var qb queryBuilder
+	query := `SELECT ` + houseDetailsColumns + houseDetailsFrom + `
+		WHERE h.deleted_at IS NOT NULL
+		ORDER BY h.deleted_at DESC, h.id DESC`
+	if limit > 0 {
+		query += " LIMIT " + qb.arg(limit)
+	}
+	if offset > 0 {
+		query += " OFFSET " + qb.arg(offset)
+	}
+
+	rows, err := hr.db.QueryContext(ctx, query, qb.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query deleted houses: %w", err)
+	}
+	defer rows.Close()
+
+	var houses []models.HouseWithDetails
+	for rows.Next() {
+		house, err := scanHouseWithDetails(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan house: %w", err)
+		}
+		houses = append(houses, house)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate deleted houses: %w", err)
+	}
+	if err := hr.loadImages(ctx, pointers(houses)...); err != nil {
+		return nil, 0, err
+	}
+
+	return houses, total, nil
+}
+
+// RestoreHouse takes a house out of the trash and returns it. A house that
+// is not in the trash is not found.
+func (hr *HouseRepository) RestoreHouse(ctx context.Context, id int) (*models.House, error) {
+	query := `
+		UPDATE houses h
+		SET deleted_at = NULL, updated_at = NOW(), version = h.version + 1
+		WHERE h.id = $1 AND h.deleted_at IS NOT NULL
+		RETURNING ` + houseColumns
+
+	house, err := scanHouse(hr.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("deleted house with id %d %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to restore house: %w", err)
+	}
+
+	return &house, nil
+}
+
+// PurgeDeletedHouses permanently removes the houses moved to the trash
+// before cutoff and returns how many were removed, with the renditions of
+// their uploaded photos.
+func (hr *HouseRepository) PurgeDeletedHouses(ctx context.Context, cutoff time.Time) (int, []models.Renditions, error) {
+	// The join still sees the images that the deletion cascades to
+	rows, err := hr.db.QueryContext(ctx, `
+		WITH purged AS (
+			DELETE FROM houses WHERE deleted_at < $1 RETURNING id
+		)
+		SELECT p.id, i.renditions
+		FROM purged p
+		LEFT JOIN house_images i ON i.house_id = p.id AND i.renditions IS NOT NULL`, cutoff)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to purge deleted houses: %w", err)
+	}
+	defer rows.Close()
+
+	purged := make(map[int]bool)
+	var uploads []models.Renditions
+	for rows.Next() {
+		var id int
+		var renditions *models.Renditions
+		if err := rows.Scan(&id, &renditions); err != nil {
+			return 0, nil, fmt.Errorf("failed to scan purged house: %w", err)
+		}
+		purged[id] = true
+		if renditions != nil {
+			uploads = append(uploads, *renditions)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to purge deleted houses: %w", err)
+	}
+
+	return len(purged), uploads, nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lib/pq"
+	"thugcorp.io/nomado/db"
+)
+
+// countingDriver wraps lib/pq and counts every statement sent to the server.
+type countingDriver struct {
+	queries atomic.Int64
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := pq.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, driver: d}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+	driver *countingDriver
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.queries.Add(1)
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.queries.Add(1)
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+var benchDriver = &countingDriver{}
+
+func init() {
+	sql.Register("postgres-counting", benchDriver)
+}
+
+// openBenchDB connects to the database named by NOMADO_TEST_DATABASE_URL and
+// creates an isolated schema seeded with houseCount houses.
+func openBenchDB(b *testing.B, houseCount int) *sql.DB {
+	b.Helper()
+
+	dsn := os.Getenv("NOMADO_TEST_DATABASE_URL")
+	if dsn == "" {
+		b.Skip("NOMADO_TEST_DATABASE_URL not set")
+	}
+
+	conn, err := sql.Open("postgres-counting", dsn)
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	// A single connection keeps the search_path below in effect for every query
+	conn.SetMaxOpenConns(1)
+	b.Cleanup(func() { conn.Close() })
+
+	schema := fmt.Sprintf("nomado_bench_%d", os.Getpid())
+	setup := []string{
+		`CREATE SCHEMA ` + schema,
+		`SET search_path TO ` + schema,
+	}
+	for _, stmt := range setup {
+		if _, err := conn.Exec(stmt); err != nil {
+			b.Fatalf("failed to prepare schema: %v", err)
+		}
+	}
+	b.Cleanup(func() { conn.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	database := &db.Database{DB: conn}
+	if _, err := database.MigrateUp(context.Background()); err != nil {
+		b.Fatalf("failed to migrate: %v", err)
+	}
+	if err := database.SeedData(); err != nil {
+		b.Fatalf("failed to seed data: %v", err)
+	}
+
+	_, err = conn.Exec(`
+		INSERT INTO houses (name, description, house_type_id, price, tags, agent_id)
+		SELECT 'House ' || n, 'Benchmark listing', (n % 5) + 1, 100000 + n, ARRAY['bench'], (n % 5) + 1
+		FROM generate_series(1, $1) AS n
+	`, houseCount)
+	if err != nil {
+		b.Fatalf("failed to insert houses: %v", err)
+	}
+
+	return conn
+}
+
+// BenchmarkListHouses verifies that listing a full page of houses with their
+// agent, house type and gallery costs a constant number of queries,
+// independent of the page size.
+func BenchmarkListHouses(b *testing.B) {
+	for _, pageSize := range []int{10, 100, 500} {
+		b.Run(fmt.Sprintf("limit=%d", pageSize), func(b *testing.B) {
+			conn := openBenchDB(b, 500)
+			repo := NewHouseRepository(conn)
+			filter := HouseFilter{SortField: "price", Limit: pageSize}
+			ctx := context.Background()
+
+			start := benchDriver.queries.Load()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				houses, _, err := repo.ListHouses(ctx, filter)
+				if err != nil {
+					b.Fatalf("ListHouses failed: %v", err)
+				}
+				if len(houses) != pageSize {
+					b.Fatalf("expected %d houses, got %d", pageSize, len(houses))
+				}
+			}
+			b.StopTimer()
+
+			perOp := float64(benchDriver.queries.Load()-start) / float64(b.N)
+			b.ReportMetric(perOp, "queries/op")
+			// COUNT, joined SELECT, one gallery query
+			if perOp != 3 {
+				b.Fatalf("expected 3 queries per page, got %.1f", perOp)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -15,14 +16,14 @@ func NewHouseTypeRepository(db *sql.DB) *HouseTypeRepository {
 	return &HouseTypeRepository{db: db}
 }
 
-func (htr *HouseTypeRepository) GetAllHouseTypes() ([]models.HouseType, error) {
+func (htr *HouseTypeRepository) GetAllHouseTypes(ctx context.Context) ([]models.HouseType, error) {
 	query := `
 		SELECT id, name
 		FROM house_types
 		ORDER BY name
 	`
 
-	rows, err := htr.db.Query(query)
+	rows, err := htr.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query house types: %w", err)
 	}
@@ -41,7 +42,40 @@ func (htr *HouseTypeRepository) GetAllHouseTypes() ([]models.HouseType, error) {
 	return houseTypes, nil
 }
 
-func (htr *HouseTypeRepository) GetHouseTypeByID(id int) (*models.HouseType, error) {
+// GetAllHouseTypesWithCounts returns every house type with the number of
+// houses currently assigned to it.
+func (htr *HouseTypeRepository) GetAllHouseTypesWithCounts(ctx context.Context) ([]models.HouseTypeWithCount, error) {
+	query := `
+		SELECT ht.id, ht.name, COUNT(h.id)
+		FROM house_types ht
+		LEFT JOIN houses h ON h.house_type_id = ht.id AND h.deleted_at IS NULL
+		GROUP BY ht.id, ht.name
+		ORDER BY ht.name
+	`
+
+	rows, err := htr.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query house types: %w", err)
+	}
+	defer rows.Close()
+
+	var houseTypes []models.HouseTypeWithCount
+	for rows.Next() {
+		var houseType models.HouseTypeWithCount
+		err := rows.Scan(&houseType.ID, &houseType.Name, &houseType.HouseCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan house type: %w", err)
+		}
+		houseTypes = append(houseTypes, houseType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate house types: %w", err)
+	}
+
+	return houseTypes, nil
+}
+
+func (htr *HouseTypeRepository) GetHouseTypeByID(ctx context.Context, id int) (*models.HouseType, error) {
 	query := `
 		SELECT id, name
 		FROM house_types
@@ -49,11 +83,11 @@ func (htr *HouseTypeRepository) GetHouseTypeByID(id int) (*models.HouseType, err
 	`
 
 	var houseType models.HouseType
-	err := htr.db.QueryRow(query, id).Scan(&houseType.ID, &houseType.Name)
+	err := htr.db.QueryRowContext(ctx, query, id).Scan(&houseType.ID, &houseType.Name)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("house type with id %d not found", id)
+			return nil, fmt.Errorf("house type with id %d %w", id, ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to query house type: %w", err)
 	}
@@ -61,32 +95,62 @@ func (htr *HouseTypeRepository) GetHouseTypeByID(id int) (*models.HouseType, err
 	return &houseType, nil
 }
 
-func (htr *HouseTypeRepository) CreateHouseType(houseType *models.HouseType) error {
+// GetHouseTypeWithCountByID returns a house type with the number of houses
+// currently assigned to it.
+func (htr *HouseTypeRepository) GetHouseTypeWithCountByID(ctx context.Context, id int) (*models.HouseTypeWithCount, error) {
+	query := `
+		SELECT ht.id, ht.name, COUNT(h.id)
+		FROM house_types ht
+		LEFT JOIN houses h ON h.house_type_id = ht.id AND h.deleted_at IS NULL
+		WHERE ht.id = $1
+		GROUP BY ht.id, ht.name
+	`
+
+	var houseType models.HouseTypeWithCount
+	err := htr.db.QueryRowContext(ctx, query, id).Scan(&houseType.ID, &houseType.Name, &houseType.HouseCount)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("house type with id %d %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to query house type: %w", err)
+	}
+
+	return &houseType, nil
+}
+
+func (htr *HouseTypeRepository) CreateHouseType(ctx context.Context, houseType *models.HouseType) error {
 	query := `
 		INSERT INTO house_types (name)
 		VALUES ($1)
 		RETURNING id
 	`
 
-	err := htr.db.QueryRow(query, houseType.Name).Scan(&houseType.ID)
+	err := htr.db.QueryRowContext(ctx, query, houseType.Name).Scan(&houseType.ID)
 
 	if err != nil {
-		return fmt.Errorf("failed to create house type: %w", err)
+		if hasPQCode(err, pqUniqueViolation) {
+			return fmt.Errorf("house type %q: %w", houseType.Name, translatePQError(err))
+		}
+		return fmt.Errorf("failed to create house type: %w", translatePQError(err))
 	}
 
 	return nil
 }
 
-func (htr *HouseTypeRepository) UpdateHouseType(houseType *models.HouseType) error {
+func (htr *HouseTypeRepository) UpdateHouseType(ctx context.Context, houseType *models.HouseType) error {
 	query := `
 		UPDATE house_types 
 		SET name = $1
 		WHERE id = $2
 	`
 
-	result, err := htr.db.Exec(query, houseType.Name, houseType.ID)
+	result, err := htr.db.ExecContext(ctx, query, houseType.Name, houseType.ID)
 	if err != nil {
-		return fmt.Errorf("failed to update house type: %w", err)
+		if hasPQCode(err, pqUniqueViolation) {
+			return fmt.Errorf("house type %q: %w", houseType.Name, translatePQError(err))
+		}
+		return fmt.Errorf("failed to update house type: %w", translatePQError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -95,16 +159,16 @@ func (htr *HouseTypeRepository) UpdateHouseType(houseType *models.HouseType) err
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("house type with id %d not found", houseType.ID)
+		return fmt.Errorf("house type with id %d %w", houseType.ID, ErrNotFound)
 	}
 
 	return nil
 }
 
-func (htr *HouseTypeRepository) DeleteHouseType(id int) error {
+func (htr *HouseTypeRepository) DeleteHouseType(ctx context.Context, id int) error {
 	query := `DELETE FROM house_types WHERE id = $1`
 
-	result, err := htr.db.Exec(query, id)
+	result, err := htr.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete house type: %w", err)
 	}
@@ -115,7 +179,7 @@ func (htr *HouseTypeRepository) DeleteHouseType(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("house type with id %d not found", id)
+		return fmt.Errorf("house type with id %d %w", id, ErrNotFound)
 	}
 
 	return nil
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+)
+
+// MaxHouseImages is the largest gallery of a house, enforced by the position
+// check of the house_images table.
+const MaxHouseImages = 50
+
+// HouseImagePatch lists the columns changed by UpdateHouseImage. Nil fields
+// are left unchanged.
+type HouseImagePatch struct {
+	URL     *string
+	Caption *string
+	AltText *string
+}
+
+// IsEmpty reports whether the patch changes no column.
+func (p HouseImagePatch) IsEmpty() bool {
+	return p == HouseImagePatch{}
+}
+
+// CheckImageOrder returns an error wrapping ErrValidation unless order lists
+// each of the image IDs exactly once.
+func CheckImageOrder(ids, order []int) error {
+	sorted := slices.Sorted(slices.Values(order))
+	if !slices.Equal(sorted, slices.Sorted(slices.Values(ids))) {
+		return fmt.Errorf("image order %w: %v must list each of the images %v once", ErrValidation, order, ids)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+)
+
+func (s *Store) GetAllAgents(ctx context.Context) ([]models.Agent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var agents []models.Agent
+	for _, agent := range s.agents {
+		agents = append(agents, cloneAgent(agent))
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		a, b := agents[i], agents[j]
+		if a.FirstName != b.FirstName {
+			return a.FirstName < b.FirstName
+		}
+		if a.LastName != b.LastName {
+			return a.LastName < b.LastName
+		}
+		return a.ID < b.ID
+	})
+
+	return agents, nil
+}
+
+func (s *Store) GetAgentByID(ctx context.Context, id int) (*models.Agent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agent, ok := s.agents[id]
+	if !ok {
+		return nil, fmt.Errorf("agent with id %d %w", id, repository.ErrNotFound)
+	}
+	agent = cloneAgent(agent)
+
+	return &agent, nil
+}
+
+// cloneAgent returns a copy of agent that shares no pointers with it.
+func cloneAgent(agent models.Agent) models.Agent {
+	agent.ImageURL = copyPointer(agent.ImageURL)
+	agent.ImageRenditions = copyPointer(agent.ImageRenditions)
+	return agent
+}
+
+func validateAgent(agent *models.Agent) error {
+	if tooLong(agent.FirstName, maxAgentNameLength) || tooLong(agent.LastName, maxAgentNameLength) {
+		return fmt.Errorf("name %w: longer than %d characters", repository.ErrValidation, maxAgentNameLength)
+	}
+	return nil
+}
+
+func (s *Store) CreateAgent(ctx context.Context, agent *models.Agent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := validateAgent(agent); err != nil {
+		return fmt.Errorf("failed to create agent: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID.agent++
+	agent.ID = s.lastID.agent
+	s.agents[agent.ID] = cloneAgent(*agent)
+
+	return nil
+}
+
+// UpdateAgent keeps the image renditions only while the image URL is
+// unchanged.
+func (s *Store) UpdateAgent(ctx context.Context, agent *models.Agent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := validateAgent(agent); err != nil {
+		return fmt.Errorf("failed to update agent: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.agents[agent.ID]
+	if !ok {
+		return fmt.Errorf("agent with id %d %w", agent.ID, repository.ErrNotFound)
+	}
+	agent.ImageRenditions = nil
+	if equalPointers(existing.ImageURL, agent.ImageURL) {
+		agent.ImageRenditions = copyPointer(existing.ImageRenditions)
+	}
+	s.agents[agent.ID] = cloneAgent(*agent)
+
+	return nil
+}
+
+func (s *Store) SetAgentImage(ctx context.Context, id int, renditions models.Renditions) (*models.Agent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agent, ok := s.agents[id]
+	if !ok {
+		return nil, fmt.Errorf("agent with id %d %w", id, repository.ErrNotFound)
+	}
+	agent.ImageURL = &renditions.Large
+	agent.ImageRenditions = &renditions
+	s.agents[id] = agent
+	agent = cloneAgent(agent)
+
+	return &agent, nil
+}
+
+// DeleteAgent removes an agent and detaches it from its houses.
+func (s *Store) DeleteAgent(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.agents[id]; !ok {
+		return fmt.Errorf("agent with id %d %w", id, repository.ErrNotFound)
+	}
+	delete(s.agents, id)
+	for _, row := range s.houses {
+		if row.house.AgentID == id {
+			row.house.AgentID = 0
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,754 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/money"
+	"thugcorp.io/nomado/repository"
+)
+
+// output returns a copy of the stored house as the database would return it.
+func (row *houseRow) output() models.House {
+	house := row.house
+	copyAttributes(&house)
+	house.Tags = append([]string(nil), house.Tags...)
+	return house
+}
+
+// copyAttributes replaces the nullable fields of house with copies.
+func copyAttributes(house *models.House) {
+	house.ImageURL = copyPointer(house.ImageURL)
+	house.DeletedAt = copyPointer(house.DeletedAt)
+	house.Bedrooms = copyPointer(house.Bedrooms)
+	house.Bathrooms = copyPointer(house.Bathrooms)
+	house.FloorArea = copyPointer(house.FloorArea)
+	house.LotSize = copyPointer(house.LotSize)
+	house.YearBuilt = copyPointer(house.YearBuilt)
+	house.ParkingSpaces = copyPointer(house.ParkingSpaces)
+	house.Floors = copyPointer(house.Floors)
+	house.Latitude = copyPointer(house.Latitude)
+	house.Longitude = copyPointer(house.Longitude)
+}
+
+// deleted reports whether the house is in the trash.
+func (row *houseRow) deleted() bool {
+	return row.house.DeletedAt != nil
+}
+
+// liveHouse returns the stored house id unless it is missing or in the
+// trash. The caller must hold the lock.
+func (s *Store) liveHouse(id int) (*houseRow, bool) {
+	row, ok := s.houses[id]
+	if !ok || row.deleted() {
+		return nil, false
+	}
+	return row, true
+}
+
+// withDetails attaches the agent, house type and images of a house.
+func (s *Store) withDetails(row *houseRow) models.HouseWithDetails {
+	details := models.HouseWithDetails{House: row.output(), Images: cloneImages(row.images)}
+	if agent, ok := s.agents[row.house.AgentID]; ok {
+		agent = cloneAgent(agent)
+		details.Agent = &agent
+	}
+	if houseType, ok := s.houseTypes[row.house.HouseTypeID]; ok {
+		details.HouseType = &houseType
+	}
+	return details
+}
+
+// matches reports whether a house satisfies the filter conditions.
+func matches(house models.House, filter repository.HouseFilter) bool {
+	if filter.Currency != "" && house.Currency != filter.Currency {
+		return false
+	}
+	if filter.MinPrice != nil && house.Price < *filter.MinPrice {
+		return false
+	}
+	if filter.MaxPrice != nil && house.Price > *filter.MaxPrice {
+		return false
+	}
+	if filter.HouseTypeID != nil && house.HouseTypeID != *filter.HouseTypeID {
+		return false
+	}
+	if filter.AgentID != nil && house.AgentID != *filter.AgentID {
+		return false
+	}
+	if !matchesAttributes(house, filter) || !matchesLocation(house, filter) {
+		return false
+	}
+	if len(filter.Tags) == 0 {
+		return true
+	}
+	for _, tag := range filter.Tags {
+		found := slices.Contains(house.Tags, tag)
+		if found && filter.AnyTag {
+			return true
+		}
+		if !found && !filter.AnyTag {
+			return false
+		}
+	}
+	return !filter.AnyTag
+}
+
+// matchesAttributes reports whether a house satisfies the attribute ranges
+// of the filter.
+func matchesAttributes(house models.House, filter repository.HouseFilter) bool {
+	return filter.Bedrooms.Contains(house.Bedrooms) &&
+		filter.Bathrooms.Contains(house.Bathrooms) &&
+		filter.FloorArea.Contains(squareMetres(house.FloorArea, house.AreaUnit)) &&
+		filter.LotSize.Contains(squareMetres(house.LotSize, house.AreaUnit)) &&
+		filter.YearBuilt.Contains(house.YearBuilt) &&
+		filter.ParkingSpaces.Contains(house.ParkingSpaces) &&
+		filter.Floors.Contains(house.Floors)
+}
+
+// matchesLocation reports whether a house lies in the bounding box and
+// radius of the filter. Houses without coordinates match neither.
+func matchesLocation(house models.House, filter repository.HouseFilter) bool {
+	if filter.BBox == nil && filter.Near == nil {
+		return true
+	}
+	point, ok := location(house)
+	if !ok {
+		return false
+	}
+	if filter.BBox != nil && !filter.BBox.Contains(point) {
+		return false
+	}
+	return filter.Near == nil || filter.RadiusKm <= 0 || repository.DistanceKm(*filter.Near, point) <= filter.RadiusKm
+}
+
+// location returns the coordinates of a house, if it has any.
+func location(house models.House) (repository.Point, bool) {
+	if house.Latitude == nil || house.Longitude == nil {
+		return repository.Point{}, false
+	}
+	return repository.Point{Lat: *house.Latitude, Lng: *house.Longitude}, true
+}
+
+// distanceKm returns the distance of a house from filter.Near, or nil.
+func distanceKm(house models.House, filter repository.HouseFilter) *float64 {
+	point, ok := location(house)
+	if filter.Near == nil || !ok {
+		return nil
+	}
+	distance := repository.DistanceKm(*filter.Near, point)
+	return &distance
+}
+
+// squareMetres converts an optional area, like the generated *_sqm columns.
+func squareMetres(area *float64, unit string) *float64 {
+	if area == nil {
+		return nil
+	}
+	converted := repository.SquareMetres(*area, unit)
+	return &converted
+}
+
+// compareHouses orders two houses by a HouseSortFields key, returning a
+// negative number when a sorts first in ascending order.
+func compareHouses(a, b *houseRow, filter repository.HouseFilter) int {
+	switch filter.SortField {
+	case "price":
+		switch {
+		case a.house.Price < b.house.Price:
+			return -1
+		case a.house.Price > b.house.Price:
+			return 1
+		}
+	case "created_at":
+		if c := a.house.CreatedAt.Compare(b.house.CreatedAt); c != 0 {
+			return c
+		}
+	case "name":
+		if c := strings.Compare(lowerASCII(a.house.Name), lowerASCII(b.house.Name)); c != 0 {
+			return c
+		}
+	case "distance":
+		da, db := distanceKm(a.house, filter), distanceKm(b.house, filter)
+		if da != nil && db != nil && *da != *db {
+			return cmp.Compare(*da, *db)
+		}
+	}
+	return a.house.ID - b.house.ID
+}
+
+// lowerASCII lowers the ASCII letters of s, like LOWER under the "C"
+// collation that the PostgreSQL repository sorts names with.
+func lowerASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// sortHouses orders rows like orderAndPage in the PostgreSQL repository.
+// fallback is used when filter.SortField is not a known sort key.
+func sortHouses(rows []*houseRow, filter repository.HouseFilter, fallback func(a, b *houseRow) bool) {
+	if _, ok := repository.HouseSortFields[filter.SortField]; !ok {
+		sort.SliceStable(rows, func(i, j int) bool { return fallback(rows[i], rows[j]) })
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		c := compareHouses(rows[i], rows[j], filter)
+		if filter.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// page applies filter.Offset and filter.Limit to n sorted items.
+func page(n int, filter repository.HouseFilter) (start, end int) {
+	start = min(max(filter.Offset, 0), n)
+	end = n
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, n)
+	}
+	return start, end
+}
+
+// newestFirst is the default order of ListHouses.
+func newestFirst(a, b *houseRow) bool {
+	if c := a.house.CreatedAt.Compare(b.house.CreatedAt); c != 0 {
+		return c > 0
+	}
+	return a.house.ID > b.house.ID
+}
+
+// ListHouses returns a page of houses, with their agent and house type,
+// matching the filter together with the total number of matching rows.
+func (s *Store) ListHouses(ctx context.Context, filter repository.HouseFilter) ([]models.HouseWithDetails, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rows []*houseRow
+	for _, row := range s.houses {
+		if !row.deleted() && matches(row.output(), filter) {
+			rows = append(rows, row)
+		}
+	}
+	sortHouses(rows, filter, newestFirst)
+
+	var houses []models.HouseWithDetails
+	start, end := page(len(rows), filter)
+	for _, row := range rows[start:end] {
+		house := s.withDetails(row)
+		house.DistanceKm = distanceKm(row.house, filter)
+		houses = append(houses, house)
+	}
+
+	return houses, len(rows), nil
+}
+
+// SearchHouses matches the search text against house names, tags and
+// descriptions. It accepts the same web search syntax as the PostgreSQL
+// repository but compares whole words only: it neither stems words nor drops
+// stop words, and ranks are comparable only within one result set.
+func (s *Store) SearchHouses(ctx context.Context, search string, filter repository.HouseFilter) ([]repository.HouseSearchResult, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	query := parseSearch(search)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ranks := make(map[*houseRow]float64)
+	var rows []*houseRow
+	for _, row := range s.houses {
+		house := row.output()
+		if row.deleted() || !matches(house, filter) {
+			continue
+		}
+		if rank, ok := query.rank(house); ok {
+			ranks[row] = rank
+			rows = append(rows, row)
+		}
+	}
+	sortHouses(rows, filter, func(a, b *houseRow) bool {
+		if ranks[a] != ranks[b] {
+			return ranks[a] > ranks[b]
+		}
+		return a.house.ID < b.house.ID
+	})
+
+	var results []repository.HouseSearchResult
+	start, end := page(len(rows), filter)
+	for _, row := range rows[start:end] {
+		house := s.withDetails(row)
+		house.DistanceKm = distanceKm(row.house, filter)
+		results = append(results, repository.HouseSearchResult{
+			House:   house,
+			Rank:    ranks[row],
+			Snippet: query.headline(row.house.Description),
+		})
+	}
+
+	return results, len(rows), nil
+}
+
+// GetTopHousesWithDetails returns the most expensive houses priced in
+// currency with their agent and house type.
+func (s *Store) GetTopHousesWithDetails(ctx context.Context, currency string, limit int) ([]models.HouseWithDetails, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rows []*houseRow
+	for _, row := range s.houses {
+		if !row.deleted() && row.house.Currency == currency {
+			rows = append(rows, row)
+		}
+	}
+	sortHouses(rows, repository.HouseFilter{SortField: "price", SortDesc: true}, nil)
+
+	var houses []models.HouseWithDetails
+	for _, row := range rows[:min(max(limit, 0), len(rows))] {
+		houses = append(houses, s.withDetails(row))
+	}
+
+	return houses, nil
+}
+
+func (s *Store) GetHouseByID(ctx context.Context, id int) (*models.House, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.liveHouse(id)
+	if !ok {
+		return nil, fmt.Errorf("house with id %d %w", id, repository.ErrNotFound)
+	}
+	house := row.output()
+
+	return &house, nil
+}
+
+// GetHouseWithDetailsByID returns a house with its agent and house type.
+func (s *Store) GetHouseWithDetailsByID(ctx context.Context, id int) (*models.HouseWithDetails, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.liveHouse(id)
+	if !ok {
+		return nil, fmt.Errorf("house with id %d %w", id, repository.ErrNotFound)
+	}
+	house := s.withDetails(row)
+
+	return &house, nil
+}
+
+// checkHouse applies the column and foreign key constraints of the houses
+// table. The caller must hold the lock.
+func (s *Store) checkHouse(house *models.House) error {
+	if err := checkHouseValues(house); err != nil {
+		return err
+	}
+	if err := s.checkHouseType(house.HouseTypeID); err != nil {
+		return err
+	}
+	return s.checkAgent(house.AgentID)
+}
+
+// checkHouseValues applies the column constraints of the houses table.
+func checkHouseValues(house *models.House) error {
+	if tooLong(house.Name, maxHouseNameLength) {
+		return fmt.Errorf("name %w: longer than %d characters", repository.ErrValidation, maxHouseNameLength)
+	}
+	if house.Price >= maxPrice || house.Price <= -maxPrice {
+		return fmt.Errorf("price %w: %v is out of range", repository.ErrValidation, house.Price)
+	}
+	if !money.ValidCurrency(house.Currency) {
+		return fmt.Errorf("currency %w: %q is not a currency code", repository.ErrValidation, house.Currency)
+	}
+	if err := checkAttributes(house); err != nil {
+		return err
+	}
+	return checkLocation(house)
+}
+
+// checkLocation applies the column constraints of the address and
+// coordinates.
+func checkLocation(house *models.House) error {
+	address := house.Address
+	for _, part := range []struct {
+		column, value string
+		max           int
+	}{
+		{"street", address.Street, maxStreetLength},
+		{"city", address.City, maxCityLength},
+		{"region", address.Region, maxRegionLength},
+		{"postal_code", address.PostalCode, maxPostalCodeLength},
+	} {
+		if tooLong(part.value, part.max) {
+			return fmt.Errorf("%s %w: longer than %d characters", part.column, repository.ErrValidation, part.max)
+		}
+	}
+	if !repository.ValidCountry(address.Country) {
+		return fmt.Errorf("country %w: %q is not a country code", repository.ErrValidation, address.Country)
+	}
+
+	if (house.Latitude == nil) != (house.Longitude == nil) {
+		return fmt.Errorf("coordinates %w: latitude and longitude must be set together", repository.ErrValidation)
+	}
+	if house.Latitude != nil && !repository.ValidCoordinates(*house.Latitude, *house.Longitude) {
+		return fmt.Errorf("coordinates %w: %v, %v is out of range", repository.ErrValidation, *house.Latitude, *house.Longitude)
+	}
+	return nil
+}
+
+// checkAttributes applies the column constraints of the property attributes.
+func checkAttributes(house *models.House) error {
+	ints := []struct {
+		column   string
+		value    *int
+		min, max int
+	}{
+		{"bedrooms", house.Bedrooms, 0, maxSmallint},
+		{"year_built", house.YearBuilt, -maxSmallint - 1, maxSmallint},
+		{"parking_spaces", house.ParkingSpaces, 0, maxSmallint},
+		{"floors", house.Floors, 1, maxSmallint},
+	}
+	for _, i := range ints {
+		if i.value != nil && (*i.value < i.min || *i.value > i.max) {
+			return fmt.Errorf("%s %w: %d is out of range", i.column, repository.ErrValidation, *i.value)
+		}
+	}
+
+	// The lower bounds are CHECK constraints, the upper ones the precision.
+	// Both apply to the value rounded to the scale of the column.
+	if house.Bathrooms != nil && (roundScale(*house.Bathrooms, 1) < 0 || roundScale(*house.Bathrooms, 1) >= maxBathrooms) {
+		return fmt.Errorf("bathrooms %w: %v is out of range", repository.ErrValidation, *house.Bathrooms)
+	}
+	if house.FloorArea != nil && (roundScale(*house.FloorArea, 2) <= 0 || roundScale(*house.FloorArea, 2) >= maxFloorArea) {
+		return fmt.Errorf("floor_area %w: %v is out of range", repository.ErrValidation, *house.FloorArea)
+	}
+	if house.LotSize != nil && (roundScale(*house.LotSize, 2) <= 0 || roundScale(*house.LotSize, 2) >= maxLotSize) {
+		return fmt.Errorf("lot_size %w: %v is out of range", repository.ErrValidation, *house.LotSize)
+	}
+	if !repository.ValidAreaUnit(house.AreaUnit) {
+		return fmt.Errorf("area_unit %w: %q is not an area unit", repository.ErrValidation, house.AreaUnit)
+	}
+	return nil
+}
+
+// roundScale rounds value to scale decimals, as PostgreSQL does when storing
+// it in a NUMERIC column.
+func roundScale(value float64, scale int) float64 {
+	factor := math.Pow10(scale)
+	return math.Round(value*factor) / factor
+}
+
+// setDefaults fills in the column defaults of an unset currency and area
+// unit.
+func setDefaults(house *models.House) {
+	if house.Currency == "" {
+		house.Currency = money.DefaultCurrency
+	}
+	if house.AreaUnit == "" {
+		house.AreaUnit = models.AreaUnitSquareMetres
+	}
+}
+
+// checkHouseType and checkAgent apply the foreign key constraints of the
+// houses table. The caller must hold the lock.
+func (s *Store) checkHouseType(id int) error {
+	if _, ok := s.houseTypes[id]; !ok {
+		return fmt.Errorf("house_type_id %w: %d", repository.ErrForeignKey, id)
+	}
+	return nil
+}
+
+func (s *Store) checkAgent(id int) error {
+	if _, ok := s.agents[id]; !ok {
+		return fmt.Errorf("agent_id %w: %d", repository.ErrForeignKey, id)
+	}
+	return nil
+}
+
+// store saves a copy of house as the database would, with the tags
+// normalised.
+func (s *Store) store(row *houseRow, house *models.House) {
+	row.house = *house
+	row.house.Tags = repository.NormalizeTags(house.Tags)
+	copyAttributes(&row.house)
+	s.houses[house.ID] = row
+}
+
+func (s *Store) CreateHouse(ctx context.Context, house *models.House) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	setDefaults(house)
+	if err := s.checkHouse(house); err != nil {
+		return fmt.Errorf("failed to create house: %w", err)
+	}
+
+	s.lastID.house++
+	house.ID = s.lastID.house
+	house.CreatedAt = now()
+	house.UpdatedAt = house.CreatedAt
+	house.Version = 1
+	house.DeletedAt = nil
+	house.Tags = repository.NormalizeTags(house.Tags)
+	s.store(&houseRow{}, house)
+
+	return nil
+}
+
+// writable returns the stored house id if it exists outside the trash and,
+// unless version is 0, is at that version. The caller must hold the lock.
+func (s *Store) writable(id, version int) (*houseRow, error) {
+	row, ok := s.liveHouse(id)
+	if !ok {
+		return nil, fmt.Errorf("house with id %d %w", id, repository.ErrNotFound)
+	}
+	if version != 0 && row.house.Version != version {
+		return nil, fmt.Errorf("house with id %d %w", id, repository.ErrVersionMismatch)
+	}
+	return row, nil
+}
+
+// UpdateHouse replaces every field of a house. If house.Version is set, the
+// update only succeeds while the stored version matches it. On success
+// house.Version holds the new version.
+func (s *Store) UpdateHouse(ctx context.Context, house *models.House) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.writable(house.ID, house.Version)
+	if err != nil {
+		return err
+	}
+	setDefaults(house)
+	if err := s.checkHouse(house); err != nil {
+		return fmt.Errorf("failed to update house: %w", err)
+	}
+
+	house.CreatedAt = existing.house.CreatedAt
+	house.UpdatedAt = now()
+	house.Version = existing.house.Version + 1
+	house.DeletedAt = nil
+	house.Tags = repository.NormalizeTags(house.Tags)
+	s.store(existing, house)
+
+	return nil
+}
+
+// PatchHouse updates only the fields set in patch and returns the updated
+// house. A non-zero version must match the stored one. Like the database, it
+// checks the agent and house type only when the patch changes them. An empty
+// patch returns the house unchanged.
+func (s *Store) PatchHouse(ctx context.Context, id, version int, patch repository.HousePatch) (*models.House, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.writable(id, version)
+	if err != nil {
+		return nil, err
+	}
+	house := existing.output()
+	if patch.IsEmpty() {
+		return &house, nil
+	}
+
+	patch.Apply(&house)
+	if err := checkHouseValues(&house); err != nil {
+		return nil, fmt.Errorf("failed to patch house: %w", err)
+	}
+	if patch.HouseTypeID != nil {
+		if err := s.checkHouseType(house.HouseTypeID); err != nil {
+			return nil, fmt.Errorf("failed to patch house: %w", err)
+		}
+	}
+	if patch.AgentID != nil {
+		if err := s.checkAgent(house.AgentID); err != nil {
+			return nil, fmt.Errorf("failed to patch house: %w", err)
+		}
+	}
+
+	house.UpdatedAt = now()
+	house.Version++
+	s.store(existing, &house)
+	house = existing.output()
+
+	return &house, nil
+}
+
+// DeleteHouse moves a house to the trash. A non-zero version must match the
+// stored one.
+func (s *Store) DeleteHouse(ctx context.Context, id, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, err := s.writable(id, version)
+	if err != nil {
+		return err
+	}
+	deletedAt := now()
+	row.house.DeletedAt = &deletedAt
+	row.house.Version++
+
+	return nil
+}
+
+// ListDeletedHouses returns a page of the houses in the trash, most recently
+// deleted first, together with the number of houses in the trash.
+func (s *Store) ListDeletedHouses(ctx context.Context, limit, offset int) ([]models.HouseWithDetails, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rows []*houseRow
+	for _, row := range s.houses {
+		if row.deleted() {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if c := rows[i].house.DeletedAt.Compare(*rows[j].house.DeletedAt); c != 0 {
+			return c > 0
+		}
+		return rows[i].house.ID > rows[j].house.ID
+	})
+
+	var houses []models.HouseWithDetails
+	start, end := page(len(rows), repository.HouseFilter{Limit: limit, Offset: offset})
+	for _, row := range rows[start:end] {
+		houses = append(houses, s.withDetails(row))
+	}
+
+	return houses, len(rows), nil
+}
+
+// RestoreHouse takes a house out of the trash and returns it. A house that
+// is not in the trash is not found.
+func (s *Store) RestoreHouse(ctx context.Context, id int) (*models.House, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.houses[id]
+	if !ok || !row.deleted() {
+		return nil, fmt.Errorf("deleted house with id %d %w", id, repository.ErrNotFound)
+	}
+	row.house.DeletedAt = nil
+	row.house.UpdatedAt = now()
+	row.house.Version++
+	house := row.output()
+
+	return &house, nil
+}
+
+// PurgeDeletedHouses permanently removes the houses moved to the trash
+// before cutoff and returns how many were removed, with the renditions of
+// their uploaded photos.
+func (s *Store) PurgeDeletedHouses(ctx context.Context, cutoff time.Time) (int, []models.Renditions, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	var uploads []models.Renditions
+	for id, row := range s.houses {
+		if row.deleted() && row.house.DeletedAt.Before(cutoff) {
+			for _, image := range row.images {
+				if image.Renditions != nil {
+					uploads = append(uploads, *image.Renditions)
+				}
+			}
+			delete(s.houses, id)
+			purged++
+		}
+	}
+
+	return purged, uploads, nil
+}
+
+// ListTags returns every tag in use with the number of houses carrying it,
+// most used first.
+func (s *Store) ListTags(ctx context.Context) ([]models.TagCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, row := range s.houses {
+		if row.deleted() {
+			continue
+		}
+		for _, tag := range row.house.Tags {
+			counts[tag]++
+		}
+	}
+
+	var tags []models.TagCount
+	for tag, count := range counts {
+		tags = append(tags, models.TagCount{Tag: tag, HouseCount: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].HouseCount != tags[j].HouseCount {
+			return tags[i].HouseCount > tags[j].HouseCount
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	return tags, nil
+}
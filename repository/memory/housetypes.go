@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+)
+
+func (s *Store) GetAllHouseTypes(ctx context.Context) ([]models.HouseType, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var houseTypes []models.HouseType
+	for _, houseType := range s.houseTypes {
+		houseTypes = append(houseTypes, houseType)
+	}
+	sort.Slice(houseTypes, func(i, j int) bool {
+		return houseTypes[i].Name < houseTypes[j].Name
+	})
+
+	return houseTypes, nil
+}
+
+// GetAllHouseTypesWithCounts returns every house type with the number of
+// houses currently assigned to it.
+func (s *Store) GetAllHouseTypesWithCounts(ctx context.Context) ([]models.HouseTypeWithCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	houseTypes, err := s.GetAllHouseTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var counted []models.HouseTypeWithCount
+	for _, houseType := range houseTypes {
+		counted = append(counted, models.HouseTypeWithCount{
+			HouseType:  houseType,
+			HouseCount: s.countHousesOfType(houseType.ID),
+		})
+	}
+
+	return counted, nil
+}
+
+func (s *Store) countHousesOfType(id int) int {
+	count := 0
+	for _, row := range s.houses {
+		if row.house.HouseTypeID == id && !row.deleted() {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Store) GetHouseTypeByID(ctx context.Context, id int) (*models.HouseType, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	houseType, ok := s.houseTypes[id]
+	if !ok {
+		return nil, fmt.Errorf("house type with id %d %w", id, repository.ErrNotFound)
+	}
+
+	return &houseType, nil
+}
+
+// GetHouseTypeWithCountByID returns a house type with the number of houses
+// currently assigned to it.
+func (s *Store) GetHouseTypeWithCountByID(ctx context.Context, id int) (*models.HouseTypeWithCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	houseType, ok := s.houseTypes[id]
+	if !ok {
+		return nil, fmt.Errorf("house type with id %d %w", id, repository.ErrNotFound)
+	}
+
+	return &models.HouseTypeWithCount{
+		HouseType:  houseType,
+		HouseCount: s.countHousesOfType(id),
+	}, nil
+}
+
+// nameTaken reports whether another house type already uses name.
+func (s *Store) nameTaken(name string, exceptID int) bool {
+	for _, houseType := range s.houseTypes {
+		if houseType.Name == name && houseType.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CreateHouseType(ctx context.Context, houseType *models.HouseType) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if tooLong(houseType.Name, maxHouseTypeNameLength) {
+		return fmt.Errorf("failed to create house type: name %w: longer than %d characters", repository.ErrValidation, maxHouseTypeNameLength)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nameTaken(houseType.Name, 0) {
+		return fmt.Errorf("house type %q: %w", houseType.Name, &repository.ColumnError{Column: "name", Err: repository.ErrConflict})
+	}
+	s.lastID.houseType++
+	houseType.ID = s.lastID.houseType
+	s.houseTypes[houseType.ID] = *houseType
+
+	return nil
+}
+
+func (s *Store) UpdateHouseType(ctx context.Context, houseType *models.HouseType) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if tooLong(houseType.Name, maxHouseTypeNameLength) {
+		return fmt.Errorf("failed to update house type: name %w: longer than %d characters", repository.ErrValidation, maxHouseTypeNameLength)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nameTaken(houseType.Name, houseType.ID) {
+		return fmt.Errorf("house type %q: %w", houseType.Name, &repository.ColumnError{Column: "name", Err: repository.ErrConflict})
+	}
+	if _, ok := s.houseTypes[houseType.ID]; !ok {
+		return fmt.Errorf("house type with id %d %w", houseType.ID, repository.ErrNotFound)
+	}
+	s.houseTypes[houseType.ID] = *houseType
+
+	return nil
+}
+
+// DeleteHouseType removes a house type and detaches it from its houses.
+func (s *Store) DeleteHouseType(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.houseTypes[id]; !ok {
+		return fmt.Errorf("house type with id %d %w", id, repository.ErrNotFound)
+	}
+	delete(s.houseTypes, id)
+	for _, row := range s.houses {
+		if row.house.HouseTypeID == id {
+			row.house.HouseTypeID = 0
+		}
+	}
+
+	return nil
+}
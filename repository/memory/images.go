@@ -0,0 +1,243 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+)
+
+// gallery returns the stored house id unless it is missing or in the trash.
+// The caller must hold the lock.
+func (s *Store) gallery(houseID int) (*houseRow, error) {
+	row, ok := s.liveHouse(houseID)
+	if !ok {
+		return nil, fmt.Errorf("house with id %d %w", houseID, repository.ErrNotFound)
+	}
+	return row, nil
+}
+
+// image returns the index of an image in the gallery of row.
+func (row *houseRow) image(imageID int) (int, error) {
+	i := slices.IndexFunc(row.images, func(image models.HouseImage) bool { return image.ID == imageID })
+	if i < 0 {
+		return 0, fmt.Errorf("image with id %d of house %d %w", imageID, row.house.ID, repository.ErrNotFound)
+	}
+	return i, nil
+}
+
+// touch records a change of the gallery like the database does: the house
+// gets a new version, and the positions follow the order of the images.
+func (row *houseRow) touch() {
+	for i := range row.images {
+		row.images[i].Position = i
+	}
+	row.house.UpdatedAt = now()
+	row.house.Version++
+}
+
+// setCover makes the image at index i the only cover of the gallery.
+func (row *houseRow) setCover(i int) {
+	for j := range row.images {
+		row.images[j].IsCover = j == i
+	}
+}
+
+// checkImage mirrors the checks of the house_images table.
+// cloneImages returns a copy of images, never nil, that shares no pointers
+// with it.
+func cloneImages(images []models.HouseImage) []models.HouseImage {
+	clones := make([]models.HouseImage, len(images))
+	for i, image := range images {
+		image.Renditions = copyPointer(image.Renditions)
+		clones[i] = image
+	}
+	return clones
+}
+
+func checkImage(image *models.HouseImage) error {
+	switch {
+	case image.URL == "":
+		return fmt.Errorf("url %w: must not be empty", repository.ErrValidation)
+	case tooLong(image.Caption, maxImageCaptionLength):
+		return fmt.Errorf("caption %w: longer than %d characters", repository.ErrValidation, maxImageCaptionLength)
+	case tooLong(image.AltText, maxImageAltTextLength):
+		return fmt.Errorf("alt_text %w: longer than %d characters", repository.ErrValidation, maxImageAltTextLength)
+	}
+	return nil
+}
+
+func (s *Store) ListHouseImages(ctx context.Context, houseID int) ([]models.HouseImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, err := s.gallery(houseID)
+	if err != nil {
+		return nil, err
+	}
+	return cloneImages(row.images), nil
+}
+
+func (s *Store) AddHouseImage(ctx context.Context, image *models.HouseImage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, err := s.gallery(image.HouseID)
+	if err != nil {
+		return err
+	}
+	if err := checkImage(image); err != nil {
+		return fmt.Errorf("failed to add house image: %w", err)
+	}
+	if len(row.images) >= repository.MaxHouseImages {
+		return fmt.Errorf("failed to add house image: position %w: a gallery holds at most %d images", repository.ErrValidation, repository.MaxHouseImages)
+	}
+
+	s.lastID.image++
+	image.ID = s.lastID.image
+	image.Position = len(row.images)
+	image.IsCover = image.IsCover || len(row.images) == 0
+	image.CreatedAt = now()
+	stored := *image
+	stored.Renditions = copyPointer(image.Renditions)
+	row.images = append(row.images, stored)
+	if image.IsCover {
+		row.setCover(image.Position)
+	}
+	row.touch()
+
+	return nil
+}
+
+func (s *Store) UpdateHouseImage(ctx context.Context, houseID, imageID int, patch repository.HouseImagePatch) (*models.HouseImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, err := s.gallery(houseID)
+	if err != nil {
+		return nil, err
+	}
+	i, err := row.image(imageID)
+	if err != nil {
+		return nil, err
+	}
+	image := row.images[i]
+	if patch.IsEmpty() {
+		image.Renditions = copyPointer(image.Renditions)
+		return &image, nil
+	}
+
+	if patch.URL != nil {
+		// Renditions belong to the URL they were uploaded as
+		if *patch.URL != image.URL {
+			image.Renditions = nil
+		}
+		image.URL = *patch.URL
+	}
+	if patch.Caption != nil {
+		image.Caption = *patch.Caption
+	}
+	if patch.AltText != nil {
+		image.AltText = *patch.AltText
+	}
+	if err := checkImage(&image); err != nil {
+		return nil, fmt.Errorf("failed to update house image: %w", err)
+	}
+	row.images[i] = image
+	row.touch()
+	image.Renditions = copyPointer(image.Renditions)
+
+	return &image, nil
+}
+
+func (s *Store) DeleteHouseImage(ctx context.Context, houseID, imageID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, err := s.gallery(houseID)
+	if err != nil {
+		return err
+	}
+	i, err := row.image(imageID)
+	if err != nil {
+		return err
+	}
+	cover := row.images[i].IsCover
+	row.images = slices.Delete(row.images, i, i+1)
+	if cover && len(row.images) > 0 {
+		row.setCover(0)
+	}
+	row.touch()
+
+	return nil
+}
+
+func (s *Store) ReorderHouseImages(ctx context.Context, houseID int, imageIDs []int) ([]models.HouseImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, err := s.gallery(houseID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(row.images))
+	for i, image := range row.images {
+		ids[i] = image.ID
+	}
+	if err := repository.CheckImageOrder(ids, imageIDs); err != nil {
+		return nil, err
+	}
+
+	images := make([]models.HouseImage, len(imageIDs))
+	for i, id := range imageIDs {
+		images[i] = row.images[slices.Index(ids, id)]
+	}
+	row.images = images
+	row.touch()
+
+	return cloneImages(row.images), nil
+}
+
+func (s *Store) SetHouseCover(ctx context.Context, houseID, imageID int) ([]models.HouseImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, err := s.gallery(houseID)
+	if err != nil {
+		return nil, err
+	}
+	i, err := row.image(imageID)
+	if err != nil {
+		return nil, err
+	}
+	row.setCover(i)
+	row.touch()
+
+	return cloneImages(row.images), nil
+}
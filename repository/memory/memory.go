@@ -0,0 +1,99 @@
+// Package memory provides an in-memory implementation of the repository
+// stores. It mirrors the PostgreSQL repositories (ordering, defaults,
+// not-found and conflict errors, ON DELETE SET NULL, failing once the context
+// is done) so that handlers can be tested without a database.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/money"
+	"thugcorp.io/nomado/repository"
+)
+
+// Column sizes of the schema in db/migrations
+const (
+	maxHouseNameLength     = 255
+	maxAgentNameLength     = 100
+	maxHouseTypeNameLength = 100
+	maxPrice               = money.Amount(1e12) // DECIMAL(12,2)
+	maxBathrooms           = 100                // NUMERIC(3,1)
+	maxFloorArea           = 1e8                // NUMERIC(10,2)
+	maxLotSize             = 1e10               // NUMERIC(12,2)
+	maxSmallint            = 32767
+	maxStreetLength        = 255
+	maxCityLength          = 100
+	maxRegionLength        = 100
+	maxPostalCodeLength    = 20
+	maxImageCaptionLength  = 500
+	maxImageAltTextLength  = 255
+)
+
+// Store holds houses with their images, agents, house types and places. It
+// implements repository.HouseStore, repository.HouseImageStore,
+// repository.TagStore, repository.AgentStore, repository.HouseTypeStore and
+// repository.GazetteerStore and is safe for concurrent use.
+type Store struct {
+	mu         sync.RWMutex
+	houses     map[int]*houseRow
+	agents     map[int]models.Agent
+	houseTypes map[int]models.HouseType
+	places     []models.Place // in import order
+	lastID     struct{ house, image, agent, houseType int }
+}
+
+// houseRow is a stored house with its gallery.
+type houseRow struct {
+	house  models.House
+	images []models.HouseImage // by position
+}
+
+var (
+	_ repository.HouseStore      = (*Store)(nil)
+	_ repository.HouseImageStore = (*Store)(nil)
+	_ repository.TagStore        = (*Store)(nil)
+	_ repository.AgentStore      = (*Store)(nil)
+	_ repository.HouseTypeStore  = (*Store)(nil)
+	_ repository.GazetteerStore  = (*Store)(nil)
+)
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{
+		houses:     make(map[int]*houseRow),
+		agents:     make(map[int]models.Agent),
+		houseTypes: make(map[int]models.HouseType),
+	}
+}
+
+// now returns the current time at the precision of a PostgreSQL TIMESTAMPTZ
+// column, in UTC like the PostgreSQL repositories return it.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// copyPointer returns a copy of a nullable value so that callers cannot
+// modify stored values.
+func copyPointer[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// equalPointers reports whether a and b are both nil or point to equal
+// values, as IS NOT DISTINCT FROM compares nullable columns.
+func equalPointers[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// tooLong reports whether s exceeds a VARCHAR(n) column.
+func tooLong(s string, n int) bool {
+	return len([]rune(s)) > n
+}
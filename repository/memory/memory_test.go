@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"thugcorp.io/nomado/repository/repositorytest"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Stores {
+		store := New()
+		return repositorytest.Stores{Houses: store, Images: store, Tags: store, Agents: store, HouseTypes: store, Gazetteer: store}
+	})
+}
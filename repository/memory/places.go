@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sort"
+
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/repository"
+)
+
+// Geocode scores every place with repository.GeocodeQuery.Score and orders
+// them like the PostgreSQL repository.
+func (s *Store) Geocode(ctx context.Context, query string, limit int) ([]models.Place, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	q := repository.ParseGeocodeQuery(query)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type match struct {
+		index, score int
+	}
+	var matches []match
+	for i, place := range s.places {
+		if score := q.Score(place); score > 0 {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		pa, pb := s.places[a.index], s.places[b.index]
+		switch {
+		case a.score != b.score:
+			return a.score > b.score
+		case pa.Population != pb.Population:
+			return pa.Population > pb.Population
+		case pa.Name != pb.Name:
+			return pa.Name < pb.Name
+		}
+		return a.index < b.index
+	})
+
+	var places []models.Place
+	for _, m := range matches[:min(limit, len(matches))] {
+		places = append(places, s.places[m.index])
+	}
+	return places, nil
+}
+
+// ReverseGeocode orders every place by its distance from p.
+func (s *Store) ReverseGeocode(ctx context.Context, p repository.Point, limit int) ([]models.Place, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	distances := make([]float64, len(s.places))
+	order := make([]int, len(s.places))
+	for i, place := range s.places {
+		distances[i] = repository.DistanceKm(p, repository.Point{Lat: place.Latitude, Lng: place.Longitude})
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return distances[order[i]] < distances[order[j]] })
+
+	var places []models.Place
+	for _, i := range order[:min(limit, len(order))] {
+		place := s.places[i]
+		place.DistanceKm = &distances[i]
+		places = append(places, place)
+	}
+	return places, nil
+}
+
+// ImportPlaces collects the places before storing any, so that a failed
+// import leaves the gazetteer unchanged.
+func (s *Store) ImportPlaces(ctx context.Context, places iter.Seq2[models.Place, error], replace bool) (int, error) {
+	var imported []models.Place
+	for place, err := range places {
+		if err != nil {
+			return 0, err
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if err := repository.CheckPlace(place); err != nil {
+			return 0, err
+		}
+		place.DistanceKm = nil
+		imported = append(imported, place)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if replace {
+		s.places = nil
+	}
+	s.places = append(slices.Clip(s.places), imported...)
+	return len(imported), nil
+}
@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"thugcorp.io/nomado/models"
+)
+
+// Field weights of the search_vector column, as applied by ts_rank
+const (
+	nameWeight        = 1.0
+	tagsWeight        = 0.4
+	descriptionWeight = 0.2
+)
+
+// Headline length, matching the ts_headline options of the PostgreSQL repository
+const headlineMaxWords = 35
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// searchTerm is a word or quoted phrase, optionally excluded with a minus.
+type searchTerm struct {
+	words  []string
+	negate bool
+}
+
+// searchQuery is a parsed web search: a house matches when it satisfies every
+// term of at least one of the OR-separated groups.
+type searchQuery [][]searchTerm
+
+// parseSearch parses the websearch_to_tsquery syntax: words, "quoted
+// phrases", OR and -excluded terms.
+func parseSearch(search string) searchQuery {
+	query := searchQuery{nil}
+	rest := search
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if rest == "" {
+			break
+		}
+
+		negate := false
+		if rest[0] == '-' {
+			negate = true
+			rest = rest[1:]
+		}
+
+		var text string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				text, rest = rest[1:], ""
+			} else {
+				text, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(rest, " \t\r\n")
+			if end < 0 {
+				end = len(rest)
+			}
+			text, rest = rest[:end], rest[end:]
+			if !negate && strings.EqualFold(text, "or") {
+				query = append(query, nil)
+				continue
+			}
+		}
+
+		if words := searchWords(text); len(words) > 0 {
+			last := len(query) - 1
+			query[last] = append(query[last], searchTerm{words: words, negate: negate})
+		}
+	}
+	return query
+}
+
+// searchWords splits text into lower-case words.
+func searchWords(text string) []string {
+	return wordPattern.FindAllString(strings.ToLower(text), -1)
+}
+
+// occurrences counts how often the phrase appears in words.
+func occurrences(words, phrase []string) int {
+	count := 0
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, word := range phrase {
+			if words[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			count++
+		}
+	}
+	return count
+}
+
+// rank reports whether the house matches the query and, if so, a relevance
+// score weighted like the search_vector column.
+func (q searchQuery) rank(house models.House) (float64, bool) {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{searchWords(house.Name), nameWeight},
+		{searchWords(strings.Join(house.Tags, " ")), tagsWeight},
+		{searchWords(house.Description), descriptionWeight},
+	}
+
+	best, matched := 0.0, false
+	for _, group := range q {
+		if len(group) == 0 {
+			continue
+		}
+		score, ok := 0.0, true
+		for _, term := range group {
+			found := 0.0
+			for _, field := range fields {
+				found += float64(occurrences(field.words, term.words)) * field.weight
+			}
+			if (found > 0) == term.negate {
+				ok = false
+				break
+			}
+			score += found
+		}
+		if ok && (!matched || score > best) {
+			best, matched = score, true
+		}
+	}
+	return best, matched
+}
+
+// headline returns up to headlineMaxWords words of the description, starting
+// near the first match, HTML-escaped, with the matching words wrapped in
+// <mark></mark>.
+func (q searchQuery) headline(description string) string {
+	marked := make(map[string]bool)
+	for _, group := range q {
+		for _, term := range group {
+			if !term.negate {
+				for _, word := range term.words {
+					marked[word] = true
+				}
+			}
+		}
+	}
+
+	spans := wordPattern.FindAllStringIndex(description, -1)
+	if len(spans) == 0 {
+		return html.EscapeString(description)
+	}
+
+	first := 0
+	for i, span := range spans {
+		if marked[strings.ToLower(description[span[0]:span[1]])] {
+			first = i
+			break
+		}
+	}
+	first = max(0, min(first, len(spans)-headlineMaxWords))
+	last := min(first+headlineMaxWords, len(spans)) - 1
+
+	var b strings.Builder
+	pos := spans[first][0]
+	for _, span := range spans[first : last+1] {
+		b.WriteString(html.EscapeString(description[pos:span[0]]))
+		word := html.EscapeString(description[span[0]:span[1]])
+		if marked[strings.ToLower(word)] {
+			b.WriteString("<mark>" + word + "</mark>")
+		} else {
+			b.WriteString(word)
+		}
+		pos = span[1]
+	}
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// queryBuilder assembles a SELECT or UPDATE statement from trusted SQL
+// fragments while keeping every user supplied value in the positional
+// argument list.
+type queryBuilder struct {
+	assignments []string
+	conditions  []string
+	args        []interface{}
+}
+
+// arg registers a value and returns its positional placeholder ($1, $2, ...).
+func (qb *queryBuilder) arg(value interface{}) string {
+	qb.args = append(qb.args, value)
+	return fmt.Sprintf("$%d", len(qb.args))
+}
+
+// where adds a condition. Every "?" in the fragment is replaced with the
+// placeholder of the corresponding value.
+func (qb *queryBuilder) where(fragment string, values ...interface{}) {
+	for _, value := range values {
+		fragment = strings.Replace(fragment, "?", qb.arg(value), 1)
+	}
+	qb.conditions = append(qb.conditions, fragment)
+}
+
+// whereClause renders the accumulated conditions, or an empty string if none.
+func (qb *queryBuilder) whereClause() string {
+	if len(qb.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(qb.conditions, " AND ")
+}
+
+// set adds a "column = value" assignment for an UPDATE statement. column must
+// be a trusted column name.
+func (qb *queryBuilder) set(column string, value interface{}) {
+	qb.assignments = append(qb.assignments, column+" = "+qb.arg(value))
+}
+
+// setClause renders the accumulated assignments, separated by commas.
+func (qb *queryBuilder) setClause() string {
+	return strings.Join(qb.assignments, ", ")
+}
@@ -0,0 +1,1633 @@
+// Package repositorytest is a conformance suite for implementations of the
+// repository stores. Every implementation runs the same tests so that they
+// stay interchangeable.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"thugcorp.io/nomado/models"
+	"thugcorp.io/nomado/money"
+	"thugcorp.io/nomado/repository"
+)
+
+// Stores are the stores under test. They must share one backing store, so
+// that houses can reference the agents and house types created through the
+// other stores.
+type Stores struct {
+	Houses     repository.HouseStore
+	Images     repository.HouseImageStore
+	Tags       repository.TagStore
+	Agents     repository.AgentStore
+	HouseTypes repository.HouseTypeStore
+	Gazetteer  repository.GazetteerStore
+}
+
+// Run runs the conformance suite. newStores is called once per test and
+// must return empty stores.
+func Run(t *testing.T, newStores func(t *testing.T) Stores) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s Stores)
+	}{
+		{"AgentsOrderedByName", testAgentsOrderedByName},
+		{"AgentRoundTrip", testAgentRoundTrip},
+		{"AgentNotFound", testAgentNotFound},
+		{"AgentImage", testAgentImage},
+		{"HouseTypesOrderedByName", testHouseTypesOrderedByName},
+		{"HouseTypeConflict", testHouseTypeConflict},
+		{"HouseTypeNotFound", testHouseTypeNotFound},
+		{"HouseTypeCounts", testHouseTypeCounts},
+		{"HouseRoundTrip", testHouseRoundTrip},
+		{"HouseUpdate", testHouseUpdate},
+		{"HousePatch", testHousePatch},
+		{"HouseVersions", testHouseVersions},
+		{"HouseNotFound", testHouseNotFound},
+		{"HouseRequiresAgentAndType", testHouseRequiresAgentAndType},
+		{"ValueLimits", testValueLimits},
+		{"ListHousesNewestFirst", testListHousesNewestFirst},
+		{"ListHousesFilters", testListHousesFilters},
+		{"HouseAttributes", testHouseAttributes},
+		{"ListHousesAttributeRanges", testListHousesAttributeRanges},
+		{"HouseLocation", testHouseLocation},
+		{"ListHousesNearby", testListHousesNearby},
+		{"TagsNormalised", testTagsNormalised},
+		{"ListTags", testListTags},
+		{"ListHousesSortAndPage", testListHousesSortAndPage},
+		{"ListHousesSortByName", testListHousesSortByName},
+		{"TopHouses", testTopHouses},
+		{"DeleteDetachesHouses", testDeleteDetachesHouses},
+		{"HouseTrash", testHouseTrash},
+		{"SearchHouses", testSearchHouses},
+		{"HouseImages", testHouseImages},
+		{"HouseImageOrder", testHouseImageOrder},
+		{"HouseImagesNotFound", testHouseImagesNotFound},
+		{"HouseImageRenditions", testHouseImageRenditions},
+		{"Geocode", testGeocode},
+		{"ReverseGeocode", testReverseGeocode},
+		{"ImportPlaces", testImportPlaces},
+		{"CanceledContext", testCanceledContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStores(t))
+		})
+	}
+}
+
+// fixture creates an agent and a house type for houses to reference.
+type fixture struct {
+	agent     models.Agent
+	houseType models.HouseType
+}
+
+func newFixture(t *testing.T, s Stores) fixture {
+	t.Helper()
+	ctx := t.Context()
+	f := fixture{
+		agent:     models.Agent{FirstName: "Jane", LastName: "Doe"},
+		houseType: models.HouseType{Name: "Villa"},
+	}
+	if err := s.Agents.CreateAgent(ctx, &f.agent); err != nil {
+		t.Fatalf("CreateAgent: %v", err)
+	}
+	if err := s.HouseTypes.CreateHouseType(ctx, &f.houseType); err != nil {
+		t.Fatalf("CreateHouseType: %v", err)
+	}
+	return f
+}
+
+func (f fixture) createHouse(t *testing.T, s Stores, name string, price money.Amount, tags ...string) models.House {
+	t.Helper()
+	ctx := t.Context()
+	house := models.House{
+		Name:        name,
+		Description: "A house called " + name,
+		HouseTypeID: f.houseType.ID,
+		AgentID:     f.agent.ID,
+		Price:       price,
+		Tags:        tags,
+	}
+	if err := s.Houses.CreateHouse(ctx, &house); err != nil {
+		t.Fatalf("CreateHouse(%s): %v", name, err)
+	}
+	return house
+}
+
+func houseNames(houses []models.HouseWithDetails) string {
+	var names []string
+	for _, house := range houses {
+		names = append(names, house.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func expectNotFound(t *testing.T, op string, err error) {
+	t.Helper()
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("%s: expected ErrNotFound, got %v", op, err)
+	}
+}
+
+func testAgentsOrderedByName(t *testing.T, s Stores) {
+	ctx := t.Context()
+	agents, err := s.Agents.GetAllAgents(ctx)
+	if err != nil || len(agents) != 0 {
+		t.Fatalf("GetAllAgents on empty store = %v, %v", agents, err)
+	}
+
+	for _, name := range [][2]string{{"Sam", "Young"}, {"Alex", "Moss"}, {"Sam", "Adams"}} {
+		if err := s.Agents.CreateAgent(ctx, &models.Agent{FirstName: name[0], LastName: name[1]}); err != nil {
+			t.Fatalf("CreateAgent: %v", err)
+		}
+	}
+
+	agents, err = s.Agents.GetAllAgents(ctx)
+	if err != nil {
+		t.Fatalf("GetAllAgents: %v", err)
+	}
+	var got []string
+	for _, agent := range agents {
+		got = append(got, agent.FirstName+" "+agent.LastName)
+	}
+	if want := "Alex Moss,Sam Adams,Sam Young"; strings.Join(got, ",") != want {
+		t.Errorf("agents = %v, want %s", got, want)
+	}
+}
+
+func testAgentRoundTrip(t *testing.T, s Stores) {
+	ctx := t.Context()
+	imageURL := "/images/jane.jpg"
+	agent := models.Agent{FirstName: "Jane", LastName: "Doe", ImageURL: &imageURL}
+	if err := s.Agents.CreateAgent(ctx, &agent); err != nil {
+		t.Fatalf("CreateAgent: %v", err)
+	}
+	if agent.ID == 0 {
+		t.Fatal("CreateAgent did not assign an ID")
+	}
+
+	got, err := s.Agents.GetAgentByID(ctx, agent.ID)
+	if err != nil {
+		t.Fatalf("GetAgentByID: %v", err)
+	}
+	if got.FirstName != "Jane" || got.LastName != "Doe" || got.ImageURL == nil || *got.ImageURL != imageURL {
+		t.Errorf("GetAgentByID = %+v", got)
+	}
+
+	agent.LastName = "Smith"
+	agent.ImageURL = nil
+	if err := s.Agents.UpdateAgent(ctx, &agent); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+	got, err = s.Agents.GetAgentByID(ctx, agent.ID)
+	if err != nil {
+		t.Fatalf("GetAgentByID: %v", err)
+	}
+	if got.LastName != "Smith" || got.ImageURL != nil {
+		t.Errorf("after update GetAgentByID = %+v", got)
+	}
+
+	if err := s.Agents.DeleteAgent(ctx, agent.ID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+	_, err = s.Agents.GetAgentByID(ctx, agent.ID)
+	expectNotFound(t, "GetAgentByID after delete", err)
+}
+
+func testAgentNotFound(t *testing.T, s Stores) {
+	ctx := t.Context()
+	_, err := s.Agents.GetAgentByID(ctx, 999)
+	expectNotFound(t, "GetAgentByID", err)
+	expectNotFound(t, "UpdateAgent", s.Agents.UpdateAgent(ctx, &models.Agent{ID: 999, FirstName: "A", LastName: "B"}))
+	expectNotFound(t, "DeleteAgent", s.Agents.DeleteAgent(ctx, 999))
+	_, err = s.Agents.SetAgentImage(ctx, 999, models.Renditions{Large: "/images/l.jpg"})
+	expectNotFound(t, "SetAgentImage", err)
+}
+
+func renditions(name string) models.Renditions {
+	return models.Renditions{
+		Thumbnail: "/images/" + name + "/thumbnail.jpg",
+		Medium:    "/images/" + name + "/medium.jpg",
+		Large:     "/images/" + name + "/large.jpg",
+	}
+}
+
+func testAgentImage(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	upload := renditions("jane")
+
+	agent, err := s.Agents.SetAgentImage(ctx, f.agent.ID, upload)
+	if err != nil || agent.ImageURL == nil || *agent.ImageURL != upload.Large ||
+		agent.ImageRenditions == nil || *agent.ImageRenditions != upload || agent.FirstName != f.agent.FirstName {
+		t.Fatalf("SetAgentImage = %+v, %v, want the large rendition as image", agent, err)
+	}
+	got, err := s.Agents.GetAgentByID(ctx, f.agent.ID)
+	if err != nil || got.ImageRenditions == nil || *got.ImageRenditions != upload {
+		t.Errorf("GetAgentByID renditions = %+v, %v", got.ImageRenditions, err)
+	}
+	house := f.createHouse(t, s, "Listed", 100)
+	details, err := s.Houses.GetHouseWithDetailsByID(ctx, house.ID)
+	if err != nil || details.Agent == nil || details.Agent.ImageRenditions == nil || *details.Agent.ImageRenditions != upload {
+		t.Errorf("GetHouseWithDetailsByID agent = %+v, %v", details.Agent, err)
+	}
+
+	// Renditions survive updates that keep the image, and no others
+	agent.LastName, agent.ImageRenditions = "Smith", nil
+	if err := s.Agents.UpdateAgent(ctx, agent); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+	if agent.ImageRenditions == nil || *agent.ImageRenditions != upload {
+		t.Errorf("UpdateAgent keeping the image: renditions = %+v", agent.ImageRenditions)
+	}
+	other := "https://example.com/jane.jpg"
+	agent.ImageURL = &other
+	if err := s.Agents.UpdateAgent(ctx, agent); err != nil {
+		t.Fatalf("UpdateAgent: %v", err)
+	}
+	got, err = s.Agents.GetAgentByID(ctx, f.agent.ID)
+	if err != nil || agent.ImageRenditions != nil || got.ImageRenditions != nil || *got.ImageURL != other {
+		t.Errorf("UpdateAgent replacing the image: renditions = %+v then %+v, %v", agent.ImageRenditions, got.ImageRenditions, err)
+	}
+}
+
+func testHouseTypesOrderedByName(t *testing.T, s Stores) {
+	ctx := t.Context()
+	for _, name := range []string{"Villa", "Apartment", "Cottage"} {
+		if err := s.HouseTypes.CreateHouseType(ctx, &models.HouseType{Name: name}); err != nil {
+			t.Fatalf("CreateHouseType: %v", err)
+		}
+	}
+
+	houseTypes, err := s.HouseTypes.GetAllHouseTypes(ctx)
+	if err != nil {
+		t.Fatalf("GetAllHouseTypes: %v", err)
+	}
+	var got []string
+	for _, houseType := range houseTypes {
+		got = append(got, houseType.Name)
+	}
+	if want := "Apartment,Cottage,Villa"; strings.Join(got, ",") != want {
+		t.Errorf("house types = %v, want %s", got, want)
+	}
+}
+
+func testHouseTypeConflict(t *testing.T, s Stores) {
+	ctx := t.Context()
+	villa := models.HouseType{Name: "Villa"}
+	cottage := models.HouseType{Name: "Cottage"}
+	for _, houseType := range []*models.HouseType{&villa, &cottage} {
+		if err := s.HouseTypes.CreateHouseType(ctx, houseType); err != nil {
+			t.Fatalf("CreateHouseType: %v", err)
+		}
+	}
+
+	if err := s.HouseTypes.CreateHouseType(ctx, &models.HouseType{Name: "Villa"}); !errors.Is(err, repository.ErrConflict) ||
+		repository.ErrorColumn(err) != "name" {
+		t.Errorf("CreateHouseType duplicate: expected ErrConflict on name, got %v", err)
+	}
+	cottage.Name = "Villa"
+	if err := s.HouseTypes.UpdateHouseType(ctx, &cottage); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("UpdateHouseType duplicate: expected ErrConflict, got %v", err)
+	}
+	// Keeping its own name is not a conflict
+	if err := s.HouseTypes.UpdateHouseType(ctx, &villa); err != nil {
+		t.Errorf("UpdateHouseType unchanged: %v", err)
+	}
+}
+
+func testHouseTypeNotFound(t *testing.T, s Stores) {
+	ctx := t.Context()
+	_, err := s.HouseTypes.GetHouseTypeByID(ctx, 999)
+	expectNotFound(t, "GetHouseTypeByID", err)
+	_, err = s.HouseTypes.GetHouseTypeWithCountByID(ctx, 999)
+	expectNotFound(t, "GetHouseTypeWithCountByID", err)
+	expectNotFound(t, "UpdateHouseType", s.HouseTypes.UpdateHouseType(ctx, &models.HouseType{ID: 999, Name: "Loft"}))
+	expectNotFound(t, "DeleteHouseType", s.HouseTypes.DeleteHouseType(ctx, 999))
+}
+
+func testHouseTypeCounts(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	empty := models.HouseType{Name: "Apartment"}
+	if err := s.HouseTypes.CreateHouseType(ctx, &empty); err != nil {
+		t.Fatalf("CreateHouseType: %v", err)
+	}
+	f.createHouse(t, s, "One", 100000)
+	f.createHouse(t, s, "Two", 200000)
+
+	counted, err := s.HouseTypes.GetAllHouseTypesWithCounts(ctx)
+	if err != nil {
+		t.Fatalf("GetAllHouseTypesWithCounts: %v", err)
+	}
+	if len(counted) != 2 || counted[0].Name != "Apartment" || counted[0].HouseCount != 0 ||
+		counted[1].Name != "Villa" || counted[1].HouseCount != 2 {
+		t.Errorf("GetAllHouseTypesWithCounts = %+v", counted)
+	}
+
+	one, err := s.HouseTypes.GetHouseTypeWithCountByID(ctx, f.houseType.ID)
+	if err != nil {
+		t.Fatalf("GetHouseTypeWithCountByID: %v", err)
+	}
+	if one.HouseCount != 2 {
+		t.Errorf("GetHouseTypeWithCountByID count = %d, want 2", one.HouseCount)
+	}
+}
+
+func testHouseRoundTrip(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	imageURL := "/images/house.jpg"
+	house := models.House{
+		Name:        "Sea View",
+		Description: "Close to the beach",
+		HouseTypeID: f.houseType.ID,
+		AgentID:     f.agent.ID,
+		Price:       250000*money.Unit + 50,
+		Currency:    "EUR",
+		Tags:        []string{"beach", "garden"},
+		ImageURL:    &imageURL,
+	}
+	if err := s.Houses.CreateHouse(ctx, &house); err != nil {
+		t.Fatalf("CreateHouse: %v", err)
+	}
+	if house.ID == 0 || house.CreatedAt.IsZero() || !house.UpdatedAt.Equal(house.CreatedAt) {
+		t.Fatalf("CreateHouse did not fill ID and timestamps: %+v", house)
+	}
+	if house.CreatedAt.Location() != time.UTC {
+		t.Errorf("CreatedAt is in %s, want UTC", house.CreatedAt.Location())
+	}
+
+	got, err := s.Houses.GetHouseByID(ctx, house.ID)
+	if err != nil {
+		t.Fatalf("GetHouseByID: %v", err)
+	}
+	if got.Name != house.Name || got.Description != house.Description || got.Price != house.Price ||
+		got.Currency != "EUR" || strings.Join(got.Tags, ",") != "beach,garden" || got.ImageURL == nil ||
+		*got.ImageURL != imageURL || !got.CreatedAt.Equal(house.CreatedAt) {
+		t.Errorf("GetHouseByID = %+v, want %+v", got, house)
+	}
+
+	details, err := s.Houses.GetHouseWithDetailsByID(ctx, house.ID)
+	if err != nil {
+		t.Fatalf("GetHouseWithDetailsByID: %v", err)
+	}
+	if details.Agent == nil || details.Agent.ID != f.agent.ID || details.Agent.LastName != "Doe" {
+		t.Errorf("agent = %+v, want %+v", details.Agent, f.agent)
+	}
+	if details.HouseType == nil || details.HouseType.Name != "Villa" {
+		t.Errorf("house type = %+v, want %+v", details.HouseType, f.houseType)
+	}
+
+	// Houses without tags read back as nil
+	bare := f.createHouse(t, s, "Bare", 1000)
+	got, err = s.Houses.GetHouseByID(ctx, bare.ID)
+	if err != nil {
+		t.Fatalf("GetHouseByID: %v", err)
+	}
+	if got.Tags != nil {
+		t.Errorf("tags = %#v, want nil", got.Tags)
+	}
+	if got.Currency != money.DefaultCurrency {
+		t.Errorf("currency = %q, want the default %q", got.Currency, money.DefaultCurrency)
+	}
+}
+
+func testHouseUpdate(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	house := f.createHouse(t, s, "Old Name", 1000, "old")
+
+	house.Name = "New Name"
+	house.Price = 2000
+	house.Tags = []string{"new", "fresh"}
+	if err := s.Houses.UpdateHouse(ctx, &house); err != nil {
+		t.Fatalf("UpdateHouse: %v", err)
+	}
+	if house.UpdatedAt.Before(house.CreatedAt) {
+		t.Error("UpdateHouse did not set UpdatedAt")
+	}
+
+	got, err := s.Houses.GetHouseByID(ctx, house.ID)
+	if err != nil {
+		t.Fatalf("GetHouseByID: %v", err)
+	}
+	if got.Name != "New Name" || got.Price != 2000 || strings.Join(got.Tags, ",") != "new,fresh" {
+		t.Errorf("after update GetHouseByID = %+v", got)
+	}
+	if !got.CreatedAt.Equal(house.CreatedAt) {
+		t.Errorf("CreatedAt changed from %s to %s", house.CreatedAt, got.CreatedAt)
+	}
+}
+
+func testHousePatch(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	house := f.createHouse(t, s, "Patched", 1000, "old")
+	imageURL := "/images/patched.jpg"
+	house.ImageURL = &imageURL
+	if err := s.Houses.UpdateHouse(ctx, &house); err != nil {
+		t.Fatalf("UpdateHouse: %v", err)
+	}
+
+	price := money.Amount(1500)
+	got, err := s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{Price: &price})
+	if err != nil {
+		t.Fatalf("PatchHouse(price): %v", err)
+	}
+	if got.Price != 1500 || got.Name != "Patched" || strings.Join(got.Tags, ",") != "old" ||
+		got.ImageURL == nil || *got.ImageURL != imageURL || got.AgentID != f.agent.ID {
+		t.Errorf("after patching the price PatchHouse = %+v", got)
+	}
+	if !got.CreatedAt.Equal(house.CreatedAt) || got.UpdatedAt.Before(house.UpdatedAt) {
+		t.Errorf("timestamps after patch: created %s -> %s, updated %s", house.CreatedAt, got.CreatedAt, got.UpdatedAt)
+	}
+
+	tags := []string{"new", "fresh"}
+	var noImage *string
+	if _, err := s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{Tags: &tags, ImageURL: &noImage}); err != nil {
+		t.Fatalf("PatchHouse(tags, image_url): %v", err)
+	}
+	stored, err := s.Houses.GetHouseByID(ctx, house.ID)
+	if err != nil {
+		t.Fatalf("GetHouseByID: %v", err)
+	}
+	if strings.Join(stored.Tags, ",") != "new,fresh" || stored.ImageURL != nil || stored.Price != 1500 {
+		t.Errorf("after patching tags and image GetHouseByID = %+v", stored)
+	}
+
+	unchanged, err := s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{})
+	if err != nil || unchanged.Name != "Patched" {
+		t.Errorf("empty PatchHouse = %+v, %v", unchanged, err)
+	}
+
+	_, err = s.Houses.PatchHouse(ctx, 999, 0, repository.HousePatch{Price: &price})
+	expectNotFound(t, "PatchHouse", err)
+	unknown := 999
+	if _, err := s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{AgentID: &unknown}); !errors.Is(err, repository.ErrForeignKey) {
+		t.Errorf("PatchHouse with an unknown agent: expected ErrForeignKey, got %v", err)
+	}
+	long := strings.Repeat("x", 256)
+	if _, err := s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{Name: &long}); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("PatchHouse with a long name: expected ErrValidation, got %v", err)
+	}
+}
+
+func testHouseVersions(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	house := f.createHouse(t, s, "Contested", 1000)
+	if house.Version != 1 {
+		t.Fatalf("CreateHouse: Version = %d, want 1", house.Version)
+	}
+
+	stale := house
+	house.Price = 1100
+	if err := s.Houses.UpdateHouse(ctx, &house); err != nil {
+		t.Fatalf("UpdateHouse: %v", err)
+	}
+	if house.Version != 2 {
+		t.Errorf("UpdateHouse: Version = %d, want 2", house.Version)
+	}
+
+	stale.Price = 900
+	if err := s.Houses.UpdateHouse(ctx, &stale); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("UpdateHouse with a stale version: expected ErrVersionMismatch, got %v", err)
+	}
+	price := money.Amount(950)
+	if _, err := s.Houses.PatchHouse(ctx, house.ID, 1, repository.HousePatch{Price: &price}); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("PatchHouse with a stale version: expected ErrVersionMismatch, got %v", err)
+	}
+	if _, err := s.Houses.PatchHouse(ctx, house.ID, 1, repository.HousePatch{}); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("empty PatchHouse with a stale version: expected ErrVersionMismatch, got %v", err)
+	}
+	if err := s.Houses.DeleteHouse(ctx, house.ID, 1); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("DeleteHouse with a stale version: expected ErrVersionMismatch, got %v", err)
+	}
+
+	patched, err := s.Houses.PatchHouse(ctx, house.ID, 2, repository.HousePatch{Price: &price})
+	if err != nil {
+		t.Fatalf("PatchHouse: %v", err)
+	}
+	if patched.Version != 3 || patched.Price != 950 {
+		t.Errorf("PatchHouse = version %d, price %v, want 3 and 950", patched.Version, patched.Price)
+	}
+	got, err := s.Houses.GetHouseByID(ctx, house.ID)
+	if err != nil || got.Version != 3 {
+		t.Errorf("GetHouseByID = %+v, %v, want version 3", got, err)
+	}
+
+	expectNotFound(t, "DeleteHouse with a version", s.Houses.DeleteHouse(ctx, 999, 3))
+	if err := s.Houses.DeleteHouse(ctx, house.ID, 3); err != nil {
+		t.Errorf("DeleteHouse with the current version: %v", err)
+	}
+}
+
+func testHouseNotFound(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	_, err := s.Houses.GetHouseByID(ctx, 999)
+	expectNotFound(t, "GetHouseByID", err)
+	_, err = s.Houses.GetHouseWithDetailsByID(ctx, 999)
+	expectNotFound(t, "GetHouseWithDetailsByID", err)
+	missing := models.House{ID: 999, Name: "Missing", Price: 1, HouseTypeID: f.houseType.ID, AgentID: f.agent.ID}
+	expectNotFound(t, "UpdateHouse", s.Houses.UpdateHouse(ctx, &missing))
+	expectNotFound(t, "DeleteHouse", s.Houses.DeleteHouse(ctx, 999, 0))
+}
+
+func testHouseRequiresAgentAndType(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	noAgent := models.House{Name: "No Agent", Price: 1, HouseTypeID: f.houseType.ID, AgentID: 999}
+	if err := s.Houses.CreateHouse(ctx, &noAgent); !errors.Is(err, repository.ErrForeignKey) {
+		t.Errorf("CreateHouse with an unknown agent: expected ErrForeignKey, got %v", err)
+	}
+	noType := models.House{Name: "No Type", Price: 1, HouseTypeID: 999, AgentID: f.agent.ID}
+	if err := s.Houses.CreateHouse(ctx, &noType); !errors.Is(err, repository.ErrForeignKey) {
+		t.Errorf("CreateHouse with an unknown house type: expected ErrForeignKey, got %v", err)
+	}
+
+	house := f.createHouse(t, s, "Moved", 1)
+	house.AgentID = 999
+	if err := s.Houses.UpdateHouse(ctx, &house); !errors.Is(err, repository.ErrForeignKey) {
+		t.Errorf("UpdateHouse with an unknown agent: expected ErrForeignKey, got %v", err)
+	}
+}
+
+func testValueLimits(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	long := strings.Repeat("x", 256)
+
+	house := models.House{Name: long, Price: 1, HouseTypeID: f.houseType.ID, AgentID: f.agent.ID}
+	if err := s.Houses.CreateHouse(ctx, &house); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("CreateHouse with a long name: expected ErrValidation, got %v", err)
+	}
+	house = models.House{Name: "Priceless", Price: 1e10 * money.Unit, HouseTypeID: f.houseType.ID, AgentID: f.agent.ID}
+	if err := s.Houses.CreateHouse(ctx, &house); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("CreateHouse with a huge price: expected ErrValidation, got %v", err)
+	}
+	house = models.House{Name: "Doubloons", Price: 1, Currency: "doubloon", HouseTypeID: f.houseType.ID, AgentID: f.agent.ID}
+	if err := s.Houses.CreateHouse(ctx, &house); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("CreateHouse with an invalid currency: expected ErrValidation, got %v", err)
+	}
+	for name, invalid := range map[string]func(*models.House){
+		"negative bedrooms": func(h *models.House) { h.Bedrooms = ptr(-1) },
+		"zero floor area":   func(h *models.House) { h.FloorArea = ptr(0.0) },
+		"huge lot size":     func(h *models.House) { h.LotSize = ptr(1e10) },
+		"no floors":         func(h *models.House) { h.Floors = ptr(0) },
+		"unknown area unit": func(h *models.House) { h.AreaUnit = "acre" },
+		"latitude only":     func(h *models.House) { h.Latitude = ptr(30.0) },
+		"latitude past 90":  func(h *models.House) { h.Latitude, h.Longitude = ptr(91.0), ptr(0.0) },
+		"long country":      func(h *models.House) { h.Address.Country = "USA" },
+	} {
+		house = models.House{Name: "Odd", Price: 1, HouseTypeID: f.houseType.ID, AgentID: f.agent.ID}
+		invalid(&house)
+		if err := s.Houses.CreateHouse(ctx, &house); !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("CreateHouse with %s: expected ErrValidation, got %v", name, err)
+		}
+	}
+	if err := s.Agents.CreateAgent(ctx, &models.Agent{FirstName: long, LastName: "Doe"}); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("CreateAgent with a long name: expected ErrValidation, got %v", err)
+	}
+	if err := s.HouseTypes.CreateHouseType(ctx, &models.HouseType{Name: long}); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("CreateHouseType with a long name: expected ErrValidation, got %v", err)
+	}
+}
+
+func testListHousesNewestFirst(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	houses, total, err := s.Houses.ListHouses(ctx, repository.HouseFilter{})
+	if err != nil || len(houses) != 0 || total != 0 {
+		t.Fatalf("ListHouses on empty store = %v, %d, %v", houses, total, err)
+	}
+
+	for _, name := range []string{"First", "Second", "Third"} {
+		f.createHouse(t, s, name, 1000)
+	}
+
+	houses, total, err = s.Houses.ListHouses(ctx, repository.HouseFilter{})
+	if err != nil {
+		t.Fatalf("ListHouses: %v", err)
+	}
+	if got := houseNames(houses); got != "Third,Second,First" || total != 3 {
+		t.Errorf("ListHouses = %s (total %d), want Third,Second,First (total 3)", got, total)
+	}
+	if houses[0].Agent == nil || houses[0].HouseType == nil {
+		t.Errorf("ListHouses did not load agent and house type: %+v", houses[0])
+	}
+}
+
+func testListHousesFilters(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	other := fixture{
+		agent:     models.Agent{FirstName: "John", LastName: "Roe"},
+		houseType: models.HouseType{Name: "Cottage"},
+	}
+	if err := s.Agents.CreateAgent(ctx, &other.agent); err != nil {
+		t.Fatalf("CreateAgent: %v", err)
+	}
+	if err := s.HouseTypes.CreateHouseType(ctx, &other.houseType); err != nil {
+		t.Fatalf("CreateHouseType: %v", err)
+	}
+
+	f.createHouse(t, s, "Cheap", 100, "garden")
+	f.createHouse(t, s, "Middle", 500, "garden", "pool")
+	other.createHouse(t, s, "Dear", 900, "pool")
+	yen := models.House{Name: "Yen", Price: 600, Currency: "JPY", HouseTypeID: f.houseType.ID, AgentID: f.agent.ID}
+	if err := s.Houses.CreateHouse(ctx, &yen); err != nil {
+		t.Fatalf("CreateHouse: %v", err)
+	}
+
+	minPrice, maxPrice := money.Amount(200), money.Amount(900)
+	otherAgent, otherType := other.agent.ID, other.houseType.ID
+	tests := []struct {
+		name   string
+		filter repository.HouseFilter
+		want   string
+	}{
+		{"price range", repository.HouseFilter{MinPrice: &minPrice, MaxPrice: &maxPrice, Currency: "USD"}, "Middle,Dear"},
+		{"price range in another currency", repository.HouseFilter{MinPrice: &minPrice, MaxPrice: &maxPrice, Currency: "JPY"}, "Yen"},
+		{"agent", repository.HouseFilter{AgentID: &otherAgent}, "Dear"},
+		{"house type", repository.HouseFilter{HouseTypeID: &otherType}, "Dear"},
+		{"one tag", repository.HouseFilter{Tags: []string{"garden"}}, "Cheap,Middle"},
+		{"all tags", repository.HouseFilter{Tags: []string{"garden", "pool"}}, "Middle"},
+		{"unknown tag", repository.HouseFilter{Tags: []string{"garage"}}, ""},
+		{"any tag", repository.HouseFilter{Tags: []string{"pool", "garage"}, AnyTag: true}, "Middle,Dear"},
+		{"any unknown tag", repository.HouseFilter{Tags: []string{"garage"}, AnyTag: true}, ""},
+	}
+	for _, tt := range tests {
+		tt.filter.SortField = "price"
+		houses, total, err := s.Houses.ListHouses(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ListHouses: %v", tt.name, err)
+		}
+		if got := houseNames(houses); got != tt.want || total != len(houses) {
+			t.Errorf("%s: ListHouses = %s (total %d), want %s", tt.name, got, total, tt.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testHouseAttributes(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	house := models.House{
+		Name:          "Farmhouse",
+		Price:         1000,
+		HouseTypeID:   f.houseType.ID,
+		AgentID:       f.agent.ID,
+		Bedrooms:      ptr(4),
+		Bathrooms:     ptr(2.5),
+		FloorArea:     ptr(2150.75),
+		LotSize:       ptr(43560.0),
+		AreaUnit:      models.AreaUnitSquareFeet,
+		YearBuilt:     ptr(1908),
+		ParkingSpaces: ptr(2),
+		Floors:        ptr(2),
+	}
+	if err := s.Houses.CreateHouse(ctx, &house); err != nil {
+		t.Fatalf("CreateHouse: %v", err)
+	}
+	got, err := s.Houses.GetHouseByID(ctx, house.ID)
+	if err != nil {
+		t.Fatalf("GetHouseByID: %v", err)
+	}
+	if *got.Bedrooms != 4 || *got.Bathrooms != 2.5 || *got.FloorArea != 2150.75 || *got.LotSize != 43560 ||
+		got.AreaUnit != models.AreaUnitSquareFeet || *got.YearBuilt != 1908 || *got.ParkingSpaces != 2 || *got.Floors != 2 {
+		t.Errorf("GetHouseByID attributes = %+v", got)
+	}
+
+	// Clearing an attribute makes it unknown again
+	var unknown *int
+	updated, err := s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{Bedrooms: &unknown})
+	if err != nil {
+		t.Fatalf("PatchHouse: %v", err)
+	}
+	if updated.Bedrooms != nil || updated.Bathrooms == nil || *updated.Bathrooms != 2.5 {
+		t.Errorf("PatchHouse cleared bedrooms = %v, bathrooms = %v", updated.Bedrooms, updated.Bathrooms)
+	}
+
+	// The upper limits are the precision of the columns, after rounding
+	for _, tt := range []struct {
+		name  string
+		patch repository.HousePatch
+		valid bool
+	}{
+		{"largest bathrooms", repository.HousePatch{Bathrooms: ptr(ptr(99.9))}, true},
+		{"bathrooms rounding to 100", repository.HousePatch{Bathrooms: ptr(ptr(99.96))}, false},
+		{"largest floor area", repository.HousePatch{FloorArea: ptr(ptr(99999999.99))}, true},
+		{"floor area rounding to 1e8", repository.HousePatch{FloorArea: ptr(ptr(99999999.996))}, false},
+		{"largest lot size", repository.HousePatch{LotSize: ptr(ptr(9999999999.99))}, true},
+		{"lot size of 1e10", repository.HousePatch{LotSize: ptr(ptr(1e10))}, false},
+	} {
+		_, err := s.Houses.PatchHouse(ctx, house.ID, 0, tt.patch)
+		if tt.valid && err != nil || !tt.valid && !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("%s: PatchHouse error = %v, want valid = %t", tt.name, err, tt.valid)
+		}
+	}
+
+	plain := f.createHouse(t, s, "Plain", 1000)
+	got, err = s.Houses.GetHouseByID(ctx, plain.ID)
+	if err != nil {
+		t.Fatalf("GetHouseByID: %v", err)
+	}
+	if got.Bedrooms != nil || got.FloorArea != nil || got.AreaUnit != models.AreaUnitSquareMetres {
+		t.Errorf("house without attributes = bedrooms %v, floor area %v, area unit %q", got.Bedrooms, got.FloorArea, got.AreaUnit)
+	}
+}
+
+func testListHousesAttributeRanges(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	create := func(name string, price money.Amount, bedrooms *int, floorArea float64, unit string) {
+		t.Helper()
+		house := models.House{
+			Name: name, Price: price, HouseTypeID: f.houseType.ID, AgentID: f.agent.ID,
+			Bedrooms: bedrooms, FloorArea: &floorArea, AreaUnit: unit,
+		}
+		if err := s.Houses.CreateHouse(ctx, &house); err != nil {
+			t.Fatalf("CreateHouse(%s): %v", name, err)
+		}
+	}
+	create("Studio", 100, ptr(0), 30, models.AreaUnitSquareMetres)
+	create("Flat", 300, ptr(2), 80, models.AreaUnitSquareMetres)
+	create("Bungalow", 400, ptr(3), 1200, models.AreaUnitSquareFeet) // 111.48 sqm
+	create("Mansion", 900, ptr(6), 600, models.AreaUnitSquareMetres)
+	create("Mystery", 200, nil, 100, models.AreaUnitSquareMetres)
+
+	three, maxPrice := 3, money.Amount(500)
+	hundred, twoHundred := 100.0, 200.0
+	tests := []struct {
+		name   string
+		filter repository.HouseFilter
+		want   string
+	}{
+		{"min bedrooms under a price", repository.HouseFilter{Bedrooms: repository.Range[int]{Min: &three}, MaxPrice: &maxPrice}, "Bungalow"},
+		{"max bedrooms skips unknown", repository.HouseFilter{Bedrooms: repository.Range[int]{Max: &three}}, "Studio,Flat,Bungalow"},
+		{"exact bedrooms", repository.HouseFilter{Bedrooms: repository.Range[int]{Min: &three, Max: &three}}, "Bungalow"},
+		{"floor area in square metres", repository.HouseFilter{FloorArea: repository.Range[float64]{Min: &hundred, Max: &twoHundred}}, "Mystery,Bungalow"},
+	}
+	for _, tt := range tests {
+		tt.filter.SortField = "price"
+		houses, total, err := s.Houses.ListHouses(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ListHouses: %v", tt.name, err)
+		}
+		if got := houseNames(houses); got != tt.want || total != len(houses) {
+			t.Errorf("%s: ListHouses = %s (total %d), want %s", tt.name, got, total, tt.want)
+		}
+	}
+}
+
+func testHouseLocation(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	address := models.Address{Street: "200 Congress Ave", City: "Austin", Region: "TX", PostalCode: "78701", Country: "US"}
+	house := models.House{
+		Name: "Downtown", Price: 1000, HouseTypeID: f.houseType.ID, AgentID: f.agent.ID,
+		Address: address, Latitude: ptr(30.2651), Longitude: ptr(-97.7436),
+	}
+	if err := s.Houses.CreateHouse(ctx, &house); err != nil {
+		t.Fatalf("CreateHouse: %v", err)
+	}
+	got, err := s.Houses.GetHouseByID(ctx, house.ID)
+	if err != nil {
+		t.Fatalf("GetHouseByID: %v", err)
+	}
+	if got.Address != address || got.Latitude == nil || *got.Latitude != 30.2651 || *got.Longitude != -97.7436 {
+		t.Errorf("GetHouseByID location = %+v, %v, %v", got.Address, got.Latitude, got.Longitude)
+	}
+
+	// Address parts are patched one by one
+	var noCoordinates *float64
+	moved := address
+	moved.City = "Round Rock"
+	patched, err := s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{
+		City: &moved.City, Latitude: &noCoordinates, Longitude: &noCoordinates,
+	})
+	if err != nil {
+		t.Fatalf("PatchHouse: %v", err)
+	}
+	if patched.Address != moved || patched.Latitude != nil || patched.Longitude != nil {
+		t.Errorf("PatchHouse location = %+v, %v, %v", patched.Address, patched.Latitude, patched.Longitude)
+	}
+}
+
+func testListHousesNearby(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	create := func(name string, lat, lng float64) {
+		t.Helper()
+		house := models.House{
+			Name: name, Price: 1000, HouseTypeID: f.houseType.ID, AgentID: f.agent.ID,
+			Latitude: &lat, Longitude: &lng,
+		}
+		if err := s.Houses.CreateHouse(ctx, &house); err != nil {
+			t.Fatalf("CreateHouse(%s): %v", name, err)
+		}
+	}
+	create("Capitol", 30.2747, -97.7404)
+	create("Zilker", 30.2670, -97.7729)     // 3.2 km from the Capitol
+	create("Round Rock", 30.5083, -97.6789) // 26.6 km
+	create("Houston", 29.7604, -95.3698)    // 235 km
+	create("Fiji", -17.7134, 178.0650)
+	create("Samoa", -13.7590, -172.1046)
+	f.createHouse(t, s, "Nowhere", 1000)
+
+	capitol := repository.Point{Lat: 30.2747, Lng: -97.7404}
+	tests := []struct {
+		name   string
+		filter repository.HouseFilter
+		want   string
+	}{
+		{"radius", repository.HouseFilter{Near: &capitol, RadiusKm: 30, SortField: "distance"}, "Capitol,Zilker,Round Rock"},
+		{"radius farthest first", repository.HouseFilter{Near: &capitol, RadiusKm: 30, SortField: "distance", SortDesc: true}, "Round Rock,Zilker,Capitol"},
+		{"small radius", repository.HouseFilter{Near: &capitol, RadiusKm: 3, SortField: "distance"}, "Capitol"},
+		{"no radius", repository.HouseFilter{Near: &capitol, SortField: "distance"}, "Capitol,Zilker,Round Rock,Houston,Samoa,Fiji"},
+		{"bounding box", repository.HouseFilter{BBox: &repository.BoundingBox{West: -98, South: 30, East: -97, North: 31}, SortField: "name"}, "Capitol,Round Rock,Zilker"},
+		{"across the antimeridian", repository.HouseFilter{BBox: &repository.BoundingBox{West: 170, South: -20, East: -170, North: 0}, SortField: "name"}, "Fiji,Samoa"},
+		{"radius within a box", repository.HouseFilter{Near: &capitol, RadiusKm: 300, BBox: &repository.BoundingBox{West: -97.5, South: 29, East: -95, North: 31}, SortField: "distance"}, "Houston"},
+	}
+	for _, tt := range tests {
+		houses, total, err := s.Houses.ListHouses(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ListHouses: %v", tt.name, err)
+		}
+		if got := houseNames(houses); got != tt.want || total != len(houses) {
+			t.Errorf("%s: ListHouses = %s (total %d), want %s", tt.name, got, total, tt.want)
+		}
+	}
+
+	houses, _, err := s.Houses.ListHouses(ctx, repository.HouseFilter{Near: &capitol, RadiusKm: 5, SortField: "distance"})
+	if err != nil {
+		t.Fatalf("ListHouses: %v", err)
+	}
+	if len(houses) != 2 || houses[0].DistanceKm == nil || *houses[0].DistanceKm > 0.001 ||
+		houses[1].DistanceKm == nil || *houses[1].DistanceKm < 3 || *houses[1].DistanceKm > 3.5 {
+		t.Errorf("ListHouses distances = %+v", houses)
+	}
+	houses, _, err = s.Houses.ListHouses(ctx, repository.HouseFilter{})
+	if err != nil {
+		t.Fatalf("ListHouses: %v", err)
+	}
+	for _, house := range houses {
+		if house.DistanceKm != nil {
+			t.Errorf("ListHouses without near: %s has a distance of %v", house.Name, *house.DistanceKm)
+		}
+	}
+}
+
+func testTagsNormalised(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	house := f.createHouse(t, s, "Tagged", 1000, "Ocean Views", " ocean-views ", "Pool!", "--")
+	if got := strings.Join(house.Tags, ","); got != "ocean-views,pool" {
+		t.Errorf("CreateHouse: Tags = %q, want ocean-views,pool", got)
+	}
+	stored, err := s.Houses.GetHouseByID(ctx, house.ID)
+	if err != nil {
+		t.Fatalf("GetHouseByID: %v", err)
+	}
+	if got := strings.Join(stored.Tags, ","); got != "ocean-views,pool" {
+		t.Errorf("GetHouseByID: Tags = %q, want ocean-views,pool", got)
+	}
+
+	house.Tags = []string{"Sea, Sand", "GARDEN"}
+	if err := s.Houses.UpdateHouse(ctx, &house); err != nil {
+		t.Fatalf("UpdateHouse: %v", err)
+	}
+	if got := strings.Join(house.Tags, ","); got != "sea-sand,garden" {
+		t.Errorf("UpdateHouse: Tags = %q, want sea-sand,garden", got)
+	}
+
+	tags := []string{"Roof Terrace"}
+	patched, err := s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{Tags: &tags})
+	if err != nil {
+		t.Fatalf("PatchHouse: %v", err)
+	}
+	if got := strings.Join(patched.Tags, ","); got != "roof-terrace" {
+		t.Errorf("PatchHouse: Tags = %q, want roof-terrace", got)
+	}
+
+	var none []string
+	patched, err = s.Houses.PatchHouse(ctx, house.ID, 0, repository.HousePatch{Tags: &none})
+	if err != nil {
+		t.Fatalf("PatchHouse: %v", err)
+	}
+	if patched.Tags != nil {
+		t.Errorf("PatchHouse without tags: Tags = %q, want nil", patched.Tags)
+	}
+}
+
+func testListTags(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+
+	tags, err := s.Tags.ListTags(ctx)
+	if err != nil || len(tags) != 0 {
+		t.Fatalf("ListTags on an empty store = %+v, %v", tags, err)
+	}
+
+	f.createHouse(t, s, "One", 1, "garden", "pool")
+	f.createHouse(t, s, "Two", 2, "garden", "city-center")
+	f.createHouse(t, s, "Three", 3, "garden", "pool", "beach")
+	f.createHouse(t, s, "Four", 4)
+
+	tags, err = s.Tags.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	want := []models.TagCount{
+		{Tag: "garden", HouseCount: 3},
+		{Tag: "pool", HouseCount: 2},
+		{Tag: "beach", HouseCount: 1},
+		{Tag: "city-center", HouseCount: 1},
+	}
+	if !slices.Equal(tags, want) {
+		t.Errorf("ListTags = %+v, want %+v", tags, want)
+	}
+}
+
+func testListHousesSortAndPage(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	f.createHouse(t, s, "Bravo", 300)
+	f.createHouse(t, s, "Alpha", 200)
+	f.createHouse(t, s, "Delta", 400)
+	f.createHouse(t, s, "Charlie", 100)
+
+	tests := []struct {
+		filter repository.HouseFilter
+		want   string
+	}{
+		{repository.HouseFilter{SortField: "price"}, "Charlie,Alpha,Bravo,Delta"},
+		{repository.HouseFilter{SortField: "price", SortDesc: true}, "Delta,Bravo,Alpha,Charlie"},
+		{repository.HouseFilter{SortField: "name"}, "Alpha,Bravo,Charlie,Delta"},
+		{repository.HouseFilter{SortField: "created_at"}, "Bravo,Alpha,Delta,Charlie"},
+		{repository.HouseFilter{SortField: "name", Limit: 2}, "Alpha,Bravo"},
+		{repository.HouseFilter{SortField: "name", Limit: 2, Offset: 2}, "Charlie,Delta"},
+		{repository.HouseFilter{SortField: "name", Limit: 2, Offset: 4}, ""},
+	}
+	for _, tt := range tests {
+		houses, total, err := s.Houses.ListHouses(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("ListHouses(%+v): %v", tt.filter, err)
+		}
+		if got := houseNames(houses); got != tt.want || total != 4 {
+			t.Errorf("ListHouses(%+v) = %s (total %d), want %s (total 4)", tt.filter, got, total, tt.want)
+		}
+	}
+}
+
+func testListHousesSortByName(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	f.createHouse(t, s, "delta", 100)
+	f.createHouse(t, s, "Éclair", 100)
+	f.createHouse(t, s, "Bravo!", 100)
+	f.createHouse(t, s, "Bravo", 100)
+	f.createHouse(t, s, "alpha", 100)
+	f.createHouse(t, s, "Alpha", 100)
+
+	// The case of ASCII letters is ignored, names that differ only in case keep
+	// their id order, and the rest compares byte by byte
+	for _, tt := range []struct {
+		desc bool
+		want string
+	}{
+		{false, "alpha,Alpha,Bravo,Bravo!,delta,Éclair"},
+		{true, "Éclair,delta,Bravo!,Bravo,Alpha,alpha"},
+	} {
+		houses, _, err := s.Houses.ListHouses(ctx, repository.HouseFilter{SortField: "name", SortDesc: tt.desc})
+		if err != nil {
+			t.Fatalf("ListHouses: %v", err)
+		}
+		if got := houseNames(houses); got != tt.want {
+			t.Errorf("ListHouses by name (desc %t) = %s, want %s", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func testTopHouses(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	f.createHouse(t, s, "Low", 100)
+	f.createHouse(t, s, "High", 300)
+	f.createHouse(t, s, "Mid", 200)
+	f.createHouse(t, s, "Also Low", 100)
+
+	yen := models.House{Name: "Yen", Price: 10_000_000, Currency: "JPY", HouseTypeID: f.houseType.ID, AgentID: f.agent.ID}
+	if err := s.Houses.CreateHouse(ctx, &yen); err != nil {
+		t.Fatalf("CreateHouse: %v", err)
+	}
+
+	// Only prices in the same currency are ranked together
+	houses, err := s.Houses.GetTopHousesWithDetails(ctx, "USD", 2)
+	if err != nil {
+		t.Fatalf("GetTopHousesWithDetails: %v", err)
+	}
+	if got := houseNames(houses); got != "High,Mid" {
+		t.Errorf("GetTopHousesWithDetails = %s, want High,Mid", got)
+	}
+	if houses[0].Agent == nil || houses[0].HouseType == nil {
+		t.Errorf("GetTopHousesWithDetails did not load agent and house type: %+v", houses[0])
+	}
+	// Equal prices put the newest house first
+	if houses, err := s.Houses.GetTopHousesWithDetails(ctx, "USD", 4); err != nil || houseNames(houses) != "High,Mid,Also Low,Low" {
+		t.Errorf("GetTopHousesWithDetails = %s, %v, want High,Mid,Also Low,Low", houseNames(houses), err)
+	}
+	if houses, err := s.Houses.GetTopHousesWithDetails(ctx, "JPY", 2); err != nil || houseNames(houses) != "Yen" {
+		t.Errorf("GetTopHousesWithDetails(JPY) = %s, %v, want Yen", houseNames(houses), err)
+	}
+}
+
+func testDeleteDetachesHouses(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	house := f.createHouse(t, s, "Orphan", 1000)
+
+	if err := s.Agents.DeleteAgent(ctx, f.agent.ID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+	if err := s.HouseTypes.DeleteHouseType(ctx, f.houseType.ID); err != nil {
+		t.Fatalf("DeleteHouseType: %v", err)
+	}
+
+	got, err := s.Houses.GetHouseWithDetailsByID(ctx, house.ID)
+	if err != nil {
+		t.Fatalf("GetHouseWithDetailsByID: %v", err)
+	}
+	if got.AgentID != 0 || got.Agent != nil || got.HouseTypeID != 0 || got.HouseType != nil {
+		t.Errorf("house still references its agent or house type: %+v", got)
+	}
+}
+
+func testSearchHouses(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	f.createHouse(t, s, "Garden Cottage", 100, "quiet")
+	f.createHouse(t, s, "City Loft", 200, "garden")
+	beach := models.House{
+		Name:        "Beach House",
+		Description: "Large <b>garden</b> facing the sea",
+		HouseTypeID: f.houseType.ID,
+		AgentID:     f.agent.ID,
+		Price:       300,
+	}
+	if err := s.Houses.CreateHouse(ctx, &beach); err != nil {
+		t.Fatalf("CreateHouse: %v", err)
+	}
+
+	results, total, err := s.Houses.SearchHouses(ctx, "garden", repository.HouseFilter{})
+	if err != nil {
+		t.Fatalf("SearchHouses: %v", err)
+	}
+	var names []string
+	for _, result := range results {
+		names = append(names, result.House.Name)
+	}
+	// Names weigh more than tags, which weigh more than descriptions
+	if got := strings.Join(names, ","); got != "Garden Cottage,City Loft,Beach House" || total != 3 {
+		t.Errorf("SearchHouses(garden) = %s (total %d)", got, total)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Rank > results[i-1].Rank {
+			t.Errorf("results not ordered by rank: %v", results)
+		}
+	}
+	if last := results[len(results)-1]; !strings.Contains(last.Snippet, "<mark>garden</mark>") {
+		t.Errorf("snippet %q does not mark the match", last.Snippet)
+	}
+	// Markup in the description is escaped, so only the marks are HTML
+	if last := results[len(results)-1]; strings.Contains(last.Snippet, "<b>") || !strings.Contains(last.Snippet, "&lt;b&gt;") {
+		t.Errorf("snippet %q does not escape the description", last.Snippet)
+	}
+
+	tests := []struct {
+		search string
+		filter repository.HouseFilter
+		want   string
+	}{
+		{"garden -loft", repository.HouseFilter{SortField: "price"}, "Garden Cottage,Beach House"},
+		{"loft or sea", repository.HouseFilter{SortField: "price"}, "City Loft,Beach House"},
+		{`"facing the sea"`, repository.HouseFilter{}, "Beach House"},
+		{"garden", repository.HouseFilter{SortField: "price", SortDesc: true, Limit: 1}, "Beach House"},
+		{"castle", repository.HouseFilter{}, ""},
+	}
+	for _, tt := range tests {
+		results, _, err := s.Houses.SearchHouses(ctx, tt.search, tt.filter)
+		if err != nil {
+			t.Fatalf("SearchHouses(%q): %v", tt.search, err)
+		}
+		var names []string
+		for _, result := range results {
+			names = append(names, result.House.Name)
+		}
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("SearchHouses(%q) = %s, want %s", tt.search, got, tt.want)
+		}
+	}
+}
+
+func testHouseTrash(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	first := f.createHouse(t, s, "First", 100, "garden")
+	second := f.createHouse(t, s, "Second", 200)
+	f.createHouse(t, s, "Kept", 300)
+	upload := renditions("second")
+	image := models.HouseImage{HouseID: second.ID, URL: upload.Large, Renditions: &upload}
+	if err := s.Images.AddHouseImage(ctx, &image); err != nil {
+		t.Fatalf("AddHouseImage: %v", err)
+	}
+	addImage(t, s, second.ID, "/linked.jpg", false)
+
+	if err := s.Houses.DeleteHouse(ctx, first.ID, first.Version); err != nil {
+		t.Fatalf("DeleteHouse(First): %v", err)
+	}
+	if err := s.Houses.DeleteHouse(ctx, second.ID, 0); err != nil {
+		t.Fatalf("DeleteHouse(Second): %v", err)
+	}
+
+	// Houses in the trash are gone for every other method
+	_, err := s.Houses.GetHouseByID(ctx, first.ID)
+	expectNotFound(t, "GetHouseByID in the trash", err)
+	_, err = s.Houses.GetHouseWithDetailsByID(ctx, first.ID)
+	expectNotFound(t, "GetHouseWithDetailsByID in the trash", err)
+	_, err = s.Houses.PatchHouse(ctx, first.ID, 0, repository.HousePatch{Name: &first.Name})
+	expectNotFound(t, "PatchHouse in the trash", err)
+	expectNotFound(t, "UpdateHouse in the trash", s.Houses.UpdateHouse(ctx, &first))
+	expectNotFound(t, "DeleteHouse in the trash", s.Houses.DeleteHouse(ctx, first.ID, 0))
+
+	houses, total, err := s.Houses.ListHouses(ctx, repository.HouseFilter{})
+	if err != nil || total != 1 || houseNames(houses) != "Kept" {
+		t.Errorf("ListHouses = %q (total %d), %v, want Kept", houseNames(houses), total, err)
+	}
+	top, err := s.Houses.GetTopHousesWithDetails(ctx, "USD", 10)
+	if err != nil || houseNames(top) != "Kept" {
+		t.Errorf("GetTopHousesWithDetails = %q, %v, want Kept", houseNames(top), err)
+	}
+	if tags, err := s.Tags.ListTags(ctx); err != nil || len(tags) != 0 {
+		t.Errorf("ListTags = %+v, %v, want no tags", tags, err)
+	}
+	if counted, err := s.HouseTypes.GetHouseTypeWithCountByID(ctx, f.houseType.ID); err != nil || counted.HouseCount != 1 {
+		t.Errorf("GetHouseTypeWithCountByID = %+v, %v, want 1 house", counted, err)
+	}
+
+	deleted, total, err := s.Houses.ListDeletedHouses(ctx, 10, 0)
+	if err != nil || total != 2 || houseNames(deleted) != "Second,First" {
+		t.Fatalf("ListDeletedHouses = %q (total %d), %v, want Second,First", houseNames(deleted), total, err)
+	}
+	if deleted[1].DeletedAt == nil || deleted[1].Agent == nil || deleted[1].Version != 2 {
+		t.Errorf("deleted house = %+v, want deleted_at, agent and version 2", deleted[1])
+	}
+	deleted, total, err = s.Houses.ListDeletedHouses(ctx, 1, 1)
+	if err != nil || total != 2 || houseNames(deleted) != "First" {
+		t.Errorf("ListDeletedHouses page 2 = %q (total %d), %v, want First", houseNames(deleted), total, err)
+	}
+
+	restored, err := s.Houses.RestoreHouse(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("RestoreHouse: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != 3 || strings.Join(restored.Tags, ",") != "garden" {
+		t.Errorf("RestoreHouse = %+v, want a live house at version 3", restored)
+	}
+	if _, err := s.Houses.GetHouseByID(ctx, first.ID); err != nil {
+		t.Errorf("GetHouseByID after restoring: %v", err)
+	}
+	_, err = s.Houses.RestoreHouse(ctx, first.ID)
+	expectNotFound(t, "RestoreHouse of a live house", err)
+	_, err = s.Houses.RestoreHouse(ctx, 999)
+	expectNotFound(t, "RestoreHouse of a missing house", err)
+
+	if purged, uploads, err := s.Houses.PurgeDeletedHouses(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 || len(uploads) != 0 {
+		t.Errorf("PurgeDeletedHouses before the deletion = %d, %v, %v, want 0", purged, uploads, err)
+	}
+	// The uploaded photos are returned so that their files can be deleted
+	if purged, uploads, err := s.Houses.PurgeDeletedHouses(ctx, time.Now().Add(time.Hour)); err != nil || purged != 1 ||
+		len(uploads) != 1 || uploads[0] != upload {
+		t.Errorf("PurgeDeletedHouses after the deletion = %d, %v, %v, want 1 with the upload of Second", purged, uploads, err)
+	}
+	_, err = s.Houses.RestoreHouse(ctx, second.ID)
+	expectNotFound(t, "RestoreHouse of a purged house", err)
+	if deleted, total, err := s.Houses.ListDeletedHouses(ctx, 10, 0); err != nil || total != 0 || len(deleted) != 0 {
+		t.Errorf("ListDeletedHouses after the purge = %q (total %d), %v", houseNames(deleted), total, err)
+	}
+}
+
+func addImage(t *testing.T, s Stores, houseID int, url string, cover bool) models.HouseImage {
+	t.Helper()
+	image := models.HouseImage{HouseID: houseID, URL: url, AltText: "Photo " + url, IsCover: cover}
+	if err := s.Images.AddHouseImage(t.Context(), &image); err != nil {
+		t.Fatalf("AddHouseImage(%s): %v", url, err)
+	}
+	return image
+}
+
+// gallery describes images as url:position, with a * after the cover.
+func gallery(images []models.HouseImage) string {
+	var parts []string
+	for _, image := range images {
+		part := image.URL + ":" + strconv.Itoa(image.Position)
+		if image.IsCover {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+func testHouseImages(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	house := f.createHouse(t, s, "Gallery", 100)
+
+	images, err := s.Images.ListHouseImages(ctx, house.ID)
+	if err != nil || images == nil || len(images) != 0 {
+		t.Fatalf("ListHouseImages of a new house = %#v, %v, want an empty gallery", images, err)
+	}
+
+	// The first image is the cover until another one claims it
+	front := addImage(t, s, house.ID, "/a.jpg", false)
+	if front.ID == 0 || front.Position != 0 || !front.IsCover || front.CreatedAt.IsZero() {
+		t.Errorf("first image = %+v, want ID, position 0, cover and created_at", front)
+	}
+	addImage(t, s, house.ID, "/b.jpg", false)
+	addImage(t, s, house.ID, "/c.jpg", true)
+	images, err = s.Images.ListHouseImages(ctx, house.ID)
+	if got, want := gallery(images), "/a.jpg:0,/b.jpg:1,/c.jpg:2*"; err != nil || got != want {
+		t.Errorf("ListHouseImages = %s, %v, want %s", got, err, want)
+	}
+
+	// Every change is a new version of the house
+	got, err := s.Houses.GetHouseByID(ctx, house.ID)
+	if err != nil || got.Version != house.Version+3 {
+		t.Errorf("house version after 3 images = %d, %v, want %d", got.Version, err, house.Version+3)
+	}
+
+	caption := "The front garden"
+	updated, err := s.Images.UpdateHouseImage(ctx, house.ID, front.ID, repository.HouseImagePatch{Caption: &caption})
+	if err != nil || updated.Caption != caption || updated.URL != "/a.jpg" || updated.AltText != "Photo /a.jpg" {
+		t.Errorf("UpdateHouseImage = %+v, %v, want the caption changed only", updated, err)
+	}
+	unchanged, err := s.Images.UpdateHouseImage(ctx, house.ID, front.ID, repository.HouseImagePatch{})
+	if err != nil || unchanged.Caption != caption {
+		t.Errorf("UpdateHouseImage with an empty patch = %+v, %v", unchanged, err)
+	}
+
+	cover, err := s.Images.SetHouseCover(ctx, house.ID, front.ID)
+	if got, want := gallery(cover), "/a.jpg:0*,/b.jpg:1,/c.jpg:2"; err != nil || got != want {
+		t.Errorf("SetHouseCover = %s, %v, want %s", got, err, want)
+	}
+
+	// Deleting the cover closes the gap and makes the new first image the cover
+	if err := s.Images.DeleteHouseImage(ctx, house.ID, front.ID); err != nil {
+		t.Fatalf("DeleteHouseImage: %v", err)
+	}
+	images, err = s.Images.ListHouseImages(ctx, house.ID)
+	if got, want := gallery(images), "/b.jpg:0*,/c.jpg:1"; err != nil || got != want {
+		t.Errorf("ListHouseImages after deleting the cover = %s, %v, want %s", got, err, want)
+	}
+
+	// Details carry the gallery
+	details, err := s.Houses.GetHouseWithDetailsByID(ctx, house.ID)
+	if err != nil || gallery(details.Images) != "/b.jpg:0*,/c.jpg:1" {
+		t.Errorf("GetHouseWithDetailsByID images = %s, %v", gallery(details.Images), err)
+	}
+	f.createHouse(t, s, "Bare", 200)
+	houses, _, err := s.Houses.ListHouses(ctx, repository.HouseFilter{})
+	if err != nil || len(houses) != 2 {
+		t.Fatalf("ListHouses = %q, %v", houseNames(houses), err)
+	}
+	for _, listed := range houses {
+		want := ""
+		if listed.ID == house.ID {
+			want = "/b.jpg:0*,/c.jpg:1"
+		}
+		if listed.Images == nil || gallery(listed.Images) != want {
+			t.Errorf("ListHouses images of %s = %#v, want %q", listed.Name, listed.Images, want)
+		}
+	}
+
+	// Replacing a house keeps its gallery
+	house.Name, house.Version = "Gallery house", 0
+	if err := s.Houses.UpdateHouse(ctx, &house); err != nil {
+		t.Fatalf("UpdateHouse: %v", err)
+	}
+	if images, err := s.Images.ListHouseImages(ctx, house.ID); err != nil || len(images) != 2 {
+		t.Errorf("ListHouseImages after UpdateHouse = %s, %v", gallery(images), err)
+	}
+
+	empty := ""
+	_, err = s.Images.UpdateHouseImage(ctx, house.ID, images[0].ID, repository.HouseImagePatch{URL: &empty})
+	if !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("UpdateHouseImage with an empty url: expected ErrValidation, got %v", err)
+	}
+	long := strings.Repeat("x", 501)
+	err = s.Images.AddHouseImage(ctx, &models.HouseImage{HouseID: house.ID, URL: "/d.jpg", Caption: long})
+	if !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("AddHouseImage with a long caption: expected ErrValidation, got %v", err)
+	}
+}
+
+func testHouseImageOrder(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	house := f.createHouse(t, s, "Gallery", 100)
+	a := addImage(t, s, house.ID, "/a.jpg", false)
+	b := addImage(t, s, house.ID, "/b.jpg", false)
+	c := addImage(t, s, house.ID, "/c.jpg", false)
+
+	images, err := s.Images.ReorderHouseImages(ctx, house.ID, []int{c.ID, a.ID, b.ID})
+	if got, want := gallery(images), "/c.jpg:0,/a.jpg:1*,/b.jpg:2"; err != nil || got != want {
+		t.Errorf("ReorderHouseImages = %s, %v, want %s", got, err, want)
+	}
+
+	for _, order := range [][]int{{c.ID, a.ID}, {c.ID, a.ID, a.ID}, {c.ID, a.ID, b.ID, 999}, nil} {
+		_, err := s.Images.ReorderHouseImages(ctx, house.ID, order)
+		if !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("ReorderHouseImages(%v): expected ErrValidation, got %v", order, err)
+		}
+	}
+	images, err = s.Images.ListHouseImages(ctx, house.ID)
+	if got, want := gallery(images), "/c.jpg:0,/a.jpg:1*,/b.jpg:2"; err != nil || got != want {
+		t.Errorf("ListHouseImages after failed reorders = %s, %v, want %s", got, err, want)
+	}
+
+	// A gallery is limited in size
+	for i := len(images); i < repository.MaxHouseImages; i++ {
+		addImage(t, s, house.ID, "/more-"+strconv.Itoa(i)+".jpg", false)
+	}
+	err = s.Images.AddHouseImage(ctx, &models.HouseImage{HouseID: house.ID, URL: "/one-too-many.jpg"})
+	if !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("AddHouseImage to a full gallery: expected ErrValidation, got %v", err)
+	}
+}
+
+func testHouseImagesNotFound(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	house := f.createHouse(t, s, "Gallery", 100)
+	other := f.createHouse(t, s, "Other", 200)
+	image := addImage(t, s, house.ID, "/a.jpg", false)
+
+	_, err := s.Images.ListHouseImages(ctx, 999)
+	expectNotFound(t, "ListHouseImages of a missing house", err)
+	expectNotFound(t, "AddHouseImage to a missing house", s.Images.AddHouseImage(ctx, &models.HouseImage{HouseID: 999, URL: "/b.jpg"}))
+
+	// An image is only found through its own house
+	caption := "Elsewhere"
+	_, err = s.Images.UpdateHouseImage(ctx, other.ID, image.ID, repository.HouseImagePatch{Caption: &caption})
+	expectNotFound(t, "UpdateHouseImage through another house", err)
+	_, err = s.Images.UpdateHouseImage(ctx, other.ID, image.ID, repository.HouseImagePatch{})
+	expectNotFound(t, "UpdateHouseImage through another house with an empty patch", err)
+	_, err = s.Images.SetHouseCover(ctx, other.ID, image.ID)
+	expectNotFound(t, "SetHouseCover through another house", err)
+	expectNotFound(t, "DeleteHouseImage through another house", s.Images.DeleteHouseImage(ctx, other.ID, image.ID))
+	expectNotFound(t, "DeleteHouseImage of a missing image", s.Images.DeleteHouseImage(ctx, house.ID, 999))
+
+	// Galleries of houses in the trash are hidden until they are restored
+	if err := s.Houses.DeleteHouse(ctx, house.ID, 0); err != nil {
+		t.Fatalf("DeleteHouse: %v", err)
+	}
+	_, err = s.Images.ListHouseImages(ctx, house.ID)
+	expectNotFound(t, "ListHouseImages in the trash", err)
+	_, err = s.Images.SetHouseCover(ctx, house.ID, image.ID)
+	expectNotFound(t, "SetHouseCover in the trash", err)
+	if _, err := s.Houses.RestoreHouse(ctx, house.ID); err != nil {
+		t.Fatalf("RestoreHouse: %v", err)
+	}
+	images, err := s.Images.ListHouseImages(ctx, house.ID)
+	if err != nil || gallery(images) != "/a.jpg:0*" {
+		t.Errorf("ListHouseImages after restoring = %s, %v", gallery(images), err)
+	}
+}
+
+func testHouseImageRenditions(t *testing.T, s Stores) {
+	ctx := t.Context()
+	f := newFixture(t, s)
+	house := f.createHouse(t, s, "Gallery", 100)
+	upload := renditions("front")
+
+	image := models.HouseImage{HouseID: house.ID, URL: upload.Large, Renditions: &upload}
+	if err := s.Images.AddHouseImage(ctx, &image); err != nil {
+		t.Fatalf("AddHouseImage: %v", err)
+	}
+	linked := addImage(t, s, house.ID, "/b.jpg", false)
+	images, err := s.Images.ListHouseImages(ctx, house.ID)
+	if err != nil || len(images) != 2 || images[0].Renditions == nil || *images[0].Renditions != upload || images[1].Renditions != nil {
+		t.Fatalf("ListHouseImages = %+v, %v, want renditions on the upload only", images, err)
+	}
+	details, err := s.Houses.GetHouseWithDetailsByID(ctx, house.ID)
+	if err != nil || details.Images[0].Renditions == nil || *details.Images[0].Renditions != upload {
+		t.Errorf("GetHouseWithDetailsByID images = %+v, %v", details.Images, err)
+	}
+	if linked.Renditions != nil {
+		t.Errorf("AddHouseImage by URL: renditions = %+v", linked.Renditions)
+	}
+
+	// Renditions belong to the URL they were uploaded as
+	caption, same, other := "Front", upload.Large, "/elsewhere.jpg"
+	updated, err := s.Images.UpdateHouseImage(ctx, house.ID, image.ID, repository.HouseImagePatch{Caption: &caption, URL: &same})
+	if err != nil || updated.Renditions == nil || *updated.Renditions != upload {
+		t.Errorf("UpdateHouseImage keeping the url = %+v, %v, want the renditions kept", updated, err)
+	}
+	updated, err = s.Images.UpdateHouseImage(ctx, house.ID, image.ID, repository.HouseImagePatch{URL: &other})
+	if err != nil || updated.Renditions != nil {
+		t.Errorf("UpdateHouseImage changing the url = %+v, %v, want no renditions", updated, err)
+	}
+}
+
+// places yields the given places.
+func places(list ...models.Place) iter.Seq2[models.Place, error] {
+	return func(yield func(models.Place, error) bool) {
+		for _, place := range list {
+			if !yield(place, nil) {
+				return
+			}
+		}
+	}
+}
+
+func importPlaces(t *testing.T, s Stores, list ...models.Place) {
+	t.Helper()
+	if n, err := s.Gazetteer.ImportPlaces(t.Context(), places(list...), false); err != nil || n != len(list) {
+		t.Fatalf("ImportPlaces = %d, %v, want %d", n, err, len(list))
+	}
+}
+
+func placeNames(places []models.Place) string {
+	var names []string
+	for _, place := range places {
+		names = append(names, place.Name+"/"+place.Region)
+	}
+	return strings.Join(names, ",")
+}
+
+func testGeocode(t *testing.T, s Stores) {
+	ctx := t.Context()
+	importPlaces(t, s,
+		models.Place{Name: "Austin", Region: "MN", Country: "US", Latitude: 43.6666, Longitude: -92.9746, Population: 26174},
+		models.Place{Name: "Austin", Region: "TX", Country: "US", Latitude: 30.2672, Longitude: -97.7431, Population: 961855},
+		models.Place{Name: "Austintown", Region: "OH", Country: "US", Latitude: 41.1017, Longitude: -80.7645, Population: 29677},
+		models.Place{Name: "Austin", Region: "TX", Country: "US", PostalCode: "78701", Latitude: 30.2713, Longitude: -97.7426},
+		models.Place{Name: "Paris", Region: "11", Country: "FR", PostalCode: "75001", Latitude: 48.8626, Longitude: 2.3363},
+		models.Place{Name: "Paris", Region: "TX", Country: "US", Latitude: 33.6609, Longitude: -95.5555, Population: 24782},
+		models.Place{Name: "100%_Town", Latitude: 0, Longitude: 0},
+	)
+
+	tests := []struct {
+		query string
+		limit int
+		want  string
+	}{
+		// Exact names before prefixes, then the most populous
+		{"austin", 10, "Austin/TX,Austin/MN,Austin/TX,Austintown/OH"},
+		{"AUSTIN,  mn", 10, "Austin/MN,Austin/TX,Austin/TX,Austintown/OH"},
+		{"Austin", 2, "Austin/TX,Austin/MN"},
+		{"austint", 10, "Austintown/OH"},
+		{"paris, fr", 10, "Paris/11,Paris/TX"},
+		{"Paris, TX, US", 10, "Paris/TX,Paris/11"},
+		{"78701", 10, "Austin/TX"},
+		{"Austin, 78701", 10, "Austin/TX,Austin/TX,Austin/MN,Austintown/OH"},
+		// Short parts match no prefixes and wildcards are literal
+		{"au", 10, ""},
+		{"100%", 10, "100%_Town/"},
+		{"100_", 10, ""},
+		{"Dallas", 10, ""},
+		{" , ", 10, ""},
+	}
+	for _, tt := range tests {
+		got, err := s.Gazetteer.Geocode(ctx, tt.query, tt.limit)
+		if err != nil {
+			t.Fatalf("Geocode(%q): %v", tt.query, err)
+		}
+		if names := placeNames(got); names != tt.want {
+			t.Errorf("Geocode(%q) = %s, want %s", tt.query, names, tt.want)
+		}
+	}
+
+	got, err := s.Gazetteer.Geocode(ctx, "78701", 1)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("Geocode(78701) = %+v, %v", got, err)
+	}
+	want := models.Place{Name: "Austin", Region: "TX", Country: "US", PostalCode: "78701", Latitude: 30.2713, Longitude: -97.7426}
+	if got[0] != want {
+		t.Errorf("Geocode(78701) = %+v, want %+v", got[0], want)
+	}
+}
+
+func testReverseGeocode(t *testing.T, s Stores) {
+	importPlaces(t, s,
+		models.Place{Name: "Austin", Region: "TX", Country: "US", Latitude: 30.2672, Longitude: -97.7431},
+		models.Place{Name: "Round Rock", Region: "TX", Country: "US", Latitude: 30.5083, Longitude: -97.6789},
+		models.Place{Name: "Georgetown", Region: "TX", Country: "US", Latitude: 30.6333, Longitude: -97.6770},
+		models.Place{Name: "Suva", Country: "FJ", Latitude: -18.1416, Longitude: 178.4419},
+		models.Place{Name: "Apia", Country: "WS", Latitude: -13.8333, Longitude: -171.7667},
+	)
+
+	got, err := s.Gazetteer.ReverseGeocode(t.Context(), repository.Point{Lat: 30.5, Lng: -97.68}, 2)
+	if err != nil {
+		t.Fatalf("ReverseGeocode: %v", err)
+	}
+	if names := placeNames(got); names != "Round Rock/TX,Georgetown/TX" {
+		t.Errorf("ReverseGeocode near Round Rock = %s", names)
+	}
+	for _, place := range got {
+		want := repository.DistanceKm(repository.Point{Lat: 30.5, Lng: -97.68}, repository.Point{Lat: place.Latitude, Lng: place.Longitude})
+		if place.DistanceKm == nil || math.Abs(*place.DistanceKm-want) > 0.01 {
+			t.Errorf("%s: distance = %v, want %.2f", place.Name, place.DistanceKm, want)
+		}
+	}
+
+	// Distances are measured across the antimeridian
+	got, err = s.Gazetteer.ReverseGeocode(t.Context(), repository.Point{Lat: -15, Lng: 179.9}, 1)
+	if err != nil || placeNames(got) != "Suva/" {
+		t.Errorf("ReverseGeocode near the antimeridian = %s, %v, want Suva", placeNames(got), err)
+	}
+}
+
+func testImportPlaces(t *testing.T, s Stores) {
+	ctx := t.Context()
+	austin := models.Place{Name: "Austin", Region: "TX", Country: "US", Latitude: 30.2672, Longitude: -97.7431}
+	importPlaces(t, s, austin)
+
+	invalid := []models.Place{
+		{Name: "", Latitude: 1, Longitude: 1},
+		{Name: "Nowhere", Latitude: 91, Longitude: 0},
+		{Name: "Nowhere", Latitude: 0, Longitude: 181},
+		{Name: "Nowhere", Country: "usa"},
+		{Name: "Nowhere", Population: -1},
+		{Name: strings.Repeat("a", 201)},
+	}
+	for _, place := range invalid {
+		// The valid place before it must not be imported either
+		_, err := s.Gazetteer.ImportPlaces(ctx, places(austin, place), false)
+		if !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("ImportPlaces(%+v): expected ErrValidation, got %v", place, err)
+		}
+	}
+	readErr := errors.New("malformed line")
+	failing := func(yield func(models.Place, error) bool) {
+		if yield(austin, nil) {
+			yield(models.Place{}, readErr)
+		}
+	}
+	if _, err := s.Gazetteer.ImportPlaces(ctx, failing, false); !errors.Is(err, readErr) {
+		t.Errorf("ImportPlaces with a read error: got %v", err)
+	}
+	if got, _ := s.Gazetteer.Geocode(ctx, "austin", 10); len(got) != 1 {
+		t.Errorf("after failed imports: %d places named Austin, want 1", len(got))
+	}
+
+	round := models.Place{Name: "Round Rock", Region: "TX", Country: "US", Latitude: 30.5083, Longitude: -97.6789}
+	if n, err := s.Gazetteer.ImportPlaces(ctx, places(round), true); err != nil || n != 1 {
+		t.Fatalf("ImportPlaces(replace) = %d, %v", n, err)
+	}
+	if got, _ := s.Gazetteer.Geocode(ctx, "austin", 10); len(got) != 0 {
+		t.Errorf("after replacing: Geocode(austin) = %s, want nothing", placeNames(got))
+	}
+	if got, _ := s.Gazetteer.Geocode(ctx, "round rock", 10); placeNames(got) != "Round Rock/TX" {
+		t.Errorf("after replacing: Geocode(round rock) = %s", placeNames(got))
+	}
+}
+
+func testCanceledContext(t *testing.T, s Stores) {
+	f := newFixture(t, s)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	if _, _, err := s.Houses.ListHouses(ctx, repository.HouseFilter{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListHouses: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.Agents.GetAgentByID(ctx, f.agent.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAgentByID: expected context.Canceled, got %v", err)
+	}
+	house := models.House{Name: "Late", Price: 1, HouseTypeID: f.houseType.ID, AgentID: f.agent.ID}
+	if err := s.Houses.CreateHouse(ctx, &house); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateHouse: expected context.Canceled, got %v", err)
+	}
+}
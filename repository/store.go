@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"iter"
+	"time"
+
+	"thugcorp.io/nomado/models"
+)
+
+// HouseStore persists houses. Lookups, updates and deletes of a missing
+// house return an error wrapping ErrNotFound. Tags are stored normalised, see
+// NormalizeTags.
+//
+// DeleteHouse moves a house to the trash rather than removing it: houses in
+// the trash are left out of every read and write, like missing houses, except
+// for ListDeletedHouses and RestoreHouse. PurgeDeletedHouses removes them for
+// good, returning the renditions of their uploaded photos so that the caller
+// can delete the stored files.
+//
+// Every write increments the house version. UpdateHouse (with house.Version),
+// PatchHouse and DeleteHouse only succeed while the stored version equals the
+// given one, returning an error wrapping ErrVersionMismatch otherwise; version
+// 0 skips the check.
+type HouseStore interface {
+	ListHouses(ctx context.Context, filter HouseFilter) ([]models.HouseWithDetails, int, error)
+	SearchHouses(ctx context.Context, search string, filter HouseFilter) ([]HouseSearchResult, int, error)
+	GetTopHousesWithDetails(ctx context.Context, currency string, limit int) ([]models.HouseWithDetails, error)
+	GetHouseByID(ctx context.Context, id int) (*models.House, error)
+	GetHouseWithDetailsByID(ctx context.Context, id int) (*models.HouseWithDetails, error)
+	CreateHouse(ctx context.Context, house *models.House) error
+	UpdateHouse(ctx context.Context, house *models.House) error
+	PatchHouse(ctx context.Context, id, version int, patch HousePatch) (*models.House, error)
+	DeleteHouse(ctx context.Context, id, version int) error
+	ListDeletedHouses(ctx context.Context, limit, offset int) ([]models.HouseWithDetails, int, error)
+	RestoreHouse(ctx context.Context, id int) (*models.House, error)
+	PurgeDeletedHouses(ctx context.Context, cutoff time.Time) (int, []models.Renditions, error)
+}
+
+// HouseImageStore persists the photo galleries of houses. Images are listed
+// by position, which counts from 0 without gaps, and a gallery that is not
+// empty has exactly one cover. Every change to a gallery increments the
+// version of its house.
+//
+// The images of a missing house, or of a house in the trash, are not found;
+// neither is an image requested through a house it does not belong to.
+type HouseImageStore interface {
+	ListHouseImages(ctx context.Context, houseID int) ([]models.HouseImage, error)
+	// AddHouseImage appends image to the gallery of image.HouseID, setting its
+	// ID, Position and CreatedAt. It becomes the cover if image.IsCover is set
+	// or the gallery was empty. A full gallery returns an error wrapping
+	// ErrValidation, see MaxHouseImages.
+	AddHouseImage(ctx context.Context, image *models.HouseImage) error
+	UpdateHouseImage(ctx context.Context, houseID, imageID int, patch HouseImagePatch) (*models.HouseImage, error)
+	// DeleteHouseImage closes the gap left in the positions. Deleting the
+	// cover makes the first remaining image the cover.
+	DeleteHouseImage(ctx context.Context, houseID, imageID int) error
+	// ReorderHouseImages moves the images to the positions of their IDs in
+	// imageIDs, which must list every image of the house once, and returns the
+	// gallery. See CheckImageOrder.
+	ReorderHouseImages(ctx context.Context, houseID int, imageIDs []int) ([]models.HouseImage, error)
+	// SetHouseCover makes an image the cover and returns the gallery.
+	SetHouseCover(ctx context.Context, houseID, imageID int) ([]models.HouseImage, error)
+}
+
+// AgentStore persists agents. Agents are listed by first then last name.
+type AgentStore interface {
+	GetAllAgents(ctx context.Context) ([]models.Agent, error)
+	GetAgentByID(ctx context.Context, id int) (*models.Agent, error)
+	CreateAgent(ctx context.Context, agent *models.Agent) error
+	// UpdateAgent keeps the image renditions only while the image URL is
+	// unchanged, and sets agent.ImageRenditions accordingly.
+	UpdateAgent(ctx context.Context, agent *models.Agent) error
+	// SetAgentImage sets the image of an agent to an upload: its URL becomes
+	// the large rendition.
+	SetAgentImage(ctx context.Context, id int, renditions models.Renditions) (*models.Agent, error)
+	DeleteAgent(ctx context.Context, id int) error
+}
+
+// HouseTypeStore persists house types. House types are listed by name, and
+// names are unique: duplicates return an error wrapping ErrConflict.
+type HouseTypeStore interface {
+	GetAllHouseTypes(ctx context.Context) ([]models.HouseType, error)
+	GetAllHouseTypesWithCounts(ctx context.Context) ([]models.HouseTypeWithCount, error)
+	GetHouseTypeByID(ctx context.Context, id int) (*models.HouseType, error)
+	GetHouseTypeWithCountByID(ctx context.Context, id int) (*models.HouseTypeWithCount, error)
+	CreateHouseType(ctx context.Context, houseType *models.HouseType) error
+	UpdateHouseType(ctx context.Context, houseType *models.HouseType) error
+	DeleteHouseType(ctx context.Context, id int) error
+}
+
+// TagStore lists the tags carried by houses. Tags are normalised slugs, see
+// NormalizeTag, and are listed by descending usage, then by name.
+type TagStore interface {
+	ListTags(ctx context.Context) ([]models.TagCount, error)
+}
+
+var (
+	_ HouseStore      = (*HouseRepository)(nil)
+	_ HouseImageStore = (*HouseRepository)(nil)
+	_ TagStore        = (*HouseRepository)(nil)
+	_ AgentStore      = (*AgentRepository)(nil)
+	_ HouseTypeStore  = (*HouseTypeRepository)(nil)
+)
+
+// Geocoder turns addresses into coordinates and coordinates into places. The
+// gazetteer stores implement it from imported place files, without network
+// access; a remote geocoding service can implement it as well.
+type Geocoder interface {
+	// Geocode returns the places best matching a free-text query of comma
+	// separated parts, such as "Austin, TX, US" or "78701", best first.
+	// Ties go to the most populous place.
+	Geocode(ctx context.Context, query string, limit int) ([]models.Place, error)
+	// ReverseGeocode returns the places nearest to p, nearest first, with
+	// their distance from p.
+	ReverseGeocode(ctx context.Context, p Point, limit int) ([]models.Place, error)
+}
+
+// GazetteerStore holds the places of the offline geocoder.
+type GazetteerStore interface {
+	Geocoder
+	// ImportPlaces adds places, after removing every stored place if replace
+	// is set, and returns how many were added. An error from places aborts
+	// the import and leaves the gazetteer unchanged. Invalid places return an
+	// error wrapping ErrValidation.
+	ImportPlaces(ctx context.Context, places iter.Seq2[models.Place, error], replace bool) (int, error)
+}
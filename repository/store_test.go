@@ -0,0 +1,70 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"thugcorp.io/nomado/db"
+	"thugcorp.io/nomado/repository"
+	"thugcorp.io/nomado/repository/repositorytest"
+)
+
+var schemaCount atomic.Int64
+
+// openTestDB connects to the database named by NOMADO_TEST_DATABASE_URL and
+// migrates a fresh, empty schema that is dropped when the test ends.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("NOMADO_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("NOMADO_TEST_DATABASE_URL not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// A single connection keeps the search_path below in effect for every query
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+
+	schema := fmt.Sprintf("nomado_test_%d_%d", os.Getpid(), schemaCount.Add(1))
+	setup := []string{
+		`CREATE SCHEMA ` + schema,
+		`SET search_path TO ` + schema,
+	}
+	for _, stmt := range setup {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("failed to prepare schema: %v", err)
+		}
+	}
+	t.Cleanup(func() { conn.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	database := &db.Database{DB: conn}
+	if _, err := database.MigrateUp(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	return conn
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Stores {
+		conn := openTestDB(t)
+		houses := repository.NewHouseRepository(conn)
+		return repositorytest.Stores{
+			Houses:     houses,
+			Images:     houses,
+			Tags:       houses,
+			Agents:     repository.NewAgentRepository(conn),
+			HouseTypes: repository.NewHouseTypeRepository(conn),
+			Gazetteer:  repository.NewGazetteerRepository(conn),
+		}
+	})
+}
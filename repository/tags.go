@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeTag returns the canonical slug of a tag: lower case, with every run
+// of characters other than letters and digits replaced by a single hyphen and
+// no leading or trailing hyphen, so "  Ocean Views!" becomes "ocean-views".
+// It returns "" if the tag has no letters or digits.
+func NormalizeTag(tag string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(tag) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingHyphen = b.Len() > 0
+			continue
+		}
+		if pendingHyphen {
+			b.WriteByte('-')
+			pendingHyphen = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// NormalizeTags normalises every tag and drops empty and duplicate slugs,
+// keeping the first occurrence. It returns nil if no tag remains.
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		slug := NormalizeTag(tag)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		normalized = append(normalized, slug)
+	}
+	return normalized
+}
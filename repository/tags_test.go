@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"garden":          "garden",
+		"  Ocean Views! ": "ocean-views",
+		"city_center":     "city-center",
+		"4-Bedroom":       "4-bedroom",
+		"a,b":             "a-b",
+		"Café Terrasse":   "café-terrasse",
+		"--":              "",
+		"":                "",
+	}
+	for tag, want := range tests {
+		if got := NormalizeTag(tag); got != want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{"Pool", "", "garden", "POOL", "!!", "Garden "})
+	if want := []string{"pool", "garden"}; !slices.Equal(got, want) {
+		t.Errorf("NormalizeTags = %q, want %q", got, want)
+	}
+	if got := NormalizeTags([]string{" "}); got != nil {
+		t.Errorf("NormalizeTags of blank tags = %q, want nil", got)
+	}
+}
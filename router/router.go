@@ -0,0 +1,115 @@
+// Package router maps "METHOD /path/{param}" routes onto a Go 1.22+
+// http.ServeMux while letting the application render its own 404 and 405
+// responses. Requests for a known path with an unsupported method get a 405
+// with an Allow header listing the supported methods.
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Methods that receive a 405 when a path does not support them.
+var knownMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost,
+	http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+type route struct {
+	method  string
+	path    string
+	handler http.Handler
+}
+
+// Router collects routes; call Handler once all of them are registered.
+type Router struct {
+	// NotFound handles requests that match no path. Defaults to http.NotFound.
+	NotFound http.Handler
+	// MethodNotAllowed handles requests for a known path with an unsupported
+	// method. The Allow header is already set when it runs.
+	MethodNotAllowed http.Handler
+
+	routes []route
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for method and a ServeMux path pattern such as
+// /api/houses/{id}.
+func (rt *Router) Handle(method, path string, handler http.Handler) {
+	rt.routes = append(rt.routes, route{method: method, path: path, handler: handler})
+}
+
+// HandleFunc registers a handler function for method and path.
+func (rt *Router) HandleFunc(method, path string, handler http.HandlerFunc) {
+	rt.Handle(method, path, handler)
+}
+
+// Handler builds the ServeMux for the registered routes. It panics if two
+// routes conflict, like http.ServeMux.Handle.
+func (rt *Router) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	methodsByPath := make(map[string]map[string]bool)
+	var paths []string
+	for _, r := range rt.routes {
+		mux.Handle(r.method+" "+r.path, r.handler)
+
+		if methodsByPath[r.path] == nil {
+			methodsByPath[r.path] = make(map[string]bool)
+			paths = append(paths, r.path)
+		}
+		methodsByPath[r.path][r.method] = true
+		// ServeMux answers HEAD with the GET handler
+		if r.method == http.MethodGet {
+			methodsByPath[r.path][http.MethodHead] = true
+		}
+	}
+
+	methodNotAllowed := rt.MethodNotAllowed
+	if methodNotAllowed == nil {
+		methodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		})
+	}
+
+	// Claim the remaining methods of every path so that they are answered
+	// with a 405 rather than falling through to the catch-all 404
+	for _, path := range paths {
+		methods := methodsByPath[path]
+		allowed := make([]string, 0, len(methods)+1)
+		for method := range methods {
+			allowed = append(allowed, method)
+		}
+		allowed = append(allowed, http.MethodOptions)
+		sort.Strings(allowed)
+		allow := strings.Join(allowed, ", ")
+
+		for _, method := range knownMethods {
+			if methods[method] {
+				continue
+			}
+			mux.Handle(method+" "+path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Allow", allow)
+				methodNotAllowed.ServeHTTP(w, r)
+			}))
+		}
+	}
+
+	notFound := rt.NotFound
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	mux.Handle("/", notFound)
+
+	return mux
+}
+
+// PathInt returns the named path parameter as an integer.
+func PathInt(r *http.Request, name string) (int, error) {
+	return strconv.Atoi(r.PathValue(name))
+}
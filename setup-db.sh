@@ -45,7 +45,7 @@ echo ""
 echo "To run the application:"
 echo "1. Make sure PostgreSQL is running"
 echo "2. Update the .env file with your database credentials if needed"
-echo "3. Run: go run main.go"
+echo "3. Run: go run ."
 echo ""
-echo "The application will automatically create tables and seed sample data."
+echo "The application will automatically apply database migrations and seed sample data."
 echo "Access the application at: http://localhost:8080"
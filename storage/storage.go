@@ -0,0 +1,57 @@
+// Package storage keeps uploaded files, such as the renditions of house and
+// agent photos, on the local filesystem or in an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// Storage stores files under slash-separated keys such as
+// houses/1/3f2a9c/large.jpg. Implementations are safe for concurrent use.
+type Storage interface {
+	// Put stores data under key, replacing any file stored there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes the file stored under key. Deleting a missing file is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients fetch the file stored under key from.
+	URL(key string) string
+	// Key returns the key of a URL returned by URL, or false for the URLs of
+	// files stored elsewhere.
+	Key(url string) (key string, ok bool)
+}
+
+// cacheControl is sent with stored files. Every upload gets new keys, so a
+// stored file never changes.
+const cacheControl = "public, max-age=31536000, immutable"
+
+// ValidKey reports whether key is a relative, slash-separated path without
+// empty, . or .. elements.
+func ValidKey(key string) bool {
+	return key != "." && fs.ValidPath(key)
+}
+
+// escapeKey escapes each element of a key for use in a URL path.
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// keyOf returns the key of a URL made of base, a slash and an escaped key.
+func keyOf(base, rawURL string) (string, bool) {
+	escaped, ok := strings.CutPrefix(rawURL, base+"/")
+	if !ok {
+		return "", false
+	}
+	key, err := url.PathUnescape(escaped)
+	if err != nil || !ValidKey(key) {
+		return "", false
+	}
+	return key, true
+}
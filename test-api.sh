@@ -14,6 +14,7 @@ test_endpoint() {
     local endpoint=$2
     local data=$3
     local description=$4
+    local if_match=$5
     
     echo "Testing: $description"
     echo "Method: $method $API_BASE$endpoint"
@@ -22,10 +23,12 @@ test_endpoint() {
         echo "Data: $data"
         response=$(curl -s -X $method "$API_BASE$endpoint" \
             -H "Content-Type: application/json" \
+            ${if_match:+-H "If-Match: $if_match"} \
             -d "$data" \
             -w "\nHTTP_STATUS:%{http_code}")
     else
         response=$(curl -s -X $method "$API_BASE$endpoint" \
+            ${if_match:+-H "If-Match: $if_match"} \
             -w "\nHTTP_STATUS:%{http_code}")
     fi
     
@@ -42,7 +45,7 @@ test_endpoint() {
 echo "Checking if API server is running..."
 if ! curl -s "$API_BASE/api/health" > /dev/null; then
     echo "❌ API server is not running on $API_BASE"
-    echo "Please start the server with: go run main.go"
+    echo "Please start the server with: go run ."
     exit 1
 fi
 
@@ -90,10 +93,15 @@ update_data='{
   "tags": ["updated", "test"],
   "agent_id": 2
 }'
-test_endpoint "PUT" "/api/houses/8" "$update_data" "Update House (ID 8)"
+test_endpoint "PUT" "/api/houses/8" "$update_data" "Update House (ID 8)" "*"
 
 # Test Delete House
-test_endpoint "DELETE" "/api/houses/8" "" "Delete House (ID 8)"
+test_endpoint "DELETE" "/api/houses/8" "" "Delete House (ID 8)" "*"
+
+# Test Trash
+test_endpoint "GET" "/api/houses/trash" "" "Get Deleted Houses"
+test_endpoint "POST" "/api/houses/8/restore" "" "Restore House (ID 8)"
+test_endpoint "DELETE" "/api/houses/8" "" "Delete House Again (ID 8)" "*"
 
 # Test Invalid Endpoints
 test_endpoint "GET" "/api/invalid" "" "Invalid Endpoint (Should return 404)"
@@ -109,6 +117,8 @@ echo "- GET    /api/houses/top   - Top houses"
 echo "- GET    /api/houses/{id}  - Specific house"
 echo "- POST   /api/houses       - Create house"
 echo "- PUT    /api/houses/{id}  - Update house"
-echo "- DELETE /api/houses/{id}  - Delete house"
+echo "- DELETE /api/houses/{id}  - Move house to the trash"
+echo "- GET    /api/houses/trash - Deleted houses"
+echo "- POST   /api/houses/{id}/restore - Restore house"
 echo "- GET    /api/agents       - All agents"
 echo "- GET    /api/house-types  - All house types"
@@ -0,0 +1,300 @@
+// Package validation decodes JSON request bodies strictly and collects
+// field-level errors so that a client learns about every invalid field in a
+// single response.
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error codes reported in FieldError.Code
+const (
+	CodeRequired     = "required"
+	CodeTooLong      = "too_long"
+	CodeNotPositive  = "must_be_positive"
+	CodeTooLarge     = "too_large"
+	CodeOutOfRange   = "out_of_range"
+	CodeInvalid      = "invalid"
+	CodeInvalidType  = "invalid_type"
+	CodeNotFound     = "not_found"
+	CodeUnknownField = "unknown_field"
+	CodeReadOnly     = "read_only"
+)
+
+// ErrMalformedJSON is returned by DecodeJSON when the body is not a single
+// well-formed JSON value.
+var ErrMalformedJSON = errors.New("malformed JSON body")
+
+// FieldError describes one invalid field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Errors is a list of field errors. It is returned as an error by
+// Validator.Err and DecodeJSON.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Code
+	}
+	return "invalid fields: " + strings.Join(parts, ", ")
+}
+
+// DecodeJSON decodes a single JSON value from r into v. Unknown fields and
+// values of the wrong type are reported as Errors, anything else that is not
+// valid JSON as ErrMalformedJSON.
+func DecodeJSON(r io.Reader, v interface{}) error {
+	var body bytes.Buffer
+	decoder := json.NewDecoder(io.TeeReader(r, &body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &typeErr):
+			field := typeErr.Field
+			if field == "" {
+				field = rejectingField(body.Bytes(), v, typeErr.Type)
+			}
+			return Errors{{Field: field, Code: CodeInvalidType, Message: "must be " + jsonType(typeErr.Type)}}
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			return Errors{{Field: field, Code: CodeUnknownField}}
+		default:
+			return fmt.Errorf("%w: %w", ErrMalformedJSON, err)
+		}
+	}
+	if decoder.More() {
+		return fmt.Errorf("%w: unexpected data after the JSON value", ErrMalformedJSON)
+	}
+	return nil
+}
+
+// DecodeMergePatch decodes an RFC 7396 JSON merge patch into v, which should
+// hold the zero value of the patched type, and returns the names of the
+// members present in the patch. A member set to null leaves its zero value in
+// v, which clears the field. Objects are merged member by member: their
+// members are named with a dotted path such as "address.city", and an empty
+// object changes nothing.
+func DecodeMergePatch(r io.Reader, v interface{}) (map[string]bool, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedJSON, err)
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		return nil, fmt.Errorf("%w: a merge patch must be a JSON object", ErrMalformedJSON)
+	}
+	if err := DecodeJSON(bytes.NewReader(body), v); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool, len(members))
+	mergeMembers(fields, "", members)
+	return fields, nil
+}
+
+// mergeMembers records the dotted names of the members of a merge patch
+// object in fields, descending into nested objects.
+func mergeMembers(fields map[string]bool, prefix string, members map[string]json.RawMessage) {
+	for name, value := range members {
+		var object map[string]json.RawMessage
+		if json.Unmarshal(value, &object) == nil && object != nil {
+			mergeMembers(fields, prefix+name+".", object)
+			continue
+		}
+		fields[prefix+name] = true
+	}
+}
+
+// rejectingField returns the JSON name of the field of the struct v points to
+// whose value in body cannot be decoded into typ. Type errors returned by a
+// json.Unmarshaler do not always carry the field name, so it is recovered by
+// decoding the candidate fields one by one.
+func rejectingField(body []byte, v interface{}, typ reflect.Type) string {
+	var members map[string]json.RawMessage
+	if json.Unmarshal(body, &members) != nil {
+		return ""
+	}
+	structType := reflect.TypeOf(v)
+	for structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return ""
+	}
+	for _, field := range reflect.VisibleFields(structType) {
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType != typ {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if value, ok := members[name]; ok && json.Unmarshal(value, reflect.New(typ).Interface()) != nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// jsonTyper is implemented by types with their own JSON decoding to describe
+// the values they accept, e.g. "a number with at most two decimals".
+type jsonTyper interface {
+	JSONType() string
+}
+
+// jsonType names the JSON type that corresponds to a Go type.
+func jsonType(typ reflect.Type) string {
+	if typer, ok := reflect.Zero(typ).Interface().(jsonTyper); ok {
+		return typer.JSONType()
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// Validator collects field errors. The zero value is ready to use.
+type Validator struct {
+	errs Errors
+}
+
+// Add records an error for field.
+func (v *Validator) Add(field, code, message string) {
+	v.errs = append(v.errs, FieldError{Field: field, Code: code, Message: message})
+}
+
+// HasError reports whether an error has been recorded for field.
+func (v *Validator) HasError(field string) bool {
+	for _, fe := range v.errs {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the collected errors, or nil if there are none.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Required checks that value is not empty.
+func (v *Validator) Required(field, value string) bool {
+	if value == "" {
+		v.Add(field, CodeRequired, "is required")
+		return false
+	}
+	return true
+}
+
+// MaxLength checks that value has at most max characters.
+func (v *Validator) MaxLength(field, value string, max int) bool {
+	if utf8.RuneCountInString(value) > max {
+		v.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", max))
+		return false
+	}
+	return true
+}
+
+// Positive checks that value is greater than zero.
+func (v *Validator) Positive(field string, value float64) bool {
+	if !(value > 0) {
+		v.Add(field, CodeNotPositive, "must be greater than 0")
+		return false
+	}
+	return true
+}
+
+// Below checks that value is less than limit.
+func (v *Validator) Below(field string, value, limit float64) bool {
+	if value >= limit {
+		v.Add(field, CodeTooLarge, "must be less than "+strconv.FormatFloat(limit, 'f', -1, 64))
+		return false
+	}
+	return true
+}
+
+// Between checks that value, if set, lies in the inclusive range [low, high].
+func Between[T int | float64](v *Validator, field string, value *T, low, high T) bool {
+	if value == nil || (*value >= low && *value <= high) {
+		return true
+	}
+	v.Add(field, CodeOutOfRange, fmt.Sprintf("must be between %v and %v", low, high))
+	return false
+}
+
+// RequiredID checks that id is set, i.e. positive.
+func (v *Validator) RequiredID(field string, id int) bool {
+	if id <= 0 {
+		v.Add(field, CodeRequired, "is required")
+		return false
+	}
+	return true
+}
+
+// ImageURL checks that value, if set, is an absolute http(s) URL or a
+// site-relative path such as /images/generic_actor.jpg.
+func (v *Validator) ImageURL(field string, value *string) bool {
+	if value == nil || isImageURL(*value) {
+		return true
+	}
+	v.Add(field, CodeInvalid, "must be an http(s) URL or an absolute path")
+	return false
+}
+
+func isImageURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	if parsed.Scheme == "" {
+		return parsed.Host == "" && strings.HasPrefix(parsed.Path, "/")
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// TrimOptional trims an optional string in place and clears it when empty.
+func TrimOptional(value **string) {
+	if *value == nil {
+		return
+	}
+	trimmed := strings.TrimSpace(**value)
+	if trimmed == "" {
+		*value = nil
+		return
+	}
+	*value = &trimmed
+}